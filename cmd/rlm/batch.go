@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/kukks/claude-rlm/internal/fsutil"
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+	"github.com/kukks/claude-rlm/internal/storage"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var analyzeBatchCmd = &cobra.Command{
+	Use:   "analyze-batch [paths-file] [query]",
+	Short: "Analyze many paths with the same query",
+	Long: `Analyze each path listed in paths-file (one per line; blank lines and lines
+starting with # are ignored) with the same query, storing every analysis and
+writing a combined Markdown report.
+
+Paths whose stored analysis for the query is still fresh are not analyzed
+again. A path that fails is recorded in the report without stopping the batch.
+Progress is checkpointed to <paths-file>.checkpoint.json after every path, so
+re-running an interrupted batch resumes where it stopped.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		report, _ := cmd.Flags().GetString("report")
+
+		if err := runAnalyzeBatch(args[0], args[1], report); err != nil {
+			log.Fatal().Err(err).Msg("Batch analysis failed")
+		}
+	},
+}
+
+func init() {
+	analyzeBatchCmd.Flags().StringP("report", "r", "", "Write the report to this file instead of stdout")
+
+	rootCmd.AddCommand(analyzeBatchCmd)
+}
+
+// Batch entry statuses
+const (
+	batchAnalyzed  = "analyzed"
+	batchUnchanged = "unchanged"
+	batchFailed    = "failed"
+)
+
+// batchEntry records the outcome of one path in a batch
+type batchEntry struct {
+	Path       string    `json:"path"`
+	Status     string    `json:"status"`
+	AnalysisID string    `json:"analysis_id,omitempty"`
+	Content    string    `json:"content,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Completed  time.Time `json:"completed"`
+}
+
+// batchCheckpoint is the progress of a batch, saved after every path
+type batchCheckpoint struct {
+	Query   string       `json:"query"`
+	Entries []batchEntry `json:"entries"`
+}
+
+// batchCheckpointPath returns where the checkpoint for a paths file lives
+func batchCheckpointPath(pathsFile string) string {
+	return pathsFile + ".checkpoint.json"
+}
+
+func runAnalyzeBatch(pathsFile, query, reportPath string) error {
+	// Stop cleanly on Ctrl-C; the checkpoint lets a re-run pick up from here
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	paths, err := readBatchPaths(pathsFile)
+	if err != nil {
+		return err
+	}
+
+	cfg, backend, err := openBackend(ctx)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	logger := setupLogger(cfg)
+
+	orchConfig, err := newOrchestratorConfig(cfg)
+	if err != nil {
+		return err
+	}
+	orch := orchestrator.New(orchConfig, logger)
+	orch.SetDispatcher(orchestrator.PlaceholderDispatcher)
+
+	checkpoint, err := analyzeBatch(ctx, orch, backend, paths, query, batchCheckpointPath(pathsFile))
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if reportPath != "" {
+		f, err := os.Create(reportPath)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	return writeBatchReport(out, checkpoint)
+}
+
+// readBatchPaths reads one path per line, skipping blank lines and # comments
+func readBatchPaths(pathsFile string) ([]string, error) {
+	f, err := os.Open(pathsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open paths file: %w", err)
+	}
+	defer f.Close()
+
+	var paths []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read paths file: %w", err)
+	}
+	return paths, nil
+}
+
+// analyzeBatch analyzes each path with query, skipping paths already
+// completed in the checkpoint and paths whose stored analysis is still
+// fresh. Failures are recorded and retried on the next run. When ctx is
+// cancelled the checkpoint is kept and ctx's error returned; once every
+// path has completed the checkpoint is removed.
+func analyzeBatch(ctx context.Context, orch *orchestrator.Orchestrator, backend storage.Backend, paths []string, query, checkpointPath string) (*batchCheckpoint, error) {
+	checkpoint, err := loadBatchCheckpoint(checkpointPath, query)
+	if err != nil {
+		return nil, err
+	}
+
+	completed := make(map[string]bool)
+	for _, entry := range checkpoint.Entries {
+		if entry.Status != batchFailed {
+			completed[entry.Path] = true
+		}
+	}
+
+	for _, path := range paths {
+		path = hash.CanonicalPath(path)
+		if completed[path] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return checkpoint, err
+		}
+
+		entry := analyzeBatchPath(ctx, orch, backend, path, query)
+		if entry.Status == batchFailed && ctx.Err() != nil {
+			// Interrupted rather than failed; the saved orchestrator state
+			// resumes this path on the next run
+			return checkpoint, ctx.Err()
+		}
+
+		checkpoint.record(entry)
+		completed[path] = entry.Status != batchFailed
+		if err := saveBatchCheckpoint(checkpointPath, checkpoint); err != nil {
+			return checkpoint, err
+		}
+	}
+
+	if err := os.Remove(checkpointPath); err != nil && !os.IsNotExist(err) {
+		return checkpoint, err
+	}
+	return checkpoint, nil
+}
+
+// analyzeBatchPath analyzes a single path, or reuses its stored analysis
+// when none of its files changed since
+func analyzeBatchPath(ctx context.Context, orch *orchestrator.Orchestrator, backend storage.Backend, path, query string) batchEntry {
+	entry := batchEntry{Path: path}
+	fail := func(err error) batchEntry {
+		entry.Status = batchFailed
+		entry.Error = err.Error()
+		entry.Completed = time.Now()
+		return entry
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return fail(err)
+	}
+
+	latest, err := latestAnalysis(ctx, backend, path, query)
+	if err != nil {
+		return fail(err)
+	}
+	if latest != nil {
+		staleness, err := hash.CheckStaleness(orch.HashOptions(), latest.FileHashes, latest.HashAlgorithm, path, latest.Timestamp)
+		if err == nil && !staleness.Stale {
+			entry.Status = batchUnchanged
+			entry.AnalysisID = latest.ID
+			entry.Content, _ = latest.Result["content"].(string)
+			entry.Completed = time.Now()
+			return entry
+		}
+	}
+
+	hasher := hash.NewFileHasher(orch.HashOptions())
+	fileHashes, fileStats, err := hasher.ComputeDirectoryHashWithStats(path)
+	if err != nil {
+		return fail(fmt.Errorf("failed to compute file hashes: %w", err))
+	}
+
+	result, err := orch.AnalyzeDocument(ctx, path, query)
+	if err != nil {
+		if ctx.Err() == nil {
+			// Don't let this path's saved state resume into the next one
+			orch.ClearState()
+		}
+		return fail(err)
+	}
+
+	data := &storage.AnalysisData{
+		Query:      query,
+		Timestamp:  time.Now(),
+		Result:     map[string]interface{}{"content": result.Content, "metadata": result.Metadata, "details": result.Details},
+		Stats:      orch.GetStats(),
+		Path:       path,
+		FileHashes: fileHashes,
+		FileStats:  fileStats,
+		Trace:      result.Trace,
+	}
+	data.HashAlgorithm = hasher.Algorithm()
+	if err := backend.Store(ctx, data); err != nil {
+		return fail(fmt.Errorf("failed to store analysis: %w", err))
+	}
+
+	entry.Status = batchAnalyzed
+	entry.AnalysisID = data.ID
+	entry.Content = result.Content
+	entry.Completed = time.Now()
+	return entry
+}
+
+// latestAnalysis returns the newest stored analysis of path for query in the
+// default namespace, or nil
+func latestAnalysis(ctx context.Context, backend storage.Backend, path, query string) (*storage.AnalysisData, error) {
+	var latest *storage.AnalysisData
+	err := backend.Walk(ctx, func(data *storage.AnalysisData) error {
+		if data.Query == query && hash.SamePath(data.Path, path) && storage.SameNamespace(data.Namespace, "") &&
+			(latest == nil || data.Timestamp.After(latest.Timestamp)) {
+			latest = data
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load analyses: %w", err)
+	}
+	return latest, nil
+}
+
+// record adds entry, replacing an earlier failed attempt at the same path
+func (c *batchCheckpoint) record(entry batchEntry) {
+	for i := range c.Entries {
+		if c.Entries[i].Path == entry.Path {
+			c.Entries[i] = entry
+			return
+		}
+	}
+	c.Entries = append(c.Entries, entry)
+}
+
+// loadBatchCheckpoint reads the checkpoint for query. A missing checkpoint,
+// or one left by a batch with a different query, starts a new batch.
+func loadBatchCheckpoint(checkpointPath, query string) (*batchCheckpoint, error) {
+	data, err := os.ReadFile(checkpointPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &batchCheckpoint{Query: query}, nil
+		}
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var checkpoint batchCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", checkpointPath, err)
+	}
+	if checkpoint.Query != query {
+		return &batchCheckpoint{Query: query}, nil
+	}
+	return &checkpoint, nil
+}
+
+func saveBatchCheckpoint(checkpointPath string, checkpoint *batchCheckpoint) error {
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFile(checkpointPath, data, 0644, true); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// writeBatchReport writes a Markdown report with a summary table followed
+// by each path's result
+func writeBatchReport(w io.Writer, checkpoint *batchCheckpoint) error {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "# Batch analysis: %s\n\n", checkpoint.Query)
+	sb.WriteString("| Path | Status | Analysis |\n|------|--------|----------|\n")
+	for _, entry := range checkpoint.Entries {
+		fmt.Fprintf(&sb, "| `%s` | %s | %s |\n", entry.Path, entry.Status, entry.AnalysisID)
+	}
+
+	for _, entry := range checkpoint.Entries {
+		fmt.Fprintf(&sb, "\n## %s\n\n", entry.Path)
+		switch {
+		case entry.Status == batchFailed:
+			fmt.Fprintf(&sb, "**Failed:** %s\n", entry.Error)
+		case strings.TrimSpace(entry.Content) == "":
+			sb.WriteString("_No result content._\n")
+		default:
+			sb.WriteString(strings.TrimSpace(entry.Content))
+			sb.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+	"github.com/kukks/claude-rlm/internal/storage"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBatchFixture creates two project directories, a paths file listing
+// them plus a missing path, a backend and an orchestrator whose dispatcher
+// records the document analyzed by each Explorer call
+func newBatchFixture(t *testing.T) (paths []string, pathsFile string, backend storage.Backend, orch *orchestrator.Orchestrator, dispatched *[]string) {
+	root := t.TempDir()
+	for _, name := range []string{"alpha", "beta"} {
+		dir := filepath.Join(root, name)
+		require.NoError(t, os.Mkdir(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package "+name), 0644))
+		paths = append(paths, dir)
+	}
+	paths = append(paths, filepath.Join(root, "missing"))
+
+	pathsFile = filepath.Join(root, "paths.txt")
+	content := "# audit targets\n" + paths[0] + "\n\n" + paths[1] + "\n" + paths[2] + "\n"
+	require.NoError(t, os.WriteFile(pathsFile, []byte(content), 0644))
+
+	backend, err := storage.NewBM25Backend(storage.DefaultConfig(t.TempDir()))
+	require.NoError(t, err)
+	t.Cleanup(func() { backend.Close() })
+
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir()
+	config.CacheEnabled = false
+	orch = orchestrator.New(config, zerolog.Nop())
+
+	dispatched = new([]string)
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		if task.AgentType == "Explorer" {
+			*dispatched = append(*dispatched, task.Context["document_path"].(string))
+		}
+		return orchestrator.PlaceholderDispatcher(ctx, task)
+	})
+
+	return paths, pathsFile, backend, orch, dispatched
+}
+
+func TestAnalyzeBatch(t *testing.T) {
+	paths, pathsFile, backend, orch, dispatched := newBatchFixture(t)
+	ctx := context.Background()
+
+	listed, err := readBatchPaths(pathsFile)
+	require.NoError(t, err)
+	require.Equal(t, paths, listed)
+
+	checkpointPath := batchCheckpointPath(pathsFile)
+	checkpoint, err := analyzeBatch(ctx, orch, backend, listed, "audit", checkpointPath)
+	require.NoError(t, err)
+
+	// Each existing path is analyzed and stored; the missing one fails
+	// without stopping the batch
+	require.Len(t, checkpoint.Entries, 3)
+	assert.Equal(t, paths[:2], *dispatched)
+	assert.Equal(t, batchAnalyzed, checkpoint.Entries[0].Status)
+	assert.Equal(t, batchAnalyzed, checkpoint.Entries[1].Status)
+	assert.Equal(t, batchFailed, checkpoint.Entries[2].Status)
+	assert.NotEmpty(t, checkpoint.Entries[2].Error)
+
+	stored, err := backend.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, stored, 2)
+
+	// A completed batch leaves no checkpoint behind
+	assert.NoFileExists(t, checkpointPath)
+
+	var report bytes.Buffer
+	require.NoError(t, writeBatchReport(&report, checkpoint))
+	assert.Contains(t, report.String(), "# Batch analysis: audit")
+	assert.Contains(t, report.String(), "**Failed:**")
+
+	// Running again skips the unchanged paths
+	*dispatched = nil
+	checkpoint, err = analyzeBatch(ctx, orch, backend, listed, "audit", checkpointPath)
+	require.NoError(t, err)
+	assert.Empty(t, *dispatched)
+	assert.Equal(t, batchUnchanged, checkpoint.Entries[0].Status)
+	assert.Equal(t, batchUnchanged, checkpoint.Entries[1].Status)
+	assert.Equal(t, stored[0].ID, checkpoint.Entries[0].AnalysisID)
+
+	// ...but re-analyzes one whose files changed
+	require.NoError(t, os.WriteFile(filepath.Join(paths[1], "extra.go"), []byte("package beta"), 0644))
+	_, err = analyzeBatch(ctx, orch, backend, listed, "audit", checkpointPath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{hash.CanonicalPath(paths[1])}, *dispatched)
+}
+
+func TestAnalyzeBatchResumes(t *testing.T) {
+	paths, pathsFile, backend, orch, dispatched := newBatchFixture(t)
+	paths = paths[:2]
+	checkpointPath := batchCheckpointPath(pathsFile)
+
+	// Interrupt the batch while it analyzes the second path
+	ctx, cancel := context.WithCancel(context.Background())
+	orch.SetDispatcher(func(dctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		if task.AgentType == "Explorer" {
+			*dispatched = append(*dispatched, task.Context["document_path"].(string))
+			if len(*dispatched) == 2 {
+				cancel()
+				return nil, dctx.Err()
+			}
+		}
+		return orchestrator.PlaceholderDispatcher(dctx, task)
+	})
+
+	_, err := analyzeBatch(ctx, orch, backend, paths, "audit", checkpointPath)
+	require.ErrorIs(t, err, context.Canceled)
+
+	saved, err := loadBatchCheckpoint(checkpointPath, "audit")
+	require.NoError(t, err)
+	require.Len(t, saved.Entries, 1)
+	assert.Equal(t, hash.CanonicalPath(paths[0]), saved.Entries[0].Path)
+
+	// The re-run picks up at the interrupted path
+	*dispatched = nil
+	checkpoint, err := analyzeBatch(context.Background(), orch, backend, paths, "audit", checkpointPath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{hash.CanonicalPath(paths[1])}, *dispatched)
+	require.Len(t, checkpoint.Entries, 2)
+	assert.Equal(t, batchAnalyzed, checkpoint.Entries[0].Status)
+	assert.Equal(t, batchAnalyzed, checkpoint.Entries[1].Status)
+	assert.NoFileExists(t, checkpointPath)
+}
@@ -1,17 +1,24 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/kukks/claude-rlm/internal/config"
+	"github.com/kukks/claude-rlm/internal/fsutil"
+	"github.com/kukks/claude-rlm/internal/hash"
 	"github.com/kukks/claude-rlm/internal/mcp"
 	"github.com/kukks/claude-rlm/internal/orchestrator"
 	"github.com/kukks/claude-rlm/internal/storage"
@@ -48,7 +55,10 @@ var mcpCmd = &cobra.Command{
 	Short: "Run MCP server (called by Claude)",
 	Long:  `Starts the MCP server on stdio for integration with Claude Desktop or Claude Code CLI.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := runMCPServer(); err != nil {
+		workDir, _ := cmd.Flags().GetString("work-dir")
+		ragDir, _ := cmd.Flags().GetString("rag-dir")
+
+		if err := runMCPServer(workDir, ragDir); err != nil {
 			log.Fatal().Err(err).Msg("MCP server failed")
 		}
 	},
@@ -57,13 +67,20 @@ var mcpCmd = &cobra.Command{
 var analyzeCmd = &cobra.Command{
 	Use:   "analyze [path] [query]",
 	Short: "Analyze a file or directory",
-	Long:  `Directly analyze a file or directory with a given query (for testing).`,
-	Args:  cobra.MinimumNArgs(2),
+	Long: `Directly analyze a file or directory with a given query (for testing).
+
+A path of the form git+https://host/org/repo is shallow-cloned to a temporary
+directory, analyzed and removed afterwards. Add ?ref=<branch or tag> to
+analyze something other than the default branch.`,
+	Args: cobra.MinimumNArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		path := args[0]
 		query := args[1]
+		assumeYes, _ := cmd.Flags().GetBool("yes")
+		assembly, _ := cmd.Flags().GetString("assembly")
+		output, _ := cmd.Flags().GetString("output")
 
-		if err := runAnalyze(path, query); err != nil {
+		if err := runAnalyze(path, query, assumeYes, assembly, output); err != nil {
 			log.Fatal().Err(err).Msg("Analysis failed")
 		}
 	},
@@ -100,17 +117,128 @@ This command will:
 2. Detect Claude Desktop configuration and add RLM
 3. Detect Claude Code CLI configuration and add RLM`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := runInstall(); err != nil {
+		name, _ := cmd.Flags().GetString("name")
+		ragDir, _ := cmd.Flags().GetString("rag-dir")
+		workDir, _ := cmd.Flags().GetString("work-dir")
+		profile, err := newInstallProfile(name, ragDir, workDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := runInstall(profile); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 	},
 }
 
+// Global flags
+var (
+	offlineFlag bool
+	configFlag  string
+	freshFlag   bool
+	presetFlag  string
+)
+
 func init() {
+	rootCmd.PersistentFlags().BoolVar(&offlineFlag, "offline", false, "Disable all network calls (update checks)")
+	rootCmd.PersistentFlags().BoolVar(&freshFlag, "no-cache", false, "Disable the subtask cache and discard saved state for a clean run")
+	rootCmd.PersistentFlags().BoolVar(&freshFlag, "fresh", false, "Alias for --no-cache")
+	rootCmd.PersistentFlags().StringVar(&configFlag, "config", "", "Load this config file instead of searching ~/.config/rlm and the current directory")
+	rootCmd.PersistentFlags().StringVar(&presetFlag, "preset", "", "File pattern preset to hash and analyze: go, web, docs or all (overrides hash.preset)")
+
+	analyzeCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt for large paths")
+	analyzeCmd.Flags().StringP("output", "o", "", "Also write the result content to this file")
+	analyzeCmd.Flags().String("assembly", "", "Directory file order: path, size, readme-first or manifest (overrides orchestrator.assembly)")
+
+	mcpCmd.Flags().String("work-dir", "", "Directory to run in (cache, state and relative paths)")
+	mcpCmd.Flags().String("rag-dir", "", "RAG storage directory (overrides storage.rag_dir)")
+
+	installCmd.Flags().String("name", "", "Profile name; registers the server as rlm-<name> instead of rlm")
+	installCmd.Flags().String("rag-dir", "", "RAG storage directory for this profile")
+	installCmd.Flags().String("work-dir", "", "Working directory for this profile")
+
 	rootCmd.AddCommand(mcpCmd, analyzeCmd, updateCmd, statusCmd, installCmd)
 }
 
+// loadConfig loads configuration and applies global flags. The --config
+// file must load; otherwise a missing or broken config falls back to defaults.
+func loadConfig() (*config.Config, error) {
+	if configFlag != "" {
+		cfg, err := config.LoadFile(configFlag)
+		if err != nil {
+			return nil, err
+		}
+		applyGlobalFlags(cfg)
+		return cfg, nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+	applyGlobalFlags(cfg)
+	return cfg, nil
+}
+
+// hashOptions builds the file hashing options from the loaded config. Every
+// hasher a command creates uses them, so staleness checks see the same
+// files the analysis hashed.
+func hashOptions(cfg *config.Config) hash.Options {
+	opts := hash.Options{
+		WalkWorkers: cfg.Hash.WalkWorkers,
+		// Never hash the tool's own output, whatever the directories are called
+		ExcludePaths: []string{cfg.Storage.RAGDir, orchestrator.CacheDir},
+	}
+	if cfg.Orchestrator.SkipGenerated {
+		opts.Generated = &hash.GeneratedFilter{MaxLineLength: cfg.Orchestrator.GeneratedMaxLineLength}
+	}
+
+	algorithm, err := hash.ParseAlgorithm(cfg.Hash.Algorithm)
+	if err != nil {
+		log.Warn().Err(err).Msg("Ignoring hash.algorithm")
+		algorithm = hash.SHA256
+	}
+	opts.Algorithm = algorithm
+
+	patterns, err := hash.ResolvePatterns(cfg.Hash.Preset, cfg.Hash.Patterns)
+	if err != nil {
+		log.Warn().Err(err).Msg("Ignoring hash.preset and hash.patterns")
+		patterns, _ = hash.ResolvePatterns(hash.PresetAll, nil)
+	}
+	opts.Patterns = patterns
+
+	return opts
+}
+
+// applyGlobalFlags overrides configuration with values from persistent flags
+func applyGlobalFlags(cfg *config.Config) {
+	if offlineFlag {
+		cfg.Offline = true
+	}
+	if freshFlag {
+		cfg.Orchestrator.Fresh = true
+	}
+	if presetFlag != "" {
+		cfg.Hash.Preset = presetFlag
+	}
+}
+
+// errOffline is returned when a command needs the network in offline mode
+var errOffline = errors.New("network access is disabled in offline mode")
+
+// newUpdater creates an updater, or returns errOffline without constructing
+// any network client when offline mode is enabled
+func newUpdater(cfg *config.Config, logger zerolog.Logger) (*updater.Updater, error) {
+	if cfg.Offline {
+		return nil, errOffline
+	}
+	upd := updater.New(Version, logger)
+	upd.SetJitter(cfg.Updater.JitterDuration())
+	return upd, nil
+}
+
 func setupLogger(cfg *config.Config) zerolog.Logger {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
@@ -136,36 +264,152 @@ func setupLogger(cfg *config.Config) zerolog.Logger {
 	return logger
 }
 
-func runMCPServer() error {
+// newOrchestratorConfig builds the orchestrator configuration from the loaded config
+func newOrchestratorConfig(cfg *config.Config) (*orchestrator.Config, error) {
+	orchConfig := &orchestrator.Config{
+		MaxRecursionDepth:  cfg.Orchestrator.MaxRecursionDepth,
+		MaxIterations:      cfg.Orchestrator.MaxIterations,
+		MaxChildrenPerTask: cfg.Orchestrator.MaxChildrenPerTask,
+		CacheEnabled:       cfg.Orchestrator.CacheEnabled,
+		CacheTTL:           cfg.Orchestrator.CacheTTL(),
+		CacheTTLPerUSD:     cfg.Orchestrator.CacheTTLPerUSD,
+		CacheVersion:       cfg.Orchestrator.CacheVersion,
+		WorkDir:            ".",
+		Fresh:              cfg.Orchestrator.Fresh,
+		MaxDuration:        cfg.Orchestrator.MaxDurationValue(),
+		TraceStore:         cfg.Orchestrator.TraceStore,
+		InitialAgentType:   cfg.Orchestrator.InitialAgentType,
+		SeedContext:        cfg.Orchestrator.SeedContext,
+		MaxResultBytes:     cfg.Orchestrator.MaxResultBytes,
+		RejectOversized:    cfg.Orchestrator.RejectOversizedResults,
+		Fsync:              cfg.Orchestrator.Fsync,
+		DedupResults:       cfg.Orchestrator.DedupResults,
+		Hashing:            hashOptions(cfg),
+	}
+
+	// A fresh run must not reuse results from earlier runs
+	if cfg.Orchestrator.Fresh {
+		orchConfig.CacheEnabled = false
+	}
+
+	if cfg.Orchestrator.FailureDump {
+		orchConfig.FailureDumpDir = cfg.Storage.RAGDir
+	}
+
+	if schedule := cfg.Orchestrator.DepthSchedule; schedule.Enabled {
+		orchConfig.DepthSchedule = &orchestrator.DepthSchedule{
+			MinDepth:      schedule.MinDepth,
+			MinIterations: schedule.MinIterations,
+			FullScale:     schedule.FullScaleFiles,
+		}
+	}
+
+	assembler, err := orchestrator.NewAssembler(cfg.Orchestrator.Assembly, cfg.Orchestrator.AssemblyManifest)
+	if err != nil {
+		log.Warn().Err(err).Msg("Ignoring orchestrator.assembly")
+	}
+	orchConfig.Assembler = assembler
+
+	if err := orchestrator.CheckBinaryPolicy(cfg.Orchestrator.BinaryFiles); err != nil {
+		log.Warn().Err(err).Msg("Ignoring orchestrator.binary_files")
+	} else {
+		orchConfig.BinaryFiles = cfg.Orchestrator.BinaryFiles
+	}
+
+	if len(cfg.Orchestrator.Prompts) > 0 {
+		prompts, err := orchestrator.NewPromptTemplates(cfg.Orchestrator.Prompts)
+		if err != nil {
+			return nil, fmt.Errorf("orchestrator.prompts: %w", err)
+		}
+		orchConfig.Prompts = prompts
+	}
+
+	processors, err := orchestrator.NewResultProcessors(cfg.Orchestrator.Processors)
+	if err != nil {
+		return nil, fmt.Errorf("orchestrator.processors: %w", err)
+	}
+	orchConfig.Processors = processors
+
+	return orchConfig, nil
+}
+
+// newStorageConfig builds the storage backend configuration from the loaded config
+func newStorageConfig(cfg *config.Config) *storage.Config {
+	tokenizer := storage.NewTokenizer(cfg.Storage.Tokenizer.MinLength, cfg.Storage.Tokenizer.Stopwords)
+	tokenizer.SetCaseSensitive(cfg.Storage.Tokenizer.CaseSensitive)
+	tokenizer.SetFoldAccents(cfg.Storage.Tokenizer.FoldAccents)
+	tokenizer.SetBigrams(cfg.Storage.Tokenizer.Bigrams)
+
+	var parallel *storage.ParallelSearch
+	if cfg.Storage.ParallelSearch.Enabled {
+		parallel = &storage.ParallelSearch{
+			Timeout:    cfg.Storage.ParallelSearch.TimeoutDuration(),
+			MinResults: cfg.Storage.ParallelSearch.MinResults,
+		}
+	}
+
+	var signingKey []byte
+	if cfg.Storage.SigningKey != "" {
+		signingKey = []byte(cfg.Storage.SigningKey)
+	}
+
+	return &storage.Config{
+		RAGDir:      cfg.Storage.RAGDir,
+		AnalysisTTL: cfg.Storage.AnalysisTTLDuration(),
+		Tokenizer:   tokenizer,
+
+		DedupScoreGap: cfg.Storage.DedupScoreGap,
+		MaxPerPath:    cfg.Storage.MaxPerPath,
+		Compress:      cfg.Storage.Compress,
+		IndexFields:   cfg.Storage.IndexFields,
+
+		DeterministicIDs: cfg.Storage.DeterministicIDs,
+		CompactJSON:      !cfg.Storage.PrettyJSON,
+		RewriteMigrated:  cfg.Storage.RewriteMigrated,
+		RecencyHalfLife:  cfg.Storage.RecencyHalfLifeDuration(),
+		Backends:         cfg.Storage.Backends,
+		ParallelSearch:   parallel,
+		Fsync:            cfg.Storage.Fsync,
+		OpTimeout:        cfg.Storage.OpTimeoutDuration(),
+		SigningKey:       signingKey,
+
+		DeltaEncoding:      cfg.Storage.DeltaEncoding,
+		DeltaSnapshotEvery: cfg.Storage.DeltaSnapshotEvery,
+	}
+}
+
+func runMCPServer(workDir, ragDir string) error {
 	ctx := context.Background()
 
+	// Switch to the profile's working directory before resolving any paths
+	if workDir != "" {
+		if err := os.Chdir(workDir); err != nil {
+			return fmt.Errorf("failed to change to work dir: %w", err)
+		}
+	}
+
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
-		cfg = config.DefaultConfig()
+		return err
+	}
+	if ragDir != "" {
+		cfg.Storage.RAGDir = ragDir
 	}
 
 	// Setup logger
 	logger := setupLogger(cfg)
 
 	// Create orchestrator
-	orchConfig := &orchestrator.Config{
-		MaxRecursionDepth: cfg.Orchestrator.MaxRecursionDepth,
-		MaxIterations:     cfg.Orchestrator.MaxIterations,
-		CacheEnabled:      cfg.Orchestrator.CacheEnabled,
-		CacheTTL:          cfg.Orchestrator.CacheTTL(),
-		WorkDir:           ".",
+	orchConfig, err := newOrchestratorConfig(cfg)
+	if err != nil {
+		return err
 	}
-
 	orch := orchestrator.New(orchConfig, logger)
 	orch.SetDispatcher(orchestrator.PlaceholderDispatcher)
 
 	// Create storage backend
-	storageConfig := &storage.Config{
-		RAGDir: cfg.Storage.RAGDir,
-	}
-
-	backend, err := storage.NewBackend(ctx, storageConfig)
+	backend, err := storage.NewBackend(ctx, newStorageConfig(cfg))
 	if err != nil {
 		return fmt.Errorf("failed to create storage backend: %w", err)
 	}
@@ -173,52 +417,149 @@ func runMCPServer() error {
 
 	logger.Info().Str("storage", backend.Name()).Msg("Storage backend initialized")
 
+	// Prune expired analyses on startup if configured
+	if cfg.Storage.PruneOnStartup {
+		pruned, err := backend.PruneExpired(ctx)
+		if err != nil {
+			logger.Warn().Err(err).Msg("Failed to prune expired analyses")
+		} else if pruned > 0 {
+			logger.Info().Int("pruned", pruned).Msg("Pruned expired analyses")
+		}
+	}
+
 	// Create MCP server
 	server := mcp.NewServer(orch, backend, logger, Version)
 	defer server.Close()
 
+	server.SetReadOnly(cfg.MCP.ReadOnly)
+	server.SetMaxConcurrentAnalyses(cfg.MCP.MaxConcurrentAnalyses, cfg.MCP.QueueAnalyses)
+	if len(cfg.MCP.RateLimits) > 0 {
+		limits := make(map[string]mcp.RateLimit, len(cfg.MCP.RateLimits))
+		for tool, limit := range cfg.MCP.RateLimits {
+			limits[tool] = mcp.RateLimit{PerMinute: limit.PerMinute, Burst: limit.Burst}
+		}
+		server.SetRateLimiter(mcp.NewRateLimiter(limits, nil))
+	}
+	server.SetSizeLimits(cfg.Orchestrator.MaxFiles, cfg.Orchestrator.MaxBytes)
+	server.SetPackageRootMarkers(cfg.MCP.PackageRootMarkers)
+	server.SetQuickScan(cfg.MCP.QuickMaxFiles, cfg.MCP.QuickMaxDepth)
+	if len(cfg.MCP.AllowedRoots) > 0 {
+		server.SetAllowedRoots(cfg.MCP.AllowedRoots)
+	}
+	if len(cfg.MCP.Focuses) > 0 {
+		server.SetFocusVocabulary(mcp.NewFocusVocabulary(cfg.MCP.Focuses, cfg.MCP.StrictFocus))
+	}
+	if window := cfg.MCP.IdempotencyWindowDuration(); window > 0 {
+		store, err := mcp.NewIdempotencyStore(cfg.Storage.RAGDir, window, nil)
+		if err != nil {
+			logger.Warn().Err(err).Msg("Idempotency keys disabled")
+		} else {
+			server.SetIdempotencyStore(store)
+		}
+	}
+	if cfg.Logging.Redact.Enabled {
+		server.SetRedactor(mcp.NewRedactor(cfg.Logging.Redact.MaxLength, cfg.Logging.Redact.Fields))
+	}
+	if cfg.MCP.Queue.Enabled {
+		queue, err := mcp.NewJobQueue(cfg.Storage.RAGDir, mcp.JobRetention{
+			MaxAge:      cfg.MCP.Queue.RetentionDuration(),
+			MaxFinished: cfg.MCP.Queue.MaxFinished,
+		})
+		if err != nil {
+			logger.Warn().Err(err).Msg("Job queue disabled")
+		} else {
+			server.SetJobQueue(queue)
+			go server.RunJobs(ctx, cfg.MCP.Queue.Workers)
+		}
+	}
+	if export := cfg.MCP.MetricsExport; export.Enabled {
+		path := export.Path
+		if path == "" {
+			path = filepath.Join(cfg.Storage.RAGDir, mcp.MetricsFileName)
+		}
+		if interval := export.IntervalDuration(); interval > 0 {
+			go server.ExportMetrics(ctx, path, interval)
+		} else {
+			logger.Warn().Str("interval", export.Interval).Msg("Metrics export disabled: invalid interval")
+		}
+	}
+
 	// Check for updates on startup (non-blocking)
-	if cfg.Updater.Enabled {
-		go checkForUpdates(ctx, logger)
+	if cfg.Updater.Enabled && !cfg.Offline {
+		go checkForUpdates(ctx, cfg, logger)
 	}
 
 	// Run MCP server
 	return server.RunStdio(ctx)
 }
 
-func runAnalyze(path, query string) error {
+func runAnalyze(path, query string, assumeYes bool, assembly, output string) error {
 	ctx := context.Background()
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
-		cfg = config.DefaultConfig()
+		return err
 	}
 
 	// Setup logger
 	logger := setupLogger(cfg)
 
-	// Create orchestrator
-	orchConfig := &orchestrator.Config{
-		MaxRecursionDepth: cfg.Orchestrator.MaxRecursionDepth,
-		MaxIterations:     cfg.Orchestrator.MaxIterations,
-		CacheEnabled:      cfg.Orchestrator.CacheEnabled,
-		CacheTTL:          cfg.Orchestrator.CacheTTL(),
-		WorkDir:           ".",
+	// Clone remote repositories to a temporary directory
+	remote := isGitRemote(path)
+	if remote {
+		dir, cleanup, err := cloneGitRemote(ctx, path)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		path = dir
+	}
+
+	// Guard against accidentally analyzing a huge tree
+	if !assumeYes {
+		if err := confirmTreeSize(path, cfg, os.Stdin, os.Stdout); err != nil {
+			return err
+		}
 	}
 
+	// Create orchestrator
+	orchConfig, err := newOrchestratorConfig(cfg)
+	if err != nil {
+		return err
+	}
 	orch := orchestrator.New(orchConfig, logger)
 	orch.SetDispatcher(orchestrator.PlaceholderDispatcher)
 
-	// Run analysis
+	if assembly != "" {
+		assembler, err := orchestrator.NewAssembler(assembly, cfg.Orchestrator.AssemblyManifest)
+		if err != nil {
+			return err
+		}
+		orch.SetAssembler(assembler)
+	}
+
+	// Run analysis. Running out of time still reports the work done so far.
 	result, err := orch.AnalyzeDocument(ctx, path, query)
-	if err != nil {
+	partial := errors.Is(err, orchestrator.ErrTimeBudgetExceeded) && result != nil
+	if err != nil && !partial {
 		return err
 	}
 
+	if output != "" && !partial {
+		if err := orchestrator.WriteOutputFile(output, result.Content); err != nil {
+			return err
+		}
+	}
+
 	// Display result
-	fmt.Println("Analysis Result:")
-	fmt.Println("================")
+	if partial {
+		fmt.Println("Partial Analysis Result:")
+		fmt.Println("========================")
+	} else {
+		fmt.Println("Analysis Result:")
+		fmt.Println("================")
+	}
 	fmt.Println(result.Content)
 	fmt.Println()
 	fmt.Printf("Token Count: %d\n", result.TokenCount)
@@ -232,15 +573,70 @@ func runAnalyze(path, query string) error {
 	fmt.Printf("  Total Cost: $%.4f\n", stats.TotalCostUSD)
 	fmt.Printf("  Max Depth: %d\n", stats.MaxDepthReached)
 	fmt.Printf("  Cache Hits: %d\n", stats.CacheHits)
+	fmt.Printf("  Cache Savings: $%.4f\n", stats.CacheSavingsUSD)
+
+	if len(stats.ByAgent) > 0 {
+		agentTypes := make([]string, 0, len(stats.ByAgent))
+		for agentType := range stats.ByAgent {
+			agentTypes = append(agentTypes, agentType)
+		}
+		sort.Strings(agentTypes)
 
+		fmt.Println()
+		fmt.Println("By Agent:")
+		for _, agentType := range agentTypes {
+			agent := stats.ByAgent[agentType]
+			fmt.Printf("  %s: %d calls, %d tokens, $%.4f\n", agentType, agent.Calls, agent.Tokens, agent.CostUSD)
+		}
+	}
+
+	if partial {
+		fmt.Println()
+		if remote {
+			// Each run clones to a new directory, so there is nothing to resume
+			fmt.Println("The analysis stopped at its time budget. Raise orchestrator.max_duration to analyze this repository in one run.")
+		} else {
+			fmt.Println("The analysis stopped at its time budget. Re-run the same command to resume it.")
+		}
+		return err
+	}
 	return nil
 }
 
+// confirmTreeSize checks path against the configured size limits and, if it
+// exceeds them, asks the user whether to continue
+func confirmTreeSize(path string, cfg *config.Config, in io.Reader, out io.Writer) error {
+	_, err := hash.NewFileHasher(hashOptions(cfg)).CheckTreeSize(path, cfg.Orchestrator.MaxFiles, cfg.Orchestrator.MaxBytes)
+	var tooLarge *hash.TreeTooLargeError
+	if !errors.As(err, &tooLarge) {
+		return err
+	}
+
+	fmt.Fprintf(out, "Warning: %v\n", tooLarge)
+	fmt.Fprint(out, "Continue anyway? [y/N]: ")
+
+	var response string
+	fmt.Fscanln(in, &response)
+
+	if response == "y" || response == "Y" {
+		return nil
+	}
+	return errors.New("analysis cancelled")
+}
+
 func runUpdate() error {
 	ctx := context.Background()
 	logger := log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
-	upd := updater.New(Version, logger)
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	upd, err := newUpdater(cfg, logger)
+	if err != nil {
+		return err
+	}
 
 	fmt.Println("Checking for updates...")
 
@@ -263,6 +659,14 @@ func runUpdate() error {
 	if response == "" || response == "y" || response == "Y" {
 		fmt.Println("Downloading and installing update...")
 		if err := upd.Update(ctx, release); err != nil {
+			var noAsset *updater.NoAssetForPlatformError
+			if errors.As(err, &noAsset) {
+				fmt.Printf("No prebuilt binary for %s/%s in %s.\n", noAsset.OS, noAsset.Arch, *release.TagName)
+				if len(noAsset.Available) > 0 {
+					fmt.Println("Available assets:", strings.Join(noAsset.Available, ", "))
+				}
+				fmt.Printf("Build from source instead: go install github.com/kukks/claude-rlm/cmd/rlm@%s\n", *release.TagName)
+			}
 			return err
 		}
 		fmt.Println("Update successful! Restart RLM to use the new version.")
@@ -279,23 +683,55 @@ func showStatus() {
 	fmt.Printf("Git Commit: %s\n", GitCommit)
 	fmt.Println()
 
-	cfg, err := config.Load()
-	if err != nil {
+	var cfg *config.Config
+	var err error
+	if configFlag != "" {
+		cfg, err = config.LoadFile(configFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Config: Loaded from %s\n", configFlag)
+	} else if cfg, err = config.Load(); err != nil {
 		fmt.Println("Config: Using defaults (no config file found)")
+		cfg = config.DefaultConfig()
 	} else {
 		fmt.Println("Config: Loaded from file")
 	}
+	applyGlobalFlags(cfg)
 
 	fmt.Println()
 	fmt.Println("Configuration:")
 	fmt.Printf("  Max Recursion Depth: %d\n", cfg.Orchestrator.MaxRecursionDepth)
 	fmt.Printf("  Cache Enabled: %v\n", cfg.Orchestrator.CacheEnabled)
-	fmt.Printf("  Storage Backend: BM25 (pure Go)\n")
+	fmt.Printf("  Storage Backend: %s\n", storageStatus(context.Background(), cfg))
 	fmt.Printf("  RAG Directory: %s\n", cfg.Storage.RAGDir)
+	fmt.Printf("  Offline: %v\n", cfg.Offline)
 }
 
-func checkForUpdates(ctx context.Context, logger zerolog.Logger) {
-	upd := updater.New(Version, logger)
+// storageStatus opens the configured storage backend and reports the one
+// actually in use, or why none could be opened
+func storageStatus(ctx context.Context, cfg *config.Config) string {
+	backend, err := storage.NewBackend(ctx, newStorageConfig(cfg))
+	if err != nil {
+		return fmt.Sprintf("unavailable (%v)", err)
+	}
+	defer backend.Close()
+	return backend.Name()
+}
+
+func checkForUpdates(ctx context.Context, cfg *config.Config, logger zerolog.Logger) {
+	upd, err := newUpdater(cfg, logger)
+	if err != nil {
+		return
+	}
+
+	// Spread startup checks across a fleet started together
+	select {
+	case <-time.After(upd.InitialDelay()):
+	case <-ctx.Done():
+		return
+	}
 
 	release, hasUpdate, err := upd.CheckForUpdate(ctx)
 	if err != nil {
@@ -308,8 +744,57 @@ func checkForUpdates(ctx context.Context, logger zerolog.Logger) {
 	}
 }
 
+// installProfile describes the MCP server entry registered by install
+type installProfile struct {
+	Name    string // Empty registers the default "rlm" entry
+	RAGDir  string
+	WorkDir string
+}
+
+// newInstallProfile builds a profile from the install flags. Directories are
+// made absolute, since Claude starts the server from a directory of its
+// choosing rather than the one install ran in.
+func newInstallProfile(name, ragDir, workDir string) (installProfile, error) {
+	profile := installProfile{Name: name}
+	if ragDir != "" {
+		abs, err := filepath.Abs(ragDir)
+		if err != nil {
+			return installProfile{}, fmt.Errorf("--rag-dir: %w", err)
+		}
+		profile.RAGDir = abs
+	}
+	if workDir != "" {
+		abs, err := filepath.Abs(workDir)
+		if err != nil {
+			return installProfile{}, fmt.Errorf("--work-dir: %w", err)
+		}
+		profile.WorkDir = abs
+	}
+	return profile, nil
+}
+
+// serverName returns the mcpServers key for this profile
+func (p installProfile) serverName() string {
+	if p.Name == "" {
+		return "rlm"
+	}
+	return "rlm-" + p.Name
+}
+
+// args returns the arguments Claude passes to the binary for this profile
+func (p installProfile) args() []string {
+	args := []string{"mcp"}
+	if p.WorkDir != "" {
+		args = append(args, "--work-dir", p.WorkDir)
+	}
+	if p.RAGDir != "" {
+		args = append(args, "--rag-dir", p.RAGDir)
+	}
+	return args
+}
+
 // runInstall handles the install command
-func runInstall() error {
+func runInstall(profile installProfile) error {
 	fmt.Println("RLM Installer")
 	fmt.Println("=============")
 	fmt.Println()
@@ -322,21 +807,21 @@ func runInstall() error {
 	fmt.Printf("✓ Binary installed to: %s\n", binaryPath)
 
 	// Step 2: Configure Claude Desktop
-	desktopConfigured, err := configureClaudeDesktop(binaryPath)
+	desktopConfigured, err := configureClaudeDesktop(binaryPath, profile)
 	if err != nil {
 		fmt.Printf("⚠ Claude Desktop configuration failed: %v\n", err)
 	} else if desktopConfigured {
-		fmt.Println("✓ Claude Desktop configured")
+		fmt.Printf("✓ Claude Desktop configured (server: %s)\n", profile.serverName())
 	} else {
 		fmt.Println("- Claude Desktop not found (skipped)")
 	}
 
 	// Step 3: Configure Claude Code CLI
-	codeConfigured, err := configureClaudeCode(binaryPath)
+	codeConfigured, err := configureClaudeCode(binaryPath, profile)
 	if err != nil {
 		fmt.Printf("⚠ Claude Code configuration failed: %v\n", err)
 	} else if codeConfigured {
-		fmt.Println("✓ Claude Code CLI configured")
+		fmt.Printf("✓ Claude Code CLI configured (server: %s)\n", profile.serverName())
 	} else {
 		fmt.Println("- Claude Code CLI not found (skipped)")
 	}
@@ -470,7 +955,7 @@ func addToWindowsPath(dir string) {
 }
 
 // configureClaudeDesktop adds RLM to Claude Desktop configuration
-func configureClaudeDesktop(binaryPath string) (bool, error) {
+func configureClaudeDesktop(binaryPath string, profile installProfile) (bool, error) {
 	configPath := getClaudeDesktopConfigPath()
 	if configPath == "" {
 		return false, nil
@@ -482,11 +967,11 @@ func configureClaudeDesktop(binaryPath string) (bool, error) {
 		return false, nil // Claude Desktop not installed
 	}
 
-	return addMCPServerToConfig(configPath, binaryPath)
+	return addMCPServerToConfig(configPath, binaryPath, profile)
 }
 
 // configureClaudeCode adds RLM to Claude Code CLI configuration
-func configureClaudeCode(binaryPath string) (bool, error) {
+func configureClaudeCode(binaryPath string, profile installProfile) (bool, error) {
 	configPath := getClaudeCodeConfigPath()
 	if configPath == "" {
 		return false, nil
@@ -505,7 +990,7 @@ func configureClaudeCode(binaryPath string) (bool, error) {
 		}
 	}
 
-	return addMCPServerToConfig(configPath, binaryPath)
+	return addMCPServerToConfig(configPath, binaryPath, profile)
 }
 
 // getClaudeDesktopConfigPath returns the Claude Desktop config path for the current OS
@@ -565,46 +1050,98 @@ func isClaudeCodeInstalled() bool {
 	return false
 }
 
-// addMCPServerToConfig adds or updates the RLM MCP server in a config file
-func addMCPServerToConfig(configPath, binaryPath string) (bool, error) {
-	// Read existing config or create new one
-	var config map[string]interface{}
+// addMCPServerToConfig adds or updates the profile's RLM MCP server in a
+// config file. The merge is additive: other keys and servers keep their
+// values and order, re-running with the same entry leaves the file untouched,
+// and a timestamped backup is written before any modification.
+func addMCPServerToConfig(configPath, binaryPath string, profile installProfile) (bool, error) {
+	// Rewrite a symlinked config in place rather than replacing the link
+	if resolved, err := filepath.EvalSymlinks(configPath); err == nil {
+		configPath = resolved
+	}
+
+	// Read existing config or create new one, keeping its permissions:
+	// it may hold other servers' credentials
+	config := newOrderedObject()
+	mode := os.FileMode(0644)
 
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			config = make(map[string]interface{})
-		} else {
+		if !os.IsNotExist(err) {
 			return false, err
 		}
+		data = nil
 	} else {
-		if err := json.Unmarshal(data, &config); err != nil {
+		if info, err := os.Stat(configPath); err == nil {
+			mode = info.Mode().Perm()
+		}
+
+		// Never write over a config we couldn't understand
+		if err := json.Unmarshal(data, config); err != nil {
 			return false, fmt.Errorf("failed to parse config: %w", err)
 		}
 	}
 
 	// Get or create mcpServers section
-	mcpServers, ok := config["mcpServers"].(map[string]interface{})
-	if !ok {
-		mcpServers = make(map[string]interface{})
-		config["mcpServers"] = mcpServers
+	mcpServers := newOrderedObject()
+	if raw, ok := config.Get("mcpServers"); ok {
+		if err := json.Unmarshal(raw, mcpServers); err != nil {
+			return false, fmt.Errorf("failed to parse mcpServers: %w", err)
+		}
 	}
 
-	// Add RLM server
-	mcpServers["rlm"] = map[string]interface{}{
+	// Build RLM server entry
+	entry, err := json.Marshal(map[string]interface{}{
 		"command": binaryPath,
-		"args":    []string{"mcp"},
+		"args":    profile.args(),
+	})
+	if err != nil {
+		return false, err
 	}
 
+	// Nothing to do if the entry is already up to date
+	if existing, ok := mcpServers.Get(profile.serverName()); ok && jsonEqual(existing, entry) {
+		return true, nil
+	}
+
+	mcpServers.Set(profile.serverName(), entry)
+	serversJSON, err := json.Marshal(mcpServers)
+	if err != nil {
+		return false, err
+	}
+	config.Set("mcpServers", serversJSON)
+
 	// Write config back
-	newData, err := json.MarshalIndent(config, "", "  ")
+	compact, err := json.Marshal(config)
 	if err != nil {
 		return false, err
 	}
+	var newData bytes.Buffer
+	if err := json.Indent(&newData, compact, "", "  "); err != nil {
+		return false, err
+	}
 
-	if err := os.WriteFile(configPath, newData, 0644); err != nil {
+	// Back up the original before touching it
+	if data != nil {
+		backupPath := fmt.Sprintf("%s.%s.bak", configPath, time.Now().Format("20060102-150405"))
+		if err := fsutil.WriteFile(backupPath, data, mode, true); err != nil {
+			return false, fmt.Errorf("failed to back up config: %w", err)
+		}
+	}
+
+	// Atomic, so an interrupted install never leaves a truncated config
+	if err := fsutil.WriteFile(configPath, newData.Bytes(), mode, true); err != nil {
 		return false, err
 	}
 
 	return true, nil
 }
+
+// jsonEqual reports whether two JSON documents are semantically equal
+func jsonEqual(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kukks/claude-rlm/internal/config"
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+	"github.com/kukks/claude-rlm/internal/storage"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOfflineModeSkipsUpdater(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Offline = true
+
+	upd, err := newUpdater(cfg, zerolog.Nop())
+	assert.Nil(t, upd)
+	assert.ErrorIs(t, err, errOffline)
+
+	cfg.Offline = false
+	upd, err = newUpdater(cfg, zerolog.Nop())
+	assert.NoError(t, err)
+	assert.NotNil(t, upd)
+}
+
+func TestOfflineFlagOverridesConfig(t *testing.T) {
+	offlineFlag = true
+	t.Cleanup(func() { offlineFlag = false })
+
+	cfg := config.DefaultConfig()
+	applyGlobalFlags(cfg)
+	assert.True(t, cfg.Offline)
+}
+
+func TestConfigFlagLoadsExplicitFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rlm-test.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+orchestrator:
+  max_recursion_depth: 3
+storage:
+  rag_dir: /tmp/rlm-explicit
+mcp:
+  read_only: true
+`), 0644))
+
+	configFlag = path
+	t.Cleanup(func() { configFlag = "" })
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.Orchestrator.MaxRecursionDepth)
+	assert.Equal(t, "/tmp/rlm-explicit", cfg.Storage.RAGDir)
+	assert.True(t, cfg.MCP.ReadOnly)
+	// Unset values keep their defaults
+	assert.Equal(t, config.DefaultConfig().Orchestrator.MaxIterations, cfg.Orchestrator.MaxIterations)
+
+	// A missing or unparseable file is an error rather than silent defaults
+	configFlag = filepath.Join(t.TempDir(), "missing.yaml")
+	_, err = loadConfig()
+	assert.Error(t, err)
+
+	broken := filepath.Join(t.TempDir(), "broken.yaml")
+	require.NoError(t, os.WriteFile(broken, []byte("orchestrator: [unclosed"), 0644))
+	configFlag = broken
+	_, err = loadConfig()
+	assert.Error(t, err)
+}
+
+func TestFreshFlagIgnoresCacheAndState(t *testing.T) {
+	workDir := t.TempDir()
+
+	var dispatched []string
+	dispatcher := func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		dispatched = append(dispatched, task.AgentType)
+		return orchestrator.PlaceholderDispatcher(ctx, task)
+	}
+	newOrchestrator := func() *orchestrator.Orchestrator {
+		cfg, err := loadConfig()
+		require.NoError(t, err)
+		orchConfig, err := newOrchestratorConfig(cfg)
+		require.NoError(t, err)
+		orchConfig.WorkDir = workDir
+		orch := orchestrator.New(orchConfig, zerolog.Nop())
+		orch.SetDispatcher(dispatcher)
+		return orch
+	}
+
+	// Populate the cache with a normal run
+	_, err := newOrchestrator().AnalyzeDocument(context.Background(), "test.txt", "q")
+	require.NoError(t, err)
+	require.Equal(t, []string{"Explorer"}, dispatched)
+
+	// Leave behind state from an interrupted run that would resume a worker
+	state := orchestrator.State{
+		Stack:       []orchestrator.Task{{AgentType: "Explorer", TaskDescription: "q"}},
+		CurrentTask: orchestrator.Task{AgentType: "Worker", TaskDescription: "stale", Depth: 1},
+	}
+	data, err := json.Marshal(state)
+	require.NoError(t, err)
+	statePath := filepath.Join(workDir, orchestrator.StateFileName)
+	require.NoError(t, os.WriteFile(statePath, data, 0644))
+
+	freshFlag = true
+	t.Cleanup(func() { freshFlag = false })
+
+	dispatched = nil
+	orch := newOrchestrator()
+	_, err = orch.AnalyzeDocument(context.Background(), "test.txt", "q")
+	require.NoError(t, err)
+
+	// The root was dispatched again rather than served from the cache or
+	// resumed from the stale worker
+	assert.Equal(t, []string{"Explorer"}, dispatched)
+	assert.Equal(t, 0, orch.GetStats().CacheHits)
+	assert.NoFileExists(t, statePath)
+}
+
+func TestConfirmTreeSize(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("package x"), 0644))
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Orchestrator.MaxFiles = 1
+
+	var out bytes.Buffer
+	err := confirmTreeSize(dir, cfg, strings.NewReader("n\n"), &out)
+	assert.Error(t, err)
+	assert.Contains(t, out.String(), "2 matching files")
+
+	assert.NoError(t, confirmTreeSize(dir, cfg, strings.NewReader("y\n"), &out))
+
+	// Within limits: no prompt
+	cfg.Orchestrator.MaxFiles = 2
+	out.Reset()
+	assert.NoError(t, confirmTreeSize(dir, cfg, strings.NewReader(""), &out))
+	assert.Empty(t, out.String())
+}
+
+func TestInstallProfiles(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "claude.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"mcpServers":{"other":{"command":"other-server"}}}`), 0644))
+
+	work := installProfile{Name: "work", RAGDir: "/data/work-rag", WorkDir: "/src/work"}
+	personal := installProfile{Name: "personal", RAGDir: "/data/personal-rag"}
+
+	for _, profile := range []installProfile{work, personal} {
+		configured, err := addMCPServerToConfig(configPath, "/usr/local/bin/rlm", profile)
+		require.NoError(t, err)
+		assert.True(t, configured)
+	}
+
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	var cfg struct {
+		MCPServers map[string]struct {
+			Command string   `json:"command"`
+			Args    []string `json:"args"`
+		} `json:"mcpServers"`
+	}
+	require.NoError(t, json.Unmarshal(data, &cfg))
+
+	assert.Contains(t, cfg.MCPServers, "other")
+	assert.Equal(t, []string{"mcp", "--work-dir", "/src/work", "--rag-dir", "/data/work-rag"}, cfg.MCPServers["rlm-work"].Args)
+	assert.Equal(t, []string{"mcp", "--rag-dir", "/data/personal-rag"}, cfg.MCPServers["rlm-personal"].Args)
+	assert.Equal(t, "rlm", installProfile{}.serverName())
+}
+
+func TestInstallProfileDirsAreAbsolute(t *testing.T) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	// Relative flags resolve against where install ran, not where Claude
+	// later starts the server
+	profile, err := newInstallProfile("work", ".rlm-work", "src")
+	require.NoError(t, err)
+	args := profile.args()
+	assert.Equal(t, []string{"mcp", "--work-dir", filepath.Join(cwd, "src"), "--rag-dir", filepath.Join(cwd, ".rlm-work")}, args)
+	for _, arg := range args[1:] {
+		if !strings.HasPrefix(arg, "--") {
+			assert.True(t, filepath.IsAbs(arg), arg)
+		}
+	}
+
+	profile, err = newInstallProfile("", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mcp"}, profile.args())
+}
+
+func TestAddMCPServerPreservesConfigAndBacksUp(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "claude.json")
+	original := `{
+  "theme": "dark",
+  "mcpServers": {
+    "zeta": {"command": "zeta"},
+    "alpha": {"command": "alpha", "args": ["serve"]}
+  },
+  "numStartups": 42
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(original), 0644))
+
+	_, err := addMCPServerToConfig(configPath, "/usr/local/bin/rlm", installProfile{})
+	require.NoError(t, err)
+
+	// A backup of the original is written
+	backups, err := filepath.Glob(configPath + ".*.bak")
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	backup, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, original, string(backup))
+
+	// Other servers and keys are preserved in their original order
+	updated, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	text := string(updated)
+	assert.Less(t, strings.Index(text, `"theme"`), strings.Index(text, `"mcpServers"`))
+	assert.Less(t, strings.Index(text, `"mcpServers"`), strings.Index(text, `"numStartups"`))
+	assert.Less(t, strings.Index(text, `"zeta"`), strings.Index(text, `"alpha"`))
+	assert.Less(t, strings.Index(text, `"alpha"`), strings.Index(text, `"rlm"`))
+	assert.Contains(t, text, `"serve"`)
+
+	// Re-running is a no-op
+	_, err = addMCPServerToConfig(configPath, "/usr/local/bin/rlm", installProfile{})
+	require.NoError(t, err)
+	again, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, text, string(again))
+	backups, err = filepath.Glob(configPath + ".*.bak")
+	require.NoError(t, err)
+	assert.Len(t, backups, 1)
+}
+
+func TestAddMCPServerKeepsConfigPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no Unix permission bits")
+	}
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "claude.json")
+	require.NoError(t, os.WriteFile(target, []byte(`{"mcpServers": {"other": {"env": {"TOKEN": "secret"}}}}`), 0600))
+	link := filepath.Join(dir, "link.json")
+	require.NoError(t, os.Symlink(target, link))
+
+	_, err := addMCPServerToConfig(link, "/usr/local/bin/rlm", installProfile{})
+	require.NoError(t, err)
+
+	// The config and its backup stay private, and the link still points at it
+	info, err := os.Stat(target)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	backups, err := filepath.Glob(target + ".*.bak")
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	info, err = os.Stat(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	info, err = os.Lstat(link)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink)
+	data, err := os.ReadFile(link)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"rlm"`)
+}
+
+func TestAddMCPServerAbortsOnInvalidConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "claude.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"mcpServers": {`), 0644))
+
+	_, err := addMCPServerToConfig(configPath, "/usr/local/bin/rlm", installProfile{})
+	assert.Error(t, err)
+
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, `{"mcpServers": {`, string(data))
+}
+
+func TestStreamOutputIsJSONLines(t *testing.T) {
+	ctx := context.Background()
+	backend, err := storage.NewBM25Backend(storage.DefaultConfig(t.TempDir()))
+	require.NoError(t, err)
+	defer backend.Close()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+			Query:  fmt.Sprintf("caching layer review %d", i),
+			Result: map[string]interface{}{"content": "multi\nline findings"},
+			Path:   "src",
+		}))
+	}
+	for i := 0; i < 5; i++ {
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+			Query:  fmt.Sprintf("unrelated topic %d", i),
+			Result: map[string]interface{}{"content": "other"},
+			Path:   "docs",
+		}))
+	}
+
+	decodeLines := func(out *bytes.Buffer) []map[string]interface{} {
+		var objects []map[string]interface{}
+		scanner := bufio.NewScanner(out)
+		for scanner.Scan() {
+			var obj map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &obj), "line: %s", scanner.Text())
+			objects = append(objects, obj)
+		}
+		require.NoError(t, scanner.Err())
+		return objects
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, writeAnalyses(ctx, backend, time.Time{}, true, &out))
+	assert.Len(t, decodeLines(&out), 8)
+
+	out.Reset()
+	enc := json.NewEncoder(&out)
+	require.NoError(t, searchStream(ctx, backend, "caching", searchFlags{max: 10}, func(hit searchHit) error {
+		return enc.Encode(hit)
+	}))
+	results := decodeLines(&out)
+	assert.Len(t, results, 3)
+	for _, result := range results {
+		assert.Equal(t, "bm25", result["search_method"])
+	}
+}
+
+func TestSearchCommand(t *testing.T) {
+	ctx := context.Background()
+	backend, err := storage.NewBM25Backend(storage.DefaultConfig(t.TempDir()))
+	require.NoError(t, err)
+	defer backend.Close()
+
+	analyses := []*storage.AnalysisData{
+		{Query: "session token rotation", Focus: "security", Path: "src/auth", Result: map[string]interface{}{"content": "Tokens rotate on login."}},
+		{Query: "session storage layout", Path: "src/store", Result: map[string]interface{}{"content": "Sessions are kept in Redis."}},
+		{Query: "build pipeline", Path: "ci", Result: map[string]interface{}{"content": "Unrelated findings."}},
+	}
+	// Enough unrelated analyses for the matching terms to be rare
+	for i := 0; i < 5; i++ {
+		analyses = append(analyses, &storage.AnalysisData{
+			Query:  fmt.Sprintf("unrelated topic %d", i),
+			Result: map[string]interface{}{"content": "other"},
+			Path:   "docs",
+		})
+	}
+	for _, data := range analyses {
+		require.NoError(t, backend.Store(ctx, data))
+	}
+
+	hits, err := searchRanked(ctx, backend, "session tokens", searchFlags{max: 5})
+	require.NoError(t, err)
+	require.Len(t, hits, 2)
+	assert.Equal(t, analyses[0].ID, hits[0].ID)
+	assert.Equal(t, 1, hits[0].Rank)
+	assert.Equal(t, 2, hits[1].Rank)
+	assert.GreaterOrEqual(t, hits[0].Score, hits[1].Score)
+
+	var out bytes.Buffer
+	require.NoError(t, writeSearchHits(&out, hits))
+	lines := strings.Split(out.String(), "\n")
+	assert.True(t, strings.HasPrefix(lines[0], "1. ["), lines[0])
+	assert.Contains(t, lines[0], "session token rotation")
+	assert.Contains(t, out.String(), "ID: "+analyses[0].ID)
+	assert.Contains(t, out.String(), "Tokens rotate on login.")
+	assert.Contains(t, out.String(), "2. [")
+
+	// The MCP tool's filters, plus the analyzed path
+	hits, err = searchRanked(ctx, backend, "session", searchFlags{max: 5, focus: "security"})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, analyses[0].ID, hits[0].ID)
+
+	hits, err = searchRanked(ctx, backend, "session", searchFlags{max: 5, path: "src/store"})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, analyses[1].ID, hits[0].ID)
+
+	hits, err = searchRanked(ctx, backend, "session", searchFlags{max: 1})
+	require.NoError(t, err)
+	assert.Len(t, hits, 1)
+
+	out.Reset()
+	require.NoError(t, writeSearchHits(&out, nil))
+	assert.Equal(t, "No matching analyses.\n", out.String())
+
+	// rlm rag search is the same command under its older name
+	for _, name := range []string{"max", "focus", "namespace", "path", "since", "min-score", "json", "stream", "limit"} {
+		assert.NotNil(t, searchCmd.Flags().Lookup(name), name)
+		assert.NotNil(t, ragSearchCmd.Flags().Lookup(name), name)
+	}
+}
+
+func TestRAGHistory(t *testing.T) {
+	ctx := context.Background()
+	backend, err := storage.NewBM25Backend(storage.DefaultConfig(t.TempDir()))
+	require.NoError(t, err)
+	defer backend.Close()
+
+	path := hash.CanonicalPath(t.TempDir())
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Stored out of order; the history sorts by time
+	runs := []struct {
+		day    int
+		cost   float64
+		hashes map[string]string
+	}{
+		{2, 0.50, map[string]string{"main.go": "a2", "util.go": "b", "lib/old.go": "c", "new.go": "d"}},
+		{0, 1.00, map[string]string{"main.go": "a", "util.go": "b", "old.go": "c"}},
+		{5, 0.25, map[string]string{"main.go": "a2", "lib/old.go": "c", "new.go": "d2"}},
+	}
+	for _, run := range runs {
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+			Query:      fmt.Sprintf("day %d", run.day),
+			Timestamp:  start.AddDate(0, 0, run.day),
+			Path:       path,
+			FileHashes: run.hashes,
+			Stats:      orchestrator.Stats{TotalCostUSD: run.cost},
+		}))
+	}
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{Query: "elsewhere", Path: t.TempDir()}))
+
+	history, err := buildHistory(ctx, backend, path)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, []string{"day 0", "day 2", "day 5"}, []string{history[0].Query, history[1].Query, history[2].Query})
+
+	assert.Nil(t, history[0].Changes)
+	assert.Equal(t, 1.00, history[0].TotalUSD)
+
+	second := history[1].Changes
+	require.NotNil(t, second)
+	assert.Equal(t, []string{"main.go"}, second.Changed)
+	assert.Equal(t, []hash.Rename{{From: "old.go", To: "lib/old.go"}}, second.Renamed)
+	assert.Equal(t, []string{"new.go"}, second.New)
+	assert.Empty(t, second.Deleted)
+	assert.InDelta(t, 1.50, history[1].TotalUSD, 1e-9)
+
+	third := history[2].Changes
+	require.NotNil(t, third)
+	assert.Equal(t, []string{"new.go"}, third.Changed)
+	assert.Equal(t, []string{"util.go"}, third.Deleted)
+	assert.Equal(t, 0.25, history[2].CostUSD)
+	assert.InDelta(t, 1.75, history[2].TotalUSD, 1e-9)
+
+	var out bytes.Buffer
+	require.NoError(t, writeHistoryMarkdown(&out, history))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 5)
+	assert.Contains(t, lines[3], "1 changed, 1 new, 0 deleted, 1 renamed")
+	assert.Contains(t, lines[4], "$1.7500")
+}
+
+func TestStatusReportsEffectiveBackend(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Storage.RAGDir = t.TempDir()
+	assert.Equal(t, "bm25", storageStatus(context.Background(), cfg))
+
+	// A backend that can't be opened is reported as such, not as bm25
+	cfg.Storage.Backends = []string{"bm25", "qdrant"}
+	status := storageStatus(context.Background(), cfg)
+	assert.True(t, strings.HasPrefix(status, "unavailable"), status)
+	assert.Contains(t, status, `"qdrant"`)
+}
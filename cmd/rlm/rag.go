@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kukks/claude-rlm/internal/config"
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/kukks/claude-rlm/internal/storage"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var ragCmd = &cobra.Command{
+	Use:   "rag",
+	Short: "Manage the local RAG store",
+	Long:  `Inspect and maintain the analyses stored in the local RAG directory.`,
+}
+
+var ragPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete analyses older than the configured TTL",
+	Long:  `Delete stored analyses older than storage.analysis_ttl. Analyses without a timestamp are kept.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runRAGPrune(); err != nil {
+			log.Fatal().Err(err).Msg("Prune failed")
+		}
+	},
+}
+
+var ragConsolidateCmd = &cobra.Command{
+	Use:   "consolidate [path]",
+	Short: "Remove superseded analyses for a path",
+	Long:  `Keep only the newest analysis for each distinct query/focus of a path and delete the rest.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runRAGConsolidate(args[0]); err != nil {
+			log.Fatal().Err(err).Msg("Consolidate failed")
+		}
+	},
+}
+
+var ragReindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Rebuild the search index from stored analyses",
+	Long:  `Rebuild index.json and the search index from the analysis files in the RAG directory. Use this if the index is lost or out of sync.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runRAGReindex(); err != nil {
+			log.Fatal().Err(err).Msg("Reindex failed")
+		}
+	},
+}
+
+var ragShowCmd = &cobra.Command{
+	Use:   "show [id]",
+	Short: "Show a stored analysis",
+	Long:  `Print a stored analysis as JSON or as a readable Markdown document (--format md).`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+
+		if err := runRAGShow(args[0], format); err != nil {
+			log.Fatal().Err(err).Msg("Show failed")
+		}
+	},
+}
+
+var ragListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stored analyses",
+	Long:  `Print all stored analyses as a JSON array, or one JSON object per line with --stream.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		since, _ := cmd.Flags().GetString("since")
+		stream, _ := cmd.Flags().GetBool("stream")
+
+		if err := runRAGList(since, stream); err != nil {
+			log.Fatal().Err(err).Msg("List failed")
+		}
+	},
+}
+
+var ragSearchCmd = &cobra.Command{
+	Use:   "search [query]",
+	Short: "Search stored analyses (alias of rlm search)",
+	Long:  `Same as rlm search, with the same flags and output.`,
+	Args:  cobra.ExactArgs(1),
+	Run:   runSearchCommand,
+}
+
+var ragHistoryCmd = &cobra.Command{
+	Use:   "history [path]",
+	Short: "Show the timeline of analyses of a path",
+	Long: `Print every stored analysis of a path in chronological order, with the files
+that changed since the previous analysis (renames detected by content) and
+the cost of each analysis and of all of them so far. Output is JSON, or a
+Markdown table with --format md.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+
+		if err := runRAGHistory(args[0], format); err != nil {
+			log.Fatal().Err(err).Msg("History failed")
+		}
+	},
+}
+
+func init() {
+	ragHistoryCmd.Flags().String("format", "json", "Output format: json or md")
+	ragShowCmd.Flags().String("format", "json", "Output format: json or md")
+	ragListCmd.Flags().Bool("stream", false, "Emit one JSON object per line as analyses are read")
+	ragListCmd.Flags().String("since", "", "Only analyses at or after this time (RFC 3339, YYYY-MM-DD, 36h or 7d)")
+	addSearchFlags(ragSearchCmd)
+
+	ragCmd.AddCommand(ragPruneCmd, ragConsolidateCmd, ragReindexCmd, ragShowCmd, ragListCmd, ragSearchCmd, ragHistoryCmd)
+	rootCmd.AddCommand(ragCmd)
+}
+
+// openBackend loads configuration and opens the configured storage backend
+func openBackend(ctx context.Context) (*config.Config, storage.Backend, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	backend, err := storage.NewBackend(ctx, newStorageConfig(cfg))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create storage backend: %w", err)
+	}
+
+	return cfg, backend, nil
+}
+
+func runRAGPrune() error {
+	ctx := context.Background()
+
+	cfg, backend, err := openBackend(ctx)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	if cfg.Storage.AnalysisTTLDuration() == 0 {
+		fmt.Println("No analysis TTL configured (storage.analysis_ttl); nothing to prune.")
+		return nil
+	}
+
+	pruned, err := backend.PruneExpired(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Pruned %d expired analyses\n", pruned)
+	return nil
+}
+
+func runRAGConsolidate(path string) error {
+	ctx := context.Background()
+
+	_, backend, err := openBackend(ctx)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	path = hash.CanonicalPath(path)
+
+	before, err := countAnalysesForPath(ctx, backend, path)
+	if err != nil {
+		return err
+	}
+
+	if err := backend.Consolidate(ctx, path); err != nil {
+		return err
+	}
+
+	after, err := countAnalysesForPath(ctx, backend, path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Consolidated %s: %d -> %d analyses\n", path, before, after)
+	return nil
+}
+
+func runRAGReindex() error {
+	ctx := context.Background()
+
+	_, backend, err := openBackend(ctx)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	if err := backend.Reindex(ctx); err != nil {
+		return err
+	}
+
+	analyses, err := backend.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Reindexed %d analyses\n", len(analyses))
+	return nil
+}
+
+// countAnalysesForPath returns the number of stored analyses for a path
+func countAnalysesForPath(ctx context.Context, backend storage.Backend, path string) (int, error) {
+	analyses, err := backend.GetAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, data := range analyses {
+		if hash.SamePath(data.Path, path) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func runRAGShow(id, format string) error {
+	ctx := context.Background()
+
+	_, backend, err := openBackend(ctx)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	data, err := backend.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if data.Integrity != "" {
+		fmt.Fprintf(os.Stderr, "Signature: %s\n", data.Integrity)
+	}
+
+	switch format {
+	case "md", "markdown":
+		fmt.Print(storage.RenderMarkdown(data))
+	case "json":
+		return writeIndentedJSON(os.Stdout, data)
+	default:
+		return fmt.Errorf("unknown format %q (expected json or md)", format)
+	}
+
+	return nil
+}
+
+func runRAGList(since string, stream bool) error {
+	ctx := context.Background()
+
+	sinceTime, err := parseSinceFlag(since)
+	if err != nil {
+		return err
+	}
+
+	_, backend, err := openBackend(ctx)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	return writeAnalyses(ctx, backend, sinceTime, stream, os.Stdout)
+}
+
+func runRAGHistory(path, format string) error {
+	ctx := context.Background()
+
+	if format != "json" && format != "md" && format != "markdown" {
+		return fmt.Errorf("unknown format %q (expected json or md)", format)
+	}
+
+	_, backend, err := openBackend(ctx)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	history, err := buildHistory(ctx, backend, hash.CanonicalPath(path))
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		return writeIndentedJSON(os.Stdout, history)
+	}
+	return writeHistoryMarkdown(os.Stdout, history)
+}
+
+// historyEntry is one analysis in a path's timeline
+type historyEntry struct {
+	ID        string    `json:"id"`
+	Query     string    `json:"query"`
+	Focus     string    `json:"focus,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Files     int       `json:"files"`
+	CostUSD   float64   `json:"cost_usd"`
+	TotalUSD  float64   `json:"total_cost_usd"` // Cost of this and all earlier analyses
+
+	// Changes since the previous analysis; nil for the first
+	Changes *hash.HashDiff `json:"changes,omitempty"`
+}
+
+// buildHistory returns the analyses of path oldest first, each diffed
+// against the one before it
+func buildHistory(ctx context.Context, backend storage.Backend, path string) ([]historyEntry, error) {
+	analyses := make([]*storage.AnalysisData, 0)
+	err := backend.Walk(ctx, func(data *storage.AnalysisData) error {
+		if hash.SamePath(data.Path, path) {
+			analyses = append(analyses, data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(analyses, func(i, j int) bool {
+		return analyses[i].Timestamp.Before(analyses[j].Timestamp)
+	})
+
+	history := make([]historyEntry, len(analyses))
+	total := 0.0
+	for i, data := range analyses {
+		total += data.Stats.TotalCostUSD
+		history[i] = historyEntry{
+			ID:        data.ID,
+			Query:     data.Query,
+			Focus:     data.Focus,
+			Namespace: data.Namespace,
+			Timestamp: data.Timestamp,
+			Files:     len(data.FileHashes),
+			CostUSD:   data.Stats.TotalCostUSD,
+			TotalUSD:  total,
+		}
+		if i > 0 {
+			history[i].Changes = hash.DiffHashes(analyses[i-1].FileHashes, data.FileHashes)
+		}
+	}
+
+	return history, nil
+}
+
+// writeHistoryMarkdown writes a path's timeline as a Markdown table
+func writeHistoryMarkdown(w io.Writer, history []historyEntry) error {
+	var sb strings.Builder
+	sb.WriteString("| Analyzed | ID | Query | Files | Changes | Cost | Total |\n")
+	sb.WriteString("|----------|----|-------|-------|---------|------|-------|\n")
+	for _, entry := range history {
+		changes := "-"
+		if c := entry.Changes; c != nil {
+			changes = fmt.Sprintf("%d changed, %d new, %d deleted, %d renamed", len(c.Changed), len(c.New), len(c.Deleted), len(c.Renamed))
+		}
+		query := strings.ReplaceAll(entry.Query, "|", `\|`)
+		fmt.Fprintf(&sb, "| %s | %s | %s | %d | %s | $%.4f | $%.4f |\n",
+			entry.Timestamp.Format("2006-01-02 15:04:05"), entry.ID, query, entry.Files, changes, entry.CostUSD, entry.TotalUSD)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// parseSinceFlag parses a --since value; empty means no bound
+func parseSinceFlag(since string) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	return storage.ParseSince(since, time.Now())
+}
+
+// writeAnalyses writes stored analyses at or after since to w, either as a
+// single JSON array or as JSON lines emitted while walking the store
+func writeAnalyses(ctx context.Context, backend storage.Backend, since time.Time, stream bool, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	analyses := make([]*storage.AnalysisData, 0)
+
+	err := backend.Walk(ctx, func(data *storage.AnalysisData) error {
+		if data.Timestamp.Before(since) {
+			return nil
+		}
+		if stream {
+			return enc.Encode(data)
+		}
+		analyses = append(analyses, data)
+		return nil
+	})
+	if err != nil || stream {
+		return err
+	}
+
+	return writeIndentedJSON(w, analyses)
+}
+
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
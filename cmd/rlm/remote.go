@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// gitScheme prefixes analyze paths that name a remote Git repository,
+// e.g. git+https://github.com/org/repo?ref=main
+const gitScheme = "git+"
+
+// isGitRemote reports whether an analyze path names a remote Git repository
+func isGitRemote(path string) bool {
+	return strings.HasPrefix(path, gitScheme)
+}
+
+// parseGitRemote splits a git+ path into the clone URL and the optional
+// ref (branch or tag) given by its ref or branch query parameter
+func parseGitRemote(path string) (cloneURL, ref string, err error) {
+	u, err := url.Parse(strings.TrimPrefix(path, gitScheme))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid git URL %q: %w", path, err)
+	}
+	if u.Scheme == "" {
+		return "", "", fmt.Errorf("invalid git URL %q: missing scheme (e.g. git+https://)", path)
+	}
+
+	query := u.Query()
+	ref = query.Get("ref")
+	if ref == "" {
+		ref = query.Get("branch")
+	}
+	query.Del("ref")
+	query.Del("branch")
+	u.RawQuery = query.Encode()
+
+	return u.String(), ref, nil
+}
+
+// cloneGitRemote shallow-clones a git+ path into a temporary directory and
+// returns the directory and a function removing it
+func cloneGitRemote(ctx context.Context, path string) (string, func(), error) {
+	cloneURL, ref, err := parseGitRemote(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "rlm-git-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create clone directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	args := []string{"clone", "--depth", "1", "--quiet"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, "--", cloneURL, dir)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stderr = &stderr
+	// Never block on a credential prompt
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	if err := cmd.Run(); err != nil {
+		cleanup()
+		return "", nil, cloneError(cloneURL, ref, err, stderr.String())
+	}
+
+	return dir, cleanup, nil
+}
+
+// cloneError turns a failed git clone into an error naming the likely cause
+func cloneError(cloneURL, ref string, err error, stderr string) error {
+	var execErr *exec.Error
+	if errors.As(err, &execErr) {
+		return fmt.Errorf("git is required to analyze %s: %w", cloneURL, err)
+	}
+
+	detail := strings.TrimSpace(stderr)
+	lower := strings.ToLower(detail)
+	switch {
+	case strings.Contains(lower, "authentication failed"),
+		strings.Contains(lower, "could not read username"),
+		strings.Contains(lower, "permission denied"):
+		return fmt.Errorf("authentication failed cloning %s: %s", cloneURL, detail)
+	case ref != "" && strings.Contains(lower, "remote branch") && strings.Contains(lower, "not found"):
+		return fmt.Errorf("ref %q not found in %s", ref, cloneURL)
+	case strings.Contains(lower, "not found"),
+		strings.Contains(lower, "does not appear to be a git repository"),
+		strings.Contains(lower, "does not exist"):
+		return fmt.Errorf("repository not found: %s", cloneURL)
+	default:
+		return fmt.Errorf("failed to clone %s: %w: %s", cloneURL, err, detail)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBareRepo creates a bare repository with a main and a feature branch
+// and returns its file:// URL
+func newBareRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	work := t.TempDir()
+	git(work, "init", "--quiet", "--initial-branch=main")
+	require.NoError(t, os.WriteFile(filepath.Join(work, "main.go"), []byte("package main\n"), 0644))
+	git(work, "add", ".")
+	git(work, "commit", "--quiet", "-m", "initial")
+	git(work, "checkout", "--quiet", "-b", "feature")
+	require.NoError(t, os.WriteFile(filepath.Join(work, "feature.go"), []byte("package main\n"), 0644))
+	git(work, "add", ".")
+	git(work, "commit", "--quiet", "-m", "feature")
+	git(work, "checkout", "--quiet", "main")
+
+	bare := filepath.Join(t.TempDir(), "repo.git")
+	git(work, "clone", "--quiet", "--bare", work, bare)
+	return "file://" + filepath.ToSlash(bare)
+}
+
+func TestParseGitRemote(t *testing.T) {
+	cloneURL, ref, err := parseGitRemote("git+https://github.com/org/repo?ref=v1.2.0")
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/org/repo", cloneURL)
+	assert.Equal(t, "v1.2.0", ref)
+
+	_, ref, err = parseGitRemote("git+https://github.com/org/repo?branch=dev")
+	require.NoError(t, err)
+	assert.Equal(t, "dev", ref)
+
+	assert.True(t, isGitRemote("git+ssh://git@github.com/org/repo"))
+	assert.False(t, isGitRemote("./repo"))
+}
+
+func TestCloneGitRemote(t *testing.T) {
+	remote := newBareRepo(t)
+	ctx := context.Background()
+
+	dir, cleanup, err := cloneGitRemote(ctx, "git+"+remote+"?ref=feature")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "feature.go"))
+
+	// The clone is analyzed like any local directory
+	orchConfig := orchestrator.DefaultConfig()
+	orchConfig.WorkDir = t.TempDir()
+	orch := orchestrator.New(orchConfig, zerolog.Nop())
+	var analyzed string
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		analyzed = task.Context["document_path"].(string)
+		return orchestrator.PlaceholderDispatcher(ctx, task)
+	})
+	_, err = orch.AnalyzeDocument(ctx, dir, "what is here?")
+	require.NoError(t, err)
+	assert.Equal(t, dir, analyzed)
+
+	cleanup()
+	assert.NoDirExists(t, dir)
+
+	// Default branch when no ref is given
+	dir, cleanup, err = cloneGitRemote(ctx, "git+"+remote)
+	require.NoError(t, err)
+	defer cleanup()
+	assert.FileExists(t, filepath.Join(dir, "main.go"))
+	assert.NoFileExists(t, filepath.Join(dir, "feature.go"))
+}
+
+func TestCloneGitRemoteErrors(t *testing.T) {
+	remote := newBareRepo(t)
+	ctx := context.Background()
+
+	_, _, err := cloneGitRemote(ctx, "git+"+remote+"?ref=missing")
+	assert.ErrorContains(t, err, `ref "missing" not found`)
+
+	_, _, err = cloneGitRemote(ctx, "git+file://"+filepath.ToSlash(filepath.Join(t.TempDir(), "nope.git")))
+	assert.ErrorContains(t, err, "repository not found")
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/kukks/claude-rlm/internal/mcp"
+	"github.com/kukks/claude-rlm/internal/storage"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search [query]",
+	Short: "Search stored analyses by relevance",
+	Long: `Search the RAG store like the rlm_search_rag MCP tool and print the ranked
+results with their score and a snippet of the findings, as JSON with --json,
+or as one JSON object per line, emitted as results are found, with --stream.
+Results can be narrowed by focus, namespace, analyzed path, age and score.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runSearchCommand,
+}
+
+func init() {
+	addSearchFlags(searchCmd)
+	rootCmd.AddCommand(searchCmd)
+}
+
+// addSearchFlags registers the flags of rlm search on cmd, which is also
+// used for its rlm rag search alias
+func addSearchFlags(cmd *cobra.Command) {
+	cmd.Flags().IntP("max", "n", 5, "Maximum number of results")
+	cmd.Flags().String("focus", "", "Only analyses with this focus")
+	cmd.Flags().String("namespace", "", "Only analyses in this namespace")
+	cmd.Flags().String("path", "", "Only analyses of this path")
+	cmd.Flags().String("since", "", "Only analyses at or after this time (RFC 3339, YYYY-MM-DD, 36h or 7d)")
+	cmd.Flags().Float64("min-score", 0, "Drop results scoring below this")
+	cmd.Flags().Bool("json", false, "Print the results as JSON")
+	cmd.Flags().Bool("stream", false, "Emit one JSON object per line as results are found")
+
+	// Spelling of --max used by rlm rag search before it became an alias
+	cmd.Flags().Int("limit", 5, "Maximum number of results")
+	cmd.Flags().MarkDeprecated("limit", "use --max instead")
+}
+
+func runSearchCommand(cmd *cobra.Command, args []string) {
+	opts := searchFlags{}
+	opts.max, _ = cmd.Flags().GetInt("max")
+	if cmd.Flags().Changed("limit") {
+		opts.max, _ = cmd.Flags().GetInt("limit")
+	}
+	opts.focus, _ = cmd.Flags().GetString("focus")
+	opts.namespace, _ = cmd.Flags().GetString("namespace")
+	opts.path, _ = cmd.Flags().GetString("path")
+	opts.since, _ = cmd.Flags().GetString("since")
+	opts.minScore, _ = cmd.Flags().GetFloat64("min-score")
+	opts.json, _ = cmd.Flags().GetBool("json")
+	opts.stream, _ = cmd.Flags().GetBool("stream")
+
+	if err := runSearch(args[0], opts); err != nil {
+		log.Fatal().Err(err).Msg("Search failed")
+	}
+}
+
+// searchFlags are the filters and output options of rlm search
+type searchFlags struct {
+	max       int
+	focus     string
+	namespace string
+	path      string
+	since     string
+	minScore  float64
+	json      bool
+	stream    bool
+}
+
+// searchHit is one ranked result of rlm search, with the fields of an
+// rlm_search_rag summary
+type searchHit struct {
+	Rank      int     `json:"rank"`
+	ID        string  `json:"id"`
+	Query     string  `json:"query"`
+	Focus     string  `json:"focus,omitempty"`
+	Namespace string  `json:"namespace,omitempty"`
+	Path      string  `json:"path"`
+	Timestamp string  `json:"timestamp"`
+	Score     float64 `json:"score"`
+	Method    string  `json:"search_method"`
+	Snippet   string  `json:"snippet"`
+}
+
+func runSearch(query string, flags searchFlags) error {
+	ctx := context.Background()
+
+	cfg, backend, err := openBackend(ctx)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	// Focus is matched against the configured vocabulary like the MCP tool
+	focus, known, err := mcp.NewFocusVocabulary(cfg.MCP.Focuses, cfg.MCP.StrictFocus).Validate(flags.focus)
+	if err != nil {
+		return err
+	}
+	if !known {
+		log.Warn().Str("focus", flags.focus).Strs("allowed", cfg.MCP.Focuses).Msg("Unknown focus")
+	}
+	flags.focus = focus
+
+	if flags.stream {
+		enc := json.NewEncoder(os.Stdout)
+		return searchStream(ctx, backend, query, flags, func(hit searchHit) error {
+			return enc.Encode(hit)
+		})
+	}
+
+	hits, err := searchRanked(ctx, backend, query, flags)
+	if err != nil {
+		return err
+	}
+	if flags.json {
+		return writeIndentedJSON(os.Stdout, hits)
+	}
+	return writeSearchHits(os.Stdout, hits)
+}
+
+// searchRanked searches backend and returns the ranked results that pass
+// the filters of flags, best first
+func searchRanked(ctx context.Context, backend storage.Backend, query string, flags searchFlags) ([]searchHit, error) {
+	hits := make([]searchHit, 0)
+	err := searchStream(ctx, backend, query, flags, func(hit searchHit) error {
+		hits = append(hits, hit)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// searchStream searches backend and passes each ranked result that passes
+// the filters of flags to emit as it is found, best first
+func searchStream(ctx context.Context, backend storage.Backend, query string, flags searchFlags, emit func(searchHit) error) error {
+	since, err := parseSinceFlag(flags.since)
+	if err != nil {
+		return err
+	}
+
+	opts := storage.SearchOptions{
+		Limit:     flags.max,
+		Since:     since,
+		Focus:     flags.focus,
+		Namespace: flags.namespace,
+	}
+	// Backends can't filter by path, so look past the limit for matches
+	if flags.path != "" {
+		opts.Limit = 0
+	}
+
+	emitted := 0
+	err = backend.SearchStream(ctx, query, opts, func(r *storage.SearchResult) error {
+		if flags.path != "" && !hash.SamePath(r.Data.Path, flags.path) {
+			return nil
+		}
+		// Results are ranked best first, so the cutoff only trims the tail
+		if (flags.minScore > 0 && r.Score < flags.minScore) || (flags.max > 0 && emitted >= flags.max) {
+			return nil
+		}
+		emitted++
+		return emit(searchHit{
+			Rank:      emitted,
+			ID:        r.Data.ID,
+			Query:     r.Data.Query,
+			Focus:     r.Data.Focus,
+			Namespace: r.Data.Namespace,
+			Path:      r.Data.Path,
+			Timestamp: r.Data.Timestamp.Format("2006-01-02 15:04:05"),
+			Score:     r.Score,
+			Method:    r.SearchMethod,
+			Snippet:   storage.Snippet(r.Data, storage.SnippetLength),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("search failed: %w", err)
+	}
+	return nil
+}
+
+// writeSearchHits prints ranked results for reading in a terminal
+func writeSearchHits(w io.Writer, hits []searchHit) error {
+	if len(hits) == 0 {
+		_, err := fmt.Fprintln(w, "No matching analyses.")
+		return err
+	}
+
+	for _, hit := range hits {
+		fmt.Fprintf(w, "%d. [%.3f] %s\n", hit.Rank, hit.Score, hit.Query)
+		fmt.Fprintf(w, "   ID: %s\n", hit.ID)
+		if hit.Focus != "" {
+			fmt.Fprintf(w, "   Focus: %s\n", hit.Focus)
+		}
+		fmt.Fprintf(w, "   Path: %s (%s)\n", hit.Path, hit.Timestamp)
+		if hit.Snippet != "" {
+			fmt.Fprintf(w, "   %s\n", hit.Snippet)
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -10,23 +13,222 @@ import (
 
 // Config holds all configuration for RLM
 type Config struct {
+	Offline      bool               `mapstructure:"offline"`
 	Orchestrator OrchestratorConfig `mapstructure:"orchestrator"`
 	Storage      StorageConfig      `mapstructure:"storage"`
+	Hash         HashConfig         `mapstructure:"hash"`
 	Updater      UpdaterConfig      `mapstructure:"updater"`
 	Logging      LoggingConfig      `mapstructure:"logging"`
+	MCP          MCPConfig          `mapstructure:"mcp"`
 }
 
 // OrchestratorConfig holds orchestrator settings
 type OrchestratorConfig struct {
-	MaxRecursionDepth int           `mapstructure:"max_recursion_depth"`
-	MaxIterations     int           `mapstructure:"max_iterations"`
-	CacheEnabled      bool          `mapstructure:"cache_enabled"`
-	CacheTTLHours     int           `mapstructure:"cache_ttl_hours"`
+	MaxRecursionDepth  int   `mapstructure:"max_recursion_depth"`
+	MaxIterations      int   `mapstructure:"max_iterations"`
+	MaxChildrenPerTask int   `mapstructure:"max_children_per_task"`
+	CacheEnabled       bool  `mapstructure:"cache_enabled"`
+	CacheTTLHours      int   `mapstructure:"cache_ttl_hours"`
+	FailureDump        bool  `mapstructure:"failure_dump"`
+	MaxFiles           int   `mapstructure:"max_files"`
+	MaxBytes           int64 `mapstructure:"max_bytes"`
+
+	// CacheTTLPerUSD keeps costly results cached longer: each USD a
+	// result cost adds this many multiples of the cache TTL (0 = flat TTL)
+	CacheTTLPerUSD float64 `mapstructure:"cache_ttl_per_usd"`
+
+	// CacheVersion salts subtask cache keys: change it after editing
+	// prompts or analysis logic to stop reusing results cached before
+	CacheVersion string `mapstructure:"cache_version"`
+
+	// SkipGenerated leaves generated and minified files (DO NOT EDIT
+	// headers, lockfiles, lines over GeneratedMaxLineLength; 0 = 1000)
+	// out of hashing and analysis
+	SkipGenerated          bool `mapstructure:"skip_generated"`
+	GeneratedMaxLineLength int  `mapstructure:"generated_max_line_length"`
+
+	// Fresh disables the subtask cache and discards saved orchestrator
+	// state, so every analysis starts clean (for debugging)
+	Fresh bool `mapstructure:"fresh"`
+
+	// Assembly orders a directory's files for the Explorer: path, size,
+	// readme-first or manifest. Empty leaves directories unassembled.
+	Assembly         string `mapstructure:"assembly"`
+	AssemblyManifest string `mapstructure:"assembly_manifest"`
+
+	// BinaryFiles decides what happens to assembled files whose content is
+	// binary: include, skip, or annotate (listed apart as binary_files)
+	BinaryFiles string `mapstructure:"binary_files"`
+
+	// Prompts maps agent types (or "default") to text/template sources
+	// rendered over the Task to build each subagent prompt
+	Prompts map[string]string `mapstructure:"prompts"`
+
+	// Processors names built-in result processors (redact_secrets,
+	// trim_whitespace) applied in order to each final result
+	Processors []string `mapstructure:"processors"`
+
+	// MaxDuration bounds an analysis' wall-clock time (Go duration, e.g.
+	// "10m"). When exceeded, state is saved for resume. Empty is unlimited.
+	MaxDuration string `mapstructure:"max_duration"`
+
+	// DepthSchedule scales depth and iterations with the analyzed size
+	DepthSchedule DepthScheduleConfig `mapstructure:"depth_schedule"`
+
+	// MaxResultBytes caps the content of each subagent result (0 =
+	// unlimited). Longer content is truncated with a marker, or fails the
+	// analysis when RejectOversizedResults is set.
+	MaxResultBytes         int  `mapstructure:"max_result_bytes"`
+	RejectOversizedResults bool `mapstructure:"reject_oversized_results"`
+
+	// InitialAgentType is the agent each analysis starts from (empty =
+	// Explorer); SeedContext is added to that first task's context
+	InitialAgentType string                 `mapstructure:"initial_agent_type"`
+	SeedContext      map[string]interface{} `mapstructure:"seed_context"`
+
+	// TraceStore keeps each analysis' full task tree (tasks, continuations
+	// and per-task results) with the stored analysis for later inspection
+	TraceStore bool `mapstructure:"trace_store"`
+
+	// Fsync syncs saved state, cache entries and failure dumps to disk
+	// before continuing, so they survive a power loss (slower)
+	Fsync bool `mapstructure:"fsync"`
+
+	// DedupResults delivers each result event once per run (by cache key),
+	// so progress and streaming consumers see no repeats after a resume
+	DedupResults bool `mapstructure:"dedup_results"`
+}
+
+// DepthScheduleConfig derives each analysis' depth and iteration budget
+// from the size of what it analyzes. The budget ranges from the minimums
+// for a single file up to max_recursion_depth and max_iterations at
+// full_scale_files (0 = 10000) or more.
+type DepthScheduleConfig struct {
+	Enabled        bool `mapstructure:"enabled"`
+	MinDepth       int  `mapstructure:"min_depth"`
+	MinIterations  int  `mapstructure:"min_iterations"`
+	FullScaleFiles int  `mapstructure:"full_scale_files"`
 }
 
 // StorageConfig holds storage settings
 type StorageConfig struct {
-	RAGDir string `mapstructure:"rag_dir"`
+	RAGDir         string          `mapstructure:"rag_dir"`
+	AnalysisTTL    string          `mapstructure:"analysis_ttl"`
+	PruneOnStartup bool            `mapstructure:"prune_on_startup"`
+	Tokenizer      TokenizerConfig `mapstructure:"tokenizer"`
+	DedupScoreGap  float64         `mapstructure:"dedup_score_gap"`
+	MaxPerPath     int             `mapstructure:"max_per_path"`
+	Compress       bool            `mapstructure:"compress"`
+	IndexFields    []string        `mapstructure:"index_fields"`
+
+	// DeterministicIDs derives analysis IDs from path, query, focus and
+	// file hashes so re-runs overwrite instead of adding duplicates
+	DeterministicIDs bool `mapstructure:"deterministic_ids"`
+
+	// PrettyJSON indents stored analysis and index files for readability;
+	// false writes compact JSON to save space on large stores
+	PrettyJSON bool `mapstructure:"pretty_json"`
+
+	// RewriteMigrated rewrites analysis files from older versions in the
+	// current format on startup instead of migrating them on every read
+	RewriteMigrated bool `mapstructure:"rewrite_migrated"`
+
+	// RecencyHalfLife boosts recent analyses in search, the boost halving
+	// with each half-life of age (Go duration, e.g. "720h"). Empty ranks
+	// on relevance alone.
+	RecencyHalfLife string `mapstructure:"recency_half_life"`
+
+	// Backends lists the backends analyses are written to, primary first.
+	// Searches read the primary and fall back to the rest on error.
+	Backends []string `mapstructure:"backends"`
+
+	// ParallelSearch queries all Backends at once instead of in order
+	ParallelSearch ParallelSearchConfig `mapstructure:"parallel_search"`
+
+	// Fsync syncs stored analyses and the index to disk before a store
+	// completes, so they survive a power loss (slower)
+	Fsync bool `mapstructure:"fsync"`
+
+	// OpTimeout bounds each storage operation (Go duration, e.g. "30s"),
+	// so a stalled backend cannot hang the server. Empty disables it.
+	OpTimeout string `mapstructure:"op_timeout"`
+
+	// SigningKey, when set, signs stored analyses with an HMAC so that
+	// edits made outside rlm are detected when they are loaded
+	SigningKey string `mapstructure:"signing_key"`
+
+	// DeltaEncoding stores a re-run analysis whose findings barely changed
+	// as a diff against the previous one, with a full copy every
+	// DeltaSnapshotEvery versions (0 = 10)
+	DeltaEncoding      bool `mapstructure:"delta_encoding"`
+	DeltaSnapshotEvery int  `mapstructure:"delta_snapshot_every"`
+}
+
+// OpTimeoutDuration parses OpTimeout. Returns 0 (no timeout) when empty
+// or invalid.
+func (c *StorageConfig) OpTimeoutDuration() time.Duration {
+	if c.OpTimeout == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(c.OpTimeout)
+	if err != nil || duration < 0 {
+		return 0
+	}
+	return duration
+}
+
+// ParallelSearchConfig answers searches from the fastest backends: a
+// search returns once min_results results arrived (0 = the search limit)
+// or after timeout (Go duration; empty waits for every backend),
+// cancelling slower backends
+type ParallelSearchConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	Timeout    string `mapstructure:"timeout"`
+	MinResults int    `mapstructure:"min_results"`
+}
+
+// TimeoutDuration parses Timeout. Returns 0 (no timeout) when empty or
+// invalid.
+func (c *ParallelSearchConfig) TimeoutDuration() time.Duration {
+	if c.Timeout == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
+// TokenizerConfig holds search tokenizer settings
+type TokenizerConfig struct {
+	MinLength int      `mapstructure:"min_length"`
+	Stopwords []string `mapstructure:"stopwords"`
+
+	// CaseSensitive keeps token case so identifiers differing only in
+	// case are distinct; FoldAccents strips accents (café matches cafe)
+	CaseSensitive bool `mapstructure:"case_sensitive"`
+	FoldAccents   bool `mapstructure:"fold_accents"`
+
+	// Bigrams also indexes adjacent word pairs so multi-word phrases
+	// match more precisely, at the cost of a larger index
+	Bigrams bool `mapstructure:"bigrams"`
+}
+
+// HashConfig holds file hashing settings
+type HashConfig struct {
+	// Algorithm hashes files for change detection: sha256, or the much
+	// faster crc32c where tamper resistance isn't needed
+	Algorithm string `mapstructure:"algorithm"`
+
+	// Preset selects the files hashed and analyzed: go, web, docs or all.
+	// Patterns adds file name globs (e.g. "*.proto") to the preset.
+	Preset   string   `mapstructure:"preset"`
+	Patterns []string `mapstructure:"patterns"`
+
+	// WalkWorkers is how many directories are read at once when walking a
+	// tree to hash or measure it
+	WalkWorkers int `mapstructure:"walk_workers"`
 }
 
 // UpdaterConfig holds auto-updater settings
@@ -34,25 +236,154 @@ type UpdaterConfig struct {
 	Enabled       bool   `mapstructure:"enabled"`
 	AutoUpdate    bool   `mapstructure:"auto_update"`
 	CheckInterval string `mapstructure:"check_interval"`
+
+	// Jitter delays the startup check and each periodic check by a random
+	// amount up to this duration (e.g. "30m"), spreading out fleets.
+	// Empty checks exactly on schedule.
+	Jitter string `mapstructure:"jitter"`
+}
+
+// MCPConfig holds MCP server settings
+type MCPConfig struct {
+	ReadOnly bool `mapstructure:"read_only"`
+
+	// MaxConcurrentAnalyses bounds in-flight rlm_analyze calls (0 = unlimited).
+	// Excess calls wait when QueueAnalyses is set and are rejected otherwise.
+	MaxConcurrentAnalyses int  `mapstructure:"max_concurrent_analyses"`
+	QueueAnalyses         bool `mapstructure:"queue_analyses"`
+
+	// RateLimits maps tool names to token-bucket limits. Unlisted tools
+	// are not limited.
+	RateLimits map[string]RateLimitConfig `mapstructure:"rate_limits"`
+
+	// IdempotencyWindow is how long rlm_analyze idempotency keys are
+	// remembered (Go duration, e.g. "24h"). Empty or zero disables keys.
+	IdempotencyWindow string `mapstructure:"idempotency_window"`
+
+	// Focuses is the allowed set of focus values (empty allows any).
+	// StrictFocus rejects unknown values instead of logging a warning.
+	Focuses     []string `mapstructure:"focuses"`
+	StrictFocus bool     `mapstructure:"strict_focus"`
+
+	// PackageRootMarkers are the files marking a package root for
+	// rlm_analyze scope=package (empty uses go.mod, package.json, ...)
+	PackageRootMarkers []string `mapstructure:"package_root_markers"`
+
+	// QuickMaxFiles and QuickMaxDepth bound rlm_analyze quick=true scans:
+	// files hashed and recursion depth (0 uses the defaults, 200 and 1)
+	QuickMaxFiles int `mapstructure:"quick_max_files"`
+	QuickMaxDepth int `mapstructure:"quick_max_depth"`
+
+	// AllowedRoots confines rlm_analyze and rlm_check_freshness to paths
+	// under these directories (empty allows any path)
+	AllowedRoots []string `mapstructure:"allowed_roots"`
+
+	// MetricsExport periodically writes accumulated analysis counters
+	// to a Prometheus textfile
+	MetricsExport MetricsExportConfig `mapstructure:"metrics_export"`
+
+	// Queue lets rlm_analyze enqueue=true queue analyses in
+	// <rag_dir>/queue.jsonl for background workers, surviving restarts
+	Queue QueueConfig `mapstructure:"queue"`
+}
+
+// QueueConfig controls the persistent analysis job queue. Finished jobs,
+// with their results, are kept for Retention (Go duration) and at most
+// MaxFinished of them; empty or zero keeps them without limit.
+type QueueConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Workers     int    `mapstructure:"workers"` // Jobs run at once (0 or less is 1)
+	Retention   string `mapstructure:"retention"`
+	MaxFinished int    `mapstructure:"max_finished"`
+}
+
+// RetentionDuration parses Retention. Returns 0 (keep finished jobs
+// regardless of age) when unset or invalid.
+func (c *QueueConfig) RetentionDuration() time.Duration {
+	if c.Retention == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(c.Retention)
+	if err != nil || duration < 0 {
+		return 0
+	}
+	return duration
+}
+
+// MetricsExportConfig controls the Prometheus textfile export: counters
+// are written to path (empty uses metrics.prom in the RAG directory)
+// every interval (Go duration) and when the server stops
+type MetricsExportConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Path     string `mapstructure:"path"`
+	Interval string `mapstructure:"interval"`
+}
+
+// IntervalDuration parses Interval. Returns 0 when empty or invalid.
+func (c *MetricsExportConfig) IntervalDuration() time.Duration {
+	if c.Interval == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(c.Interval)
+	if err != nil || duration < 0 {
+		return 0
+	}
+	return duration
+}
+
+// RateLimitConfig holds a single tool's rate limit
+type RateLimitConfig struct {
+	PerMinute float64 `mapstructure:"per_minute"`
+	Burst     int     `mapstructure:"burst"`
 }
 
 // LoggingConfig holds logging settings
 type LoggingConfig struct {
-	Level  string `mapstructure:"level"`
-	Format string `mapstructure:"format"`
+	Level  string       `mapstructure:"level"`
+	Format string       `mapstructure:"format"`
+	Redact RedactConfig `mapstructure:"redact"`
+}
+
+// RedactConfig controls redaction of MCP traffic in debug logs
+type RedactConfig struct {
+	Enabled   bool     `mapstructure:"enabled"`
+	MaxLength int      `mapstructure:"max_length"`
+	Fields    []string `mapstructure:"fields"`
 }
 
 // DefaultConfig returns default configuration
 func DefaultConfig() *Config {
 	return &Config{
+		Offline: false,
 		Orchestrator: OrchestratorConfig{
 			MaxRecursionDepth: 10,
 			MaxIterations:     1000,
 			CacheEnabled:      true,
 			CacheTTLHours:     24,
+			FailureDump:       true,
+			MaxFiles:          10000,
+			MaxBytes:          200 * 1024 * 1024,
+			BinaryFiles:       "skip",
+			DepthSchedule: DepthScheduleConfig{
+				MinDepth:      2,
+				MinIterations: 50,
+			},
 		},
 		Storage: StorageConfig{
-			RAGDir: ".rlm",
+			RAGDir:         ".rlm",
+			AnalysisTTL:    "",
+			PruneOnStartup: false,
+			Tokenizer: TokenizerConfig{
+				MinLength: 2,
+			},
+			IndexFields: []string{"query", "focus", "content"},
+			PrettyJSON:  true,
+			OpTimeout:   "30s",
+		},
+		Hash: HashConfig{
+			Algorithm:   "sha256",
+			Preset:      "all",
+			WalkWorkers: 4,
 		},
 		Updater: UpdaterConfig{
 			Enabled:       true,
@@ -62,39 +393,85 @@ func DefaultConfig() *Config {
 		Logging: LoggingConfig{
 			Level:  "info",
 			Format: "text",
+			Redact: RedactConfig{
+				Enabled:   true,
+				MaxLength: 200,
+			},
+		},
+		MCP: MCPConfig{
+			ReadOnly:              false,
+			MaxConcurrentAnalyses: 0,
+			QueueAnalyses:         true,
+			IdempotencyWindow:     "24h",
+			MetricsExport: MetricsExportConfig{
+				Interval: "1m",
+			},
+			Queue: QueueConfig{
+				Workers:     1,
+				Retention:   "24h",
+				MaxFinished: 100,
+			},
 		},
 	}
 }
 
-// Load loads configuration from file and environment
+// configExtensions are the config file formats Load looks for, in order
+var configExtensions = []string{".yaml", ".yml", ".json", ".jsonc", ".json5", ".toml"}
+
+// Load loads configuration from config.<ext> in ~/.config/rlm or the current
+// directory, in that order, falling back to defaults when neither has one
 func Load() (*Config, error) {
-	// Set defaults
-	config := DefaultConfig()
+	dirs := make([]string, 0, 2)
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".config", "rlm"))
+	}
+	dirs = append(dirs, ".")
+
+	for _, dir := range dirs {
+		for _, ext := range configExtensions {
+			path := filepath.Join(dir, "config"+ext)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return LoadFile(path)
+			}
+		}
+	}
 
-	// Set up viper
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
+	// Config file not found; use defaults
+	return DefaultConfig(), nil
+}
+
+// LoadFile loads configuration from exactly the file at path instead of
+// searching the default locations. The format follows the file extension:
+// YAML (.yaml, .yml or none), TOML (.toml) or JSON (.json, .jsonc, .json5),
+// where JSON may contain comments and trailing commas. Unlike Load, a
+// missing or unparseable file is an error.
+func LoadFile(path string) (*Config, error) {
+	config := DefaultConfig()
 
-	// Look for config in ~/.config/rlm/
-	home, err := os.UserHomeDir()
-	if err == nil {
-		viper.AddConfigPath(filepath.Join(home, ".config", "rlm"))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
 	}
 
-	// Also look in current directory
-	viper.AddConfigPath(".")
+	configType := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	switch configType {
+	case "", "yml":
+		configType = "yaml"
+	case "jsonc", "json5":
+		configType = "json"
+	}
+	if configType == "json" {
+		data = stripJSONComments(data)
+	}
 
-	// Read config file if it exists
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, err
-		}
-		// Config file not found; use defaults
+	v := viper.New()
+	v.SetConfigType(configType)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
 	}
 
-	// Unmarshal into config struct
-	if err := viper.Unmarshal(config); err != nil {
-		return nil, err
+	if err := v.Unmarshal(config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
 	}
 
 	return config, nil
@@ -105,6 +482,19 @@ func (c *OrchestratorConfig) CacheTTL() time.Duration {
 	return time.Duration(c.CacheTTLHours) * time.Hour
 }
 
+// MaxDurationValue parses MaxDuration. Returns 0 (unlimited) when unset
+// or invalid.
+func (c *OrchestratorConfig) MaxDurationValue() time.Duration {
+	if c.MaxDuration == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(c.MaxDuration)
+	if err != nil || duration < 0 {
+		return 0
+	}
+	return duration
+}
+
 // CheckIntervalDuration returns the check interval as a duration
 func (c *UpdaterConfig) CheckIntervalDuration() time.Duration {
 	duration, err := time.ParseDuration(c.CheckInterval)
@@ -113,3 +503,55 @@ func (c *UpdaterConfig) CheckIntervalDuration() time.Duration {
 	}
 	return duration
 }
+
+// JitterDuration parses Jitter. Returns 0 (no jitter) when unset or
+// invalid.
+func (c *UpdaterConfig) JitterDuration() time.Duration {
+	if c.Jitter == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(c.Jitter)
+	if err != nil || duration < 0 {
+		return 0
+	}
+	return duration
+}
+
+// AnalysisTTLDuration returns the analysis TTL as a duration.
+// A zero duration means stored analyses never expire.
+func (c *StorageConfig) AnalysisTTLDuration() time.Duration {
+	if c.AnalysisTTL == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(c.AnalysisTTL)
+	if err != nil || duration < 0 {
+		return 0
+	}
+	return duration
+}
+
+// RecencyHalfLifeDuration parses RecencyHalfLife. Returns 0 (no recency
+// boost) when unset or invalid.
+func (c *StorageConfig) RecencyHalfLifeDuration() time.Duration {
+	if c.RecencyHalfLife == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(c.RecencyHalfLife)
+	if err != nil || duration < 0 {
+		return 0
+	}
+	return duration
+}
+
+// IdempotencyWindowDuration parses IdempotencyWindow. Returns 0 (disabled)
+// when unset or invalid.
+func (c *MCPConfig) IdempotencyWindowDuration() time.Duration {
+	if c.IdempotencyWindow == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(c.IdempotencyWindow)
+	if err != nil || duration < 0 {
+		return 0
+	}
+	return duration
+}
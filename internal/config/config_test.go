@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFileFormats(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+# Keep analyses for a month
+offline: true
+orchestrator:
+  max_recursion_depth: 4
+  processors: [redact_secrets, trim_whitespace]
+storage:
+  rag_dir: /data/rlm # shared store
+  index_fields: [query, content]
+mcp:
+  focuses: [security, performance]
+  rate_limits:
+    rlm_analyze:
+      per_minute: 2
+      burst: 1
+`,
+		"config.json": `{
+  // Keep analyses for a month
+  "offline": true,
+  "orchestrator": {
+    "max_recursion_depth": 4,
+    "processors": ["redact_secrets", "trim_whitespace",],
+  },
+  /* shared store */
+  "storage": {"rag_dir": "/data/rlm", "index_fields": ["query", "content"]},
+  "mcp": {
+    "focuses": ["security", "performance"],
+    "rate_limits": {"rlm_analyze": {"per_minute": 2, "burst": 1}}
+  }
+}`,
+		"config.toml": `
+# Keep analyses for a month
+offline = true
+
+[orchestrator]
+max_recursion_depth = 4
+processors = ["redact_secrets", "trim_whitespace"]
+
+[storage]
+rag_dir = "/data/rlm" # shared store
+index_fields = ["query", "content"]
+
+[mcp]
+focuses = ["security", "performance"]
+
+[mcp.rate_limits.rlm_analyze]
+per_minute = 2
+burst = 1
+`,
+	}
+
+	dir := t.TempDir()
+	loaded := make(map[string]*Config, len(files))
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+		cfg, err := LoadFile(path)
+		require.NoError(t, err, name)
+		loaded[name] = cfg
+	}
+
+	yaml := loaded["config.yaml"]
+	assert.True(t, yaml.Offline)
+	assert.Equal(t, 4, yaml.Orchestrator.MaxRecursionDepth)
+	assert.Equal(t, "/data/rlm", yaml.Storage.RAGDir)
+	assert.Equal(t, RateLimitConfig{PerMinute: 2, Burst: 1}, yaml.MCP.RateLimits["rlm_analyze"])
+	// Unset values keep their defaults
+	assert.Equal(t, DefaultConfig().Orchestrator.MaxIterations, yaml.Orchestrator.MaxIterations)
+
+	assert.Equal(t, yaml, loaded["config.json"])
+	assert.Equal(t, yaml, loaded["config.toml"])
+}
+
+func TestStripJSONComments(t *testing.T) {
+	input := `{"url": "http://example.com/*not a comment*/", // trailing
+"list": [1, 2,], /* block */ "escaped": "say \"//hi\""}`
+	assert.JSONEq(t,
+		`{"url": "http://example.com/*not a comment*/", "list": [1, 2], "escaped": "say \"//hi\""}`,
+		string(stripJSONComments([]byte(input))))
+}
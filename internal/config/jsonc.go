@@ -0,0 +1,60 @@
+package config
+
+// stripJSONComments removes // and /* */ comments and trailing commas before
+// a closing bracket or brace from JSON, leaving string contents untouched,
+// so commented configs parse as standard JSON
+func stripJSONComments(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	pendingComma := -1 // index in out of a comma that may be trailing
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out = append(out, '\n')
+			}
+			continue
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++ // Skip the closing slash
+			out = append(out, ' ')
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			out = append(out, c)
+			continue
+		case (c == '}' || c == ']') && pendingComma >= 0:
+			out[pendingComma] = ' '
+		}
+
+		pendingComma = -1
+		if c == ',' {
+			pendingComma = len(out)
+		}
+		if c == '"' {
+			inString = true
+		}
+		out = append(out, c)
+	}
+
+	return out
+}
@@ -0,0 +1,67 @@
+// Package fsutil holds file helpers shared by the stores that persist
+// analyses and orchestrator state.
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// syncFile flushes a file to stable storage; replaced in tests
+var syncFile = (*os.File).Sync
+
+// WriteFile writes data to path like os.WriteFile. With sync set the write
+// is durable and atomic instead: data goes to a temporary file in the same
+// directory, which is fsynced and renamed over path, and the directory is
+// fsynced so the rename itself survives a power loss. Readers never see a
+// partially written file.
+func WriteFile(path string, data []byte, perm os.FileMode, sync bool) error {
+	if !sync {
+		return os.WriteFile(path, data, perm)
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := syncFile(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return SyncDir(dir)
+}
+
+// SyncDir fsyncs a directory so renames and removals in it are durable.
+// Windows can't sync directories, so it is a no-op there.
+func SyncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return syncFile(d)
+}
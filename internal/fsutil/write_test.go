@@ -0,0 +1,65 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countSyncs records the paths syncFile is called on until the test ends
+func countSyncs(t *testing.T) *[]string {
+	synced := new([]string)
+	previous := syncFile
+	syncFile = func(f *os.File) error {
+		*synced = append(*synced, f.Name())
+		return previous(f)
+	}
+	t.Cleanup(func() { syncFile = previous })
+	return synced
+}
+
+func TestWriteFileSync(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directories can't be synced on windows")
+	}
+	synced := countSyncs(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0644))
+	require.NoError(t, WriteFile(path, []byte("new"), 0600, true))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The data is synced before the rename, then the directory after it
+	require.Len(t, *synced, 2)
+	assert.Equal(t, dir, filepath.Dir((*synced)[0]))
+	assert.Equal(t, dir, (*synced)[1])
+
+	// No temporary file is left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestWriteFileNoSync(t *testing.T) {
+	synced := countSyncs(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	require.NoError(t, WriteFile(path, []byte("data"), 0644, false))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.Empty(t, *synced)
+}
@@ -0,0 +1,63 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	gohash "hash"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// File hashing algorithms. Staleness checks only need to detect changes,
+// so the much faster CRC32C is enough where collision resistance against
+// deliberate tampering doesn't matter.
+const (
+	SHA256 = "sha256"
+	CRC32C = "crc32c"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ParseAlgorithm validates a hashing algorithm name. Empty is SHA256, the
+// algorithm of analyses stored before the choice existed.
+func ParseAlgorithm(name string) (string, error) {
+	switch name {
+	case "", SHA256:
+		return SHA256, nil
+	case CRC32C:
+		return CRC32C, nil
+	default:
+		return "", fmt.Errorf("unknown hash algorithm %q (expected %s or %s)", name, SHA256, CRC32C)
+	}
+}
+
+// newHash returns a hash.Hash for a validated algorithm name
+func newHash(algorithm string) gohash.Hash {
+	if algorithm == CRC32C {
+		return crc32.New(castagnoli)
+	}
+	return sha256.New()
+}
+
+// ComputeFileHashWith returns the hex hash of a single file using algorithm
+func ComputeFileHashWith(filePath, algorithm string) (string, error) {
+	algorithm, err := ParseAlgorithm(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := newHash(algorithm)
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,83 @@
+package hash_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashAlgorithms(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main"})
+	path := filepath.Join(dir, "main.go")
+
+	sha, err := hash.ComputeFileHashWith(path, hash.SHA256)
+	require.NoError(t, err)
+	assert.Len(t, sha, 64)
+
+	crc, err := hash.ComputeFileHashWith(path, hash.CRC32C)
+	require.NoError(t, err)
+	assert.Len(t, crc, 8)
+
+	// Empty means SHA256, the algorithm of older analyses
+	legacy, err := hash.ComputeFileHashWith(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, sha, legacy)
+
+	_, err = hash.ParseAlgorithm("md5")
+	assert.ErrorContains(t, err, `unknown hash algorithm "md5"`)
+}
+
+func TestCheckStalenessUsesStoredAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main", "util.go": "package main"})
+
+	stored, err := hash.NewFileHasher(hash.Options{}).ComputeDirectoryHash(dir)
+	require.NoError(t, err)
+
+	// The configured algorithm changed since the analysis was stored
+	opts := hash.Options{Algorithm: hash.CRC32C}
+	hasher := hash.NewFileHasher(opts)
+	assert.Equal(t, hash.CRC32C, hasher.Algorithm())
+	current, err := hasher.ComputeDirectoryHash(dir)
+	require.NoError(t, err)
+	require.NotEqual(t, stored["main.go"], current["main.go"])
+
+	// The tree is re-hashed with the stored algorithm, so nothing looks
+	// changed, and a real change is still caught
+	report, err := hash.CheckStaleness(opts, stored, hash.SHA256, dir, time.Now())
+	require.NoError(t, err)
+	assert.False(t, report.Stale)
+
+	report, err = hash.CheckStaleness(opts, stored, "", dir, time.Now())
+	require.NoError(t, err)
+	assert.False(t, report.Stale)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "util.go"), []byte("package util"), 0644))
+	report, err = hash.CheckStaleness(opts, stored, hash.SHA256, dir, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"util.go"}, report.ChangedFiles)
+}
+
+func BenchmarkComputeFileHash(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "large.txt")
+	content := strings.Repeat("func handler(w http.ResponseWriter, r *http.Request) {}\n", 1<<15)
+	require.NoError(b, os.WriteFile(path, []byte(content), 0644))
+
+	for _, algorithm := range []string{hash.SHA256, hash.CRC32C} {
+		b.Run(algorithm, func(b *testing.B) {
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				if _, err := hash.ComputeFileHashWith(path, algorithm); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package hash
+
+import "sort"
+
+// Rename is a file that moved without its content changing
+type Rename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// HashDiff describes how a tree changed between two sets of file hashes.
+// A deleted and a new file with identical content are reported as a
+// rename rather than as both. All lists are sorted.
+type HashDiff struct {
+	Changed []string `json:"changed"`
+	New     []string `json:"new"`
+	Deleted []string `json:"deleted"`
+	Renamed []Rename `json:"renamed"`
+}
+
+// Total returns the number of files that changed in any way
+func (d *HashDiff) Total() int {
+	return len(d.Changed) + len(d.New) + len(d.Deleted) + len(d.Renamed)
+}
+
+// DiffHashes compares two file hash maps, pairing deleted files with new
+// files of the same content as renames
+func DiffHashes(old, new map[string]string) *HashDiff {
+	diff := &HashDiff{
+		Changed: make([]string, 0),
+		New:     make([]string, 0),
+		Deleted: make([]string, 0),
+		Renamed: make([]Rename, 0),
+	}
+
+	for path, newHash := range new {
+		if oldHash, exists := old[path]; exists && oldHash != newHash {
+			diff.Changed = append(diff.Changed, path)
+		}
+	}
+
+	// Index deleted files by content so new files can claim them
+	deleted := FindDeletedFiles(old, new)
+	sort.Strings(deleted)
+	byHash := make(map[string][]string)
+	for _, path := range deleted {
+		byHash[old[path]] = append(byHash[old[path]], path)
+	}
+
+	added := FindNewFiles(old, new)
+	sort.Strings(added)
+	for _, path := range added {
+		if from := byHash[new[path]]; len(from) > 0 {
+			diff.Renamed = append(diff.Renamed, Rename{From: from[0], To: path})
+			byHash[new[path]] = from[1:]
+			continue
+		}
+		diff.New = append(diff.New, path)
+	}
+
+	for _, path := range deleted {
+		if remaining := byHash[old[path]]; len(remaining) > 0 && remaining[0] == path {
+			diff.Deleted = append(diff.Deleted, path)
+			byHash[old[path]] = remaining[1:]
+		}
+	}
+
+	sort.Strings(diff.Changed)
+	return diff
+}
@@ -0,0 +1,38 @@
+package hash_test
+
+import (
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffHashes(t *testing.T) {
+	old := map[string]string{
+		"main.go":      "aaa",
+		"util.go":      "bbb",
+		"old/name.go":  "ccc",
+		"removed.go":   "ddd",
+		"copy1.go":     "eee",
+		"unchanged.md": "fff",
+	}
+	new := map[string]string{
+		"main.go":      "aa2",
+		"util.go":      "bbb",
+		"new/name.go":  "ccc",
+		"added.go":     "ggg",
+		"copy2.go":     "eee",
+		"copy3.go":     "eee",
+		"unchanged.md": "fff",
+	}
+
+	diff := hash.DiffHashes(old, new)
+	assert.Equal(t, []string{"main.go"}, diff.Changed)
+	assert.Equal(t, []hash.Rename{{From: "copy1.go", To: "copy2.go"}, {From: "old/name.go", To: "new/name.go"}}, diff.Renamed)
+	// Only one new file can claim a deleted file's content
+	assert.Equal(t, []string{"added.go", "copy3.go"}, diff.New)
+	assert.Equal(t, []string{"removed.go"}, diff.Deleted)
+	assert.Equal(t, 6, diff.Total())
+
+	assert.Zero(t, hash.DiffHashes(old, old).Total())
+}
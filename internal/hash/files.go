@@ -1,79 +1,105 @@
 package hash
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"bytes"
+	"context"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
-// FileHasher computes SHA256 hashes of files
+// FileHasher computes hashes of files, SHA256 unless configured otherwise
 type FileHasher struct {
-	excludeDirs []string
-	patterns    []string
+	excludeDirs  []string
+	excludePaths []string // Canonical directories skipped wherever they are
+	patterns     []string
+	generated    *GeneratedFilter
+	skipped      []string
+	algorithm    string
+	walkWorkers  int
 }
 
-// NewFileHasher creates a new file hasher with default patterns
-func NewFileHasher() *FileHasher {
-	return &FileHasher{
+// Options configure a FileHasher. The zero value hashes the PresetAll
+// patterns with SHA256. Analyses and their staleness checks must hash with
+// the same options to see the same files.
+type Options struct {
+	Algorithm    string           // Hashing algorithm, see ParseAlgorithm; empty is SHA256
+	Patterns     []string         // File patterns to hash; nil uses the PresetAll patterns
+	Generated    *GeneratedFilter // Skips generated and minified files; nil skips nothing
+	ExcludePaths []string         // Directories skipped by location rather than name, such as a renamed RAG directory
+	WalkWorkers  int              // Directories read at once; 0 is DefaultWalkWorkers
+}
+
+// NewFileHasher creates a new file hasher configured by opts
+func NewFileHasher(opts Options) *FileHasher {
+	patterns := opts.Patterns
+	if patterns == nil {
+		patterns = presets[PresetAll]
+	}
+	algorithm := opts.Algorithm
+	if algorithm == "" {
+		algorithm = SHA256
+	}
+	workers := opts.WalkWorkers
+	if workers <= 0 {
+		workers = DefaultWalkWorkers
+	}
+
+	h := &FileHasher{
 		excludeDirs: []string{".git", "node_modules", ".rlm", ".rlm_cache", "vendor", "dist", "build"},
-		patterns: []string{
-			"*.py", "*.js", "*.ts", "*.tsx", "*.jsx",
-			"*.go", "*.rs", "*.java", "*.c", "*.cpp", "*.h",
-			"*.md", "*.txt", "*.json", "*.yaml", "*.yml",
-			"*.html", "*.css", "*.scss", "*.sass",
-		},
+		patterns:    append([]string(nil), patterns...),
+		generated:   opts.Generated,
+		algorithm:   algorithm,
+		walkWorkers: workers,
 	}
+	for _, path := range opts.ExcludePaths {
+		h.AddExcludePath(path)
+	}
+	return h
 }
 
 // ComputeFileHash returns the SHA256 hash of a single file
 func ComputeFileHash(filePath string) (string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	hasher := sha256.New()
-	if _, err := io.Copy(hasher, file); err != nil {
-		return "", err
-	}
+	return ComputeFileHashWith(filePath, SHA256)
+}
 
-	return hex.EncodeToString(hasher.Sum(nil)), nil
+// FileTypeStat counts the files and bytes of one file type
+type FileTypeStat struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
 }
 
-// ComputeDirectoryHash returns a map of file paths to their SHA256 hashes
-func (h *FileHasher) ComputeDirectoryHash(dirPath string) (map[string]string, error) {
-	hashes := make(map[string]string)
+// NoExtension is the FileStats key for files without an extension
+const NoExtension = "(none)"
 
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+// ComputeDirectoryHash returns a map of file paths to their hashes
+func (h *FileHasher) ComputeDirectoryHash(dirPath string) (map[string]string, error) {
+	hashes, _, err := h.ComputeDirectoryHashWithStats(dirPath)
+	return hashes, err
+}
 
-		// Skip directories
-		if info.IsDir() {
-			// Check if this directory should be excluded
-			dirName := filepath.Base(path)
-			for _, excluded := range h.excludeDirs {
-				if dirName == excluded {
-					return filepath.SkipDir
-				}
-			}
-			return nil
-		}
+// ComputeDirectoryHashWithStats hashes dirPath like ComputeDirectoryHash and
+// also returns per-extension file counts and sizes from the same walk.
+// Extensions are lowercase with the leading dot, e.g. ".go".
+func (h *FileHasher) ComputeDirectoryHashWithStats(dirPath string) (map[string]string, map[string]FileTypeStat, error) {
+	return h.ComputeDirectoryHashWithStatsContext(context.Background(), dirPath)
+}
 
-		// Check if file matches any pattern
-		if !h.matchesPattern(path) {
-			return nil
-		}
+// ComputeDirectoryHashWithStatsContext is ComputeDirectoryHashWithStats,
+// stopping with ctx's error once ctx is done
+func (h *FileHasher) ComputeDirectoryHashWithStatsContext(ctx context.Context, dirPath string) (map[string]string, map[string]FileTypeStat, error) {
+	hashes := make(map[string]string)
+	stats := make(map[string]FileTypeStat)
 
+	err := h.walkMatching(ctx, dirPath, func(path string, info os.FileInfo) error {
 		// Compute hash
-		hash, err := ComputeFileHash(path)
+		hash, err := ComputeFileHashWith(path, h.algorithm)
 		if err != nil {
-			// Skip files that can't be read
+			// Skip files that can't be read, but remember them
+			h.skip(dirPath, path)
 			return nil
 		}
 
@@ -84,10 +110,45 @@ func (h *FileHasher) ComputeDirectoryHash(dirPath string) (map[string]string, er
 		}
 
 		hashes[relPath] = hash
+
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext == "" {
+			ext = NoExtension
+		}
+		stat := stats[ext]
+		stat.Files++
+		stat.Bytes += info.Size()
+		stats[ext] = stat
 		return nil
 	})
 
-	return hashes, err
+	return hashes, stats, err
+}
+
+// FileEntry describes a file found under a directory
+type FileEntry struct {
+	Path string // Relative to the walked directory, slash-separated
+	Size int64
+}
+
+// ListFiles returns the files under dirPath that match the configured
+// patterns, sorted by path, using the same traversal as
+// ComputeDirectoryHash
+func (h *FileHasher) ListFiles(dirPath string) ([]FileEntry, error) {
+	files := make([]FileEntry, 0)
+
+	err := h.walkMatching(context.Background(), dirPath, func(path string, info os.FileInfo) error {
+		relPath, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			relPath = path
+		}
+
+		files = append(files, FileEntry{Path: filepath.ToSlash(relPath), Size: info.Size()})
+		return nil
+	})
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, err
 }
 
 // matchesPattern checks if a file matches any of the configured patterns
@@ -109,9 +170,17 @@ func (h *FileHasher) ComputeQuickHash(dirPath string, maxFiles int) (map[string]
 	hashes := make(map[string]string)
 	count := 0
 
+	excluded := h.dirExcluder(dirPath)
 	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			return err
+			if path == dirPath {
+				return err
+			}
+			h.skip(dirPath, path)
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 
 		if count >= maxFiles {
@@ -120,23 +189,21 @@ func (h *FileHasher) ComputeQuickHash(dirPath string, maxFiles int) (map[string]
 
 		// Skip directories
 		if info.IsDir() {
-			dirName := filepath.Base(path)
-			for _, excluded := range h.excludeDirs {
-				if dirName == excluded {
-					return filepath.SkipDir
-				}
+			if excluded(path) {
+				return filepath.SkipDir
 			}
 			return nil
 		}
 
 		// Check if file matches any pattern
-		if !h.matchesPattern(path) {
+		if !h.matchesPattern(path) || h.isGenerated(path) {
 			return nil
 		}
 
 		// Compute hash
-		hash, err := ComputeFileHash(path)
+		hash, err := ComputeFileHashWith(path, h.algorithm)
 		if err != nil {
+			h.skip(dirPath, path)
 			return nil
 		}
 
@@ -155,16 +222,93 @@ func (h *FileHasher) ComputeQuickHash(dirPath string, maxFiles int) (map[string]
 	return hashes, err
 }
 
+// SetAlgorithm sets the hashing algorithm (see ParseAlgorithm)
+func (h *FileHasher) SetAlgorithm(algorithm string) {
+	h.algorithm = algorithm
+}
+
+// Algorithm returns the hashing algorithm in use
+func (h *FileHasher) Algorithm() string {
+	return h.algorithm
+}
+
 // SetPatterns allows customizing file patterns to hash
 func (h *FileHasher) SetPatterns(patterns []string) {
 	h.patterns = patterns
 }
 
+// SetGeneratedFilter skips files the filter recognizes as generated or
+// minified. Nil skips nothing.
+func (h *FileHasher) SetGeneratedFilter(filter *GeneratedFilter) {
+	h.generated = filter
+}
+
+// isGenerated reports whether path should be skipped as generated
+func (h *FileHasher) isGenerated(path string) bool {
+	return h.generated != nil && h.generated.IsGenerated(path)
+}
+
+// SkippedFiles returns the files and directories this hasher had to skip
+// because they could not be read, relative to the walked directory, so
+// callers can report the blind spots of an analysis
+func (h *FileHasher) SkippedFiles() []string {
+	return h.skipped
+}
+
+// skip records an unreadable path under dirPath
+func (h *FileHasher) skip(dirPath, path string) {
+	relPath, err := filepath.Rel(dirPath, path)
+	if err != nil {
+		relPath = path
+	}
+	h.skipped = append(h.skipped, relPath)
+}
+
 // SetExcludeDirs allows customizing directories to exclude
 func (h *FileHasher) SetExcludeDirs(dirs []string) {
 	h.excludeDirs = dirs
 }
 
+// AddExcludePath excludes the directory at path, however it is reached,
+// in addition to the directories excluded by name
+func (h *FileHasher) AddExcludePath(path string) {
+	h.excludePaths = append(h.excludePaths, CanonicalPath(path))
+}
+
+// dirExcluder returns a function reporting whether a directory found
+// walking root is excluded, by name or by location
+func (h *FileHasher) dirExcluder(root string) func(path string) bool {
+	canonicalRoot := ""
+	if len(h.excludePaths) > 0 {
+		canonicalRoot = CanonicalPath(root)
+	}
+
+	return func(path string) bool {
+		dirName := filepath.Base(path)
+		for _, excluded := range h.excludeDirs {
+			if dirName == excluded {
+				return true
+			}
+		}
+		if len(h.excludePaths) == 0 {
+			return false
+		}
+
+		// The walk doesn't follow symlinks, so the directory's location is
+		// its path relative to the canonical root
+		location := CanonicalPath(path)
+		if rel, err := filepath.Rel(root, path); err == nil {
+			location = filepath.Join(canonicalRoot, rel)
+		}
+		for _, excluded := range h.excludePaths {
+			if location == excluded {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 // HashesEqual compares two hash maps
 func HashesEqual(a, b map[string]string) bool {
 	if len(a) != len(b) {
@@ -228,6 +372,39 @@ func FormatHash(hash string) string {
 	return hash
 }
 
+// binarySniffLength is how much of a file IsBinaryFile inspects
+const binarySniffLength = 8000
+
+// IsBinaryFile reports whether a file's content looks binary: its first
+// bytes hold a NUL byte or are not valid UTF-8. Unlike IsTextFile it reads
+// the file, so it catches binary files with text extensions. Unreadable
+// files are not reported as binary.
+func IsBinaryFile(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	buf := make([]byte, binarySniffLength)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false
+	}
+	buf = buf[:n]
+	if bytes.IndexByte(buf, 0) >= 0 {
+		return true
+	}
+
+	// A multi-byte character may be cut off at the end of the sample
+	if n == binarySniffLength {
+		for i := 0; i < utf8.UTFMax && len(buf) > 0 && !utf8.Valid(buf); i++ {
+			buf = buf[:len(buf)-1]
+		}
+	}
+	return !utf8.Valid(buf)
+}
+
 // IsTextFile checks if a file is likely a text file based on extension
 func IsTextFile(filePath string) bool {
 	ext := strings.ToLower(filepath.Ext(filePath))
@@ -0,0 +1,80 @@
+package hash_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasherReportsSkippedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main"})
+
+	// A dangling symlink can't be read even by root, unlike chmod 000
+	require.NoError(t, os.Symlink(filepath.Join(dir, "gone.go"), filepath.Join(dir, "broken.go")))
+
+	hasher := hash.NewFileHasher(hash.Options{})
+	hashes, err := hasher.ComputeDirectoryHash(dir)
+	require.NoError(t, err)
+	assert.Len(t, hashes, 1)
+	assert.Equal(t, []string{"broken.go"}, hasher.SkippedFiles())
+
+	quick := hash.NewFileHasher(hash.Options{})
+	_, err = quick.ComputeQuickHash(dir, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"broken.go"}, quick.SkippedFiles())
+}
+
+func TestIsBinaryFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go":   "package main\n\n// Grüße\n",
+		"data.json": "{\"a\":\x00\x01}",
+		"blob.txt":  "\xff\xfe\xfd",
+		"empty.md":  "",
+	})
+
+	assert.False(t, hash.IsBinaryFile(filepath.Join(dir, "main.go")))
+	assert.True(t, hash.IsBinaryFile(filepath.Join(dir, "data.json")))
+	assert.True(t, hash.IsBinaryFile(filepath.Join(dir, "blob.txt")))
+	assert.False(t, hash.IsBinaryFile(filepath.Join(dir, "empty.md")))
+	assert.False(t, hash.IsBinaryFile(filepath.Join(dir, "missing.go")))
+}
+
+func TestHasherExcludesCustomRAGDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go":                  "package main",
+		"store/analysis_1.json":    `{"id":"1"}`,
+		"store/index.json":         `{}`,
+		"pkg/store/handler.go":     "package store",
+		"docs/rag-notes/readme.md": "# Notes",
+	})
+
+	opts := hash.Options{ExcludePaths: []string{filepath.Join(dir, "store")}}
+
+	// Only the RAG dir itself is excluded, not other directories of that name
+	hashes, err := hash.NewFileHasher(opts).ComputeDirectoryHash(dir)
+	require.NoError(t, err)
+	var files []string
+	for path := range hashes {
+		files = append(files, filepath.ToSlash(path))
+	}
+	assert.ElementsMatch(t, []string{"main.go", "pkg/store/handler.go", "docs/rag-notes/readme.md"}, files)
+
+	quick, err := hash.NewFileHasher(opts).ComputeQuickHash(dir, 100)
+	require.NoError(t, err)
+	assert.Len(t, quick, 3)
+
+	// Writing analyses to the RAG dir doesn't make the path stale
+	writeFiles(t, dir, map[string]string{"store/analysis_2.json": `{"id":"2"}`})
+	report, err := hash.CheckStaleness(opts, hashes, hash.SHA256, dir, time.Now())
+	require.NoError(t, err)
+	assert.False(t, report.Stale)
+	assert.Zero(t, report.TotalChanges)
+}
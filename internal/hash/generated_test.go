@@ -0,0 +1,86 @@
+package hash_test
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsGenerated(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go":           "package main\n\nfunc main() {}\n",
+		"zz_generated.go":   "// Code generated by controller-gen. DO NOT EDIT.\n\npackage api\n",
+		"schema.ts":         "/**\n * @generated\n */\nexport type A = string\n",
+		"bundle.js":         "!function(){" + strings.Repeat("var a=1;", 200) + "}();\n",
+		"app.min.css":       "body{margin:0}",
+		"package-lock.json": "{}",
+		"notes.md":          "Code generated here is fine to edit.\n",
+	})
+
+	filter := &hash.GeneratedFilter{}
+	for name, generated := range map[string]bool{
+		"main.go":           false,
+		"zz_generated.go":   true,
+		"schema.ts":         true,
+		"bundle.js":         true,
+		"app.min.css":       true,
+		"package-lock.json": true,
+		"notes.md":          false,
+	} {
+		assert.Equal(t, generated, filter.IsGenerated(filepath.Join(dir, name)), name)
+	}
+
+	// The line length threshold is configurable
+	assert.False(t, (&hash.GeneratedFilter{MaxLineLength: 5000}).IsGenerated(filepath.Join(dir, "bundle.js")))
+	assert.True(t, (&hash.GeneratedFilter{MaxLineLength: 10}).IsGenerated(filepath.Join(dir, "notes.md")))
+}
+
+func TestHasherSkipsGenerated(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go":      "package main",
+		"generated.go": "// Code generated by stringer; DO NOT EDIT.\n\npackage main\n",
+		"vendor.js":    strings.Repeat("x", 2000),
+	})
+
+	hasher := hash.NewFileHasher(hash.Options{})
+	hashes, err := hasher.ComputeDirectoryHash(dir)
+	require.NoError(t, err)
+	assert.Len(t, hashes, 3)
+
+	hasher.SetGeneratedFilter(&hash.GeneratedFilter{})
+	hashes, err = hasher.ComputeDirectoryHash(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.go"}, keys(hashes))
+
+	quick, err := hasher.ComputeQuickHash(dir, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.go"}, keys(quick))
+
+	// Hashers created with the filter, including the staleness check's,
+	// skip the same files
+	opts := hash.Options{Generated: &hash.GeneratedFilter{}}
+	size, err := hash.NewFileHasher(opts).MeasureTree(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, size.Files)
+
+	report, err := hash.CheckStaleness(opts, hashes, hash.SHA256, dir, time.Now())
+	require.NoError(t, err)
+	assert.False(t, report.Stale)
+}
+
+func keys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
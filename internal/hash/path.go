@@ -0,0 +1,84 @@
+package hash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CanonicalPath returns an absolute, cleaned, symlink-resolved form of p so
+// that different spellings of the same location (./src, src, /abs/src, a
+// symlink to it) compare equal. Parts that cannot be resolved, such as a
+// path that no longer exists, are left as cleaned absolute paths.
+func CanonicalPath(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return filepath.Clean(p)
+	}
+
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
+
+// SamePath reports whether a and b refer to the same location
+func SamePath(a, b string) bool {
+	return CanonicalPath(a) == CanonicalPath(b)
+}
+
+// Within reports whether p is root or inside it, after both are made
+// canonical, so ".." segments and symlinks can't escape root. p need not
+// exist yet, e.g. a file about to be written.
+func Within(p, root string) bool {
+	target := CanonicalPath(p)
+	if _, err := os.Lstat(target); err != nil {
+		target = filepath.Join(CanonicalPath(filepath.Dir(target)), filepath.Base(target))
+	}
+
+	rel, err := filepath.Rel(CanonicalPath(root), target)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// DefaultRootMarkers are files marking the root of a package or module
+var DefaultRootMarkers = []string{
+	"go.mod", "package.json", "Cargo.toml", "pyproject.toml", "setup.py",
+	"pom.xml", "build.gradle", "build.gradle.kts", "composer.json", "Gemfile",
+}
+
+// FindPackageRoot returns the nearest directory at or above p containing
+// one of the marker files, i.e. the root of the package p belongs to. A
+// file starts the search from its directory. Nil markers use
+// DefaultRootMarkers.
+func FindPackageRoot(p string, markers []string) (string, error) {
+	if len(markers) == 0 {
+		markers = DefaultRootMarkers
+	}
+
+	dir := CanonicalPath(p)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	for {
+		for _, marker := range markers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return dir, nil
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no package root (%v) found above %s", markers, p)
+		}
+		dir = parent
+	}
+}
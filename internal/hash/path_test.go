@@ -0,0 +1,87 @@
+package hash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalPath(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	src := filepath.Join(root, "src")
+	require.NoError(t, os.Mkdir(src, 0755))
+	require.NoError(t, os.Symlink(src, filepath.Join(root, "link")))
+	t.Chdir(root)
+
+	for _, spelling := range []string{"src", "./src", "src/", "src/../src", src, "link"} {
+		assert.Equal(t, src, CanonicalPath(spelling), spelling)
+	}
+
+	// Missing paths still normalize
+	assert.Equal(t, filepath.Join(root, "gone"), CanonicalPath("./gone/"))
+	assert.True(t, SamePath("./src", filepath.Join(root, "link")))
+	assert.False(t, SamePath("src", "gone"))
+}
+
+func TestFindPackageRoot(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+
+	// A monorepo with a Go module at the top and a JS package inside it
+	files := map[string]string{
+		"go.mod":                            "module example.com/mono",
+		"services/api/handler.go":           "package api",
+		"web/app/package.json":              "{}",
+		"web/app/src/components/button.tsx": "export {}",
+		"tools/BUILD.marker":                "",
+		"tools/gen/main.go":                 "package main",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	tests := []struct {
+		path    string
+		markers []string
+		want    string
+	}{
+		{"web/app/src/components/button.tsx", nil, "web/app"},
+		{"web/app/src", nil, "web/app"},
+		{"web/app", nil, "web/app"},
+		{"services/api/handler.go", nil, "."},
+		{"tools/gen/main.go", []string{"BUILD.marker"}, "tools"},
+	}
+	for _, tt := range tests {
+		got, err := FindPackageRoot(filepath.Join(root, filepath.FromSlash(tt.path)), tt.markers)
+		require.NoError(t, err, tt.path)
+		assert.Equal(t, filepath.Join(root, tt.want), got, tt.path)
+	}
+
+	// No marker anywhere above the path
+	_, err = FindPackageRoot(filepath.Join(root, "web"), []string{"no-such-marker"})
+	assert.Error(t, err)
+}
+
+func TestWithin(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	allowed := filepath.Join(root, "allowed")
+	require.NoError(t, os.MkdirAll(filepath.Join(allowed, "src"), 0755))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "secret"), 0755))
+	require.NoError(t, os.Symlink(filepath.Join(root, "secret"), filepath.Join(allowed, "escape")))
+
+	assert.True(t, Within(allowed, allowed))
+	assert.True(t, Within(filepath.Join(allowed, "src"), allowed))
+	assert.True(t, Within(filepath.Join(allowed, "src", "new.md"), allowed), "paths that don't exist yet")
+
+	assert.False(t, Within(filepath.Join(root, "secret"), allowed))
+	assert.False(t, Within(allowed+"/src/../../secret", allowed))
+	assert.False(t, Within(filepath.Join(allowed, "escape"), allowed), "symlinks out of the root")
+	assert.False(t, Within(allowed+"-sibling", allowed))
+}
@@ -0,0 +1,70 @@
+package hash
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// File pattern presets, naming the files hashed and analyzed for a kind
+// of project
+const (
+	PresetGo   = "go"
+	PresetWeb  = "web"
+	PresetDocs = "docs"
+	PresetAll  = "all"
+)
+
+var presets = map[string][]string{
+	PresetGo: {"*.go", "go.mod"},
+	PresetWeb: {
+		"*.js", "*.ts", "*.tsx", "*.jsx",
+		"*.json", "*.html", "*.css", "*.scss", "*.sass",
+	},
+	PresetDocs: {"*.md", "*.txt", "*.rst", "*.adoc"},
+	PresetAll: {
+		"*.py", "*.js", "*.ts", "*.tsx", "*.jsx",
+		"*.go", "*.rs", "*.java", "*.c", "*.cpp", "*.h",
+		"*.md", "*.txt", "*.json", "*.yaml", "*.yml",
+		"*.html", "*.css", "*.scss", "*.sass",
+	},
+}
+
+// Presets returns the names of the file pattern presets, sorted
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolvePatterns returns the patterns of the named preset followed by
+// extra ones not already in it. An empty preset is PresetAll.
+func ResolvePatterns(preset string, extra []string) ([]string, error) {
+	if preset == "" {
+		preset = PresetAll
+	}
+	base, ok := presets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown file pattern preset %q (expected one of %s)", preset, strings.Join(Presets(), ", "))
+	}
+
+	patterns := append([]string(nil), base...)
+	seen := make(map[string]bool, len(patterns))
+	for _, pattern := range patterns {
+		seen[pattern] = true
+	}
+	for _, pattern := range extra {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+		}
+		if !seen[pattern] {
+			seen[pattern] = true
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns, nil
+}
@@ -0,0 +1,69 @@
+package hash_test
+
+import (
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePatterns(t *testing.T) {
+	for preset, want := range map[string][]string{
+		hash.PresetGo:   {"*.go", "go.mod"},
+		hash.PresetWeb:  {"*.js", "*.ts", "*.tsx", "*.jsx", "*.json", "*.html", "*.css", "*.scss", "*.sass"},
+		hash.PresetDocs: {"*.md", "*.txt", "*.rst", "*.adoc"},
+	} {
+		patterns, err := hash.ResolvePatterns(preset, nil)
+		require.NoError(t, err, preset)
+		assert.Equal(t, want, patterns, preset)
+	}
+
+	// all, also the default, is the fixed list hashed before presets existed
+	before := []string{
+		"*.py", "*.js", "*.ts", "*.tsx", "*.jsx",
+		"*.go", "*.rs", "*.java", "*.c", "*.cpp", "*.h",
+		"*.md", "*.txt", "*.json", "*.yaml", "*.yml",
+		"*.html", "*.css", "*.scss", "*.sass",
+	}
+	for _, preset := range []string{hash.PresetAll, ""} {
+		patterns, err := hash.ResolvePatterns(preset, nil)
+		require.NoError(t, err)
+		assert.Equal(t, before, patterns)
+	}
+
+	// Extra patterns extend the preset once each
+	patterns, err := hash.ResolvePatterns(hash.PresetGo, []string{"*.proto", "*.go", "*.proto"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"*.go", "go.mod", "*.proto"}, patterns)
+
+	_, err = hash.ResolvePatterns("cobol", nil)
+	assert.ErrorContains(t, err, "unknown file pattern preset")
+	_, err = hash.ResolvePatterns(hash.PresetGo, []string{"[bad"})
+	assert.ErrorContains(t, err, "invalid file pattern")
+}
+
+func TestFileHasherUsesPatterns(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go":   "package main",
+		"go.mod":    "module example",
+		"README.md": "# Example",
+		"style.css": "body {}",
+	})
+
+	patterns, err := hash.ResolvePatterns(hash.PresetGo, nil)
+	require.NoError(t, err)
+
+	hashes, err := hash.NewFileHasher(hash.Options{Patterns: patterns}).ComputeDirectoryHash(dir)
+	require.NoError(t, err)
+	assert.Len(t, hashes, 2)
+	for path := range hashes {
+		assert.Regexp(t, `(main\.go|go\.mod)$`, path)
+	}
+
+	// Without patterns a hasher uses the all preset
+	hashes, err = hash.NewFileHasher(hash.Options{}).ComputeDirectoryHash(dir)
+	require.NoError(t, err)
+	assert.Len(t, hashes, 3)
+}
@@ -0,0 +1,99 @@
+package hash
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// TreeSize summarizes the files a hasher would consider under a path
+type TreeSize struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+
+	// Partial is set when the walk stopped at a limit, so Files and Bytes
+	// are lower bounds
+	Partial bool `json:"partial,omitempty"`
+}
+
+// TreeTooLargeError reports that a path exceeds the configured analysis limits
+type TreeTooLargeError struct {
+	Path     string
+	Size     TreeSize
+	MaxFiles int
+	MaxBytes int64
+}
+
+func (e *TreeTooLargeError) Error() string {
+	contains := "contains"
+	if e.Size.Partial {
+		contains = "contains at least"
+	}
+	return fmt.Sprintf("%s %s %d matching files (%s), exceeding the limit of %d files / %s",
+		e.Path, contains, e.Size.Files, FormatBytes(e.Size.Bytes), e.MaxFiles, FormatBytes(e.MaxBytes))
+}
+
+// MeasureTree counts the files matching the hasher's patterns under dirPath
+// and their total size, using the same traversal as ComputeDirectoryHash
+func (h *FileHasher) MeasureTree(dirPath string) (*TreeSize, error) {
+	return h.measureTree(context.Background(), dirPath, 0, 0)
+}
+
+// measureTree is MeasureTree, stopping early once the tree is known to
+// exceed maxFiles or maxBytes (zero limits are not enforced)
+func (h *FileHasher) measureTree(ctx context.Context, dirPath string, maxFiles int, maxBytes int64) (*TreeSize, error) {
+	size := &TreeSize{}
+
+	err := h.walkMatching(ctx, dirPath, func(path string, info os.FileInfo) error {
+		size.Files++
+		size.Bytes += info.Size()
+		if (maxFiles > 0 && size.Files > maxFiles) || (maxBytes > 0 && size.Bytes > maxBytes) {
+			size.Partial = true
+			return errStopWalk
+		}
+		return nil
+	})
+
+	return size, err
+}
+
+// CheckTreeSize measures dirPath and returns a *TreeTooLargeError when it
+// exceeds maxFiles or maxBytes. A zero limit is not enforced.
+func (h *FileHasher) CheckTreeSize(dirPath string, maxFiles int, maxBytes int64) (*TreeSize, error) {
+	return h.CheckTreeSizeContext(context.Background(), dirPath, maxFiles, maxBytes)
+}
+
+// CheckTreeSizeContext is CheckTreeSize, stopping with ctx's error once
+// ctx is done. The walk ends as soon as a limit is exceeded, so the size
+// of a tree that is too large is Partial.
+func (h *FileHasher) CheckTreeSizeContext(ctx context.Context, dirPath string, maxFiles int, maxBytes int64) (*TreeSize, error) {
+	size, err := h.measureTree(ctx, dirPath, maxFiles, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if size.Partial {
+		return size, &TreeTooLargeError{
+			Path:     dirPath,
+			Size:     *size,
+			MaxFiles: maxFiles,
+			MaxBytes: maxBytes,
+		}
+	}
+
+	return size, nil
+}
+
+// FormatBytes renders a byte count for display
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
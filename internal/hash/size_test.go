@@ -0,0 +1,123 @@
+package hash_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles creates files (relative path -> content) under dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func TestMeasureTree(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go":             "package main",
+		"docs/README.md":      "# readme",
+		"image.png":           "not matched",
+		"node_modules/lib.js": "excluded",
+	})
+
+	size, err := hash.NewFileHasher(hash.Options{}).MeasureTree(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, size.Files)
+	assert.Equal(t, int64(len("package main")+len("# readme")), size.Bytes)
+}
+
+func TestCheckTreeSize(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.go": strings.Repeat("x", 100),
+		"b.go": strings.Repeat("x", 100),
+		"c.go": strings.Repeat("x", 100),
+	})
+	hasher := hash.NewFileHasher(hash.Options{})
+
+	// Within limits
+	_, err := hasher.CheckTreeSize(dir, 3, 300)
+	assert.NoError(t, err)
+
+	// Too many files
+	_, err = hasher.CheckTreeSize(dir, 2, 0)
+	var tooLarge *hash.TreeTooLargeError
+	require.True(t, errors.As(err, &tooLarge))
+	assert.Equal(t, 3, tooLarge.Size.Files)
+
+	// Too many bytes
+	_, err = hasher.CheckTreeSize(dir, 0, 299)
+	assert.True(t, errors.As(err, &tooLarge))
+
+	// Zero limits are not enforced
+	_, err = hasher.CheckTreeSize(dir, 0, 0)
+	assert.NoError(t, err)
+}
+
+func TestCheckTreeSizeStopsEarly(t *testing.T) {
+	dir := t.TempDir()
+	files := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		files[fmt.Sprintf("pkg%d/file%d.go", i%10, i)] = "package pkg"
+	}
+	writeFiles(t, dir, files)
+	hasher := hash.NewFileHasher(hash.Options{})
+	hasher.SetWalkWorkers(4)
+
+	// The walk ends at the first file over the limit
+	_, err := hasher.CheckTreeSizeContext(context.Background(), dir, 5, 0)
+	var tooLarge *hash.TreeTooLargeError
+	require.True(t, errors.As(err, &tooLarge))
+	assert.Equal(t, 6, tooLarge.Size.Files)
+	assert.True(t, tooLarge.Size.Partial)
+	assert.Contains(t, err.Error(), "at least 6")
+
+	// Within limits the whole tree is measured
+	size, err := hasher.CheckTreeSizeContext(context.Background(), dir, 100, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 100, size.Files)
+	assert.False(t, size.Partial)
+
+	// A cancelled walk stops with the context's error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = hasher.CheckTreeSizeContext(ctx, dir, 0, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, _, err = hasher.ComputeDirectoryHashWithStatsContext(ctx, dir)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestComputeDirectoryHashWithStats(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go":             "package main",
+		"pkg/util.go":         "package pkg",
+		"web/app.js":          "app()",
+		"web/lib.js":          "lib()",
+		"README.md":           "# readme",
+		"image.png":           "not matched",
+		"node_modules/lib.js": "excluded",
+	})
+
+	hashes, stats, err := hash.NewFileHasher(hash.Options{}).ComputeDirectoryHashWithStats(dir)
+	require.NoError(t, err)
+	assert.Len(t, hashes, 5)
+	assert.Equal(t, map[string]hash.FileTypeStat{
+		".go": {Files: 2, Bytes: int64(len("package main") + len("package pkg"))},
+		".js": {Files: 2, Bytes: int64(len("app()") + len("lib()"))},
+		".md": {Files: 1, Bytes: int64(len("# readme"))},
+	}, stats)
+}
@@ -17,10 +17,20 @@ type StalenessReport struct {
 	Details        string    `json:"details"`
 }
 
-// CheckStaleness compares stored file hashes with current hashes
-func CheckStaleness(storedHashes map[string]string, currentPath string, lastAnalysisTime time.Time) (*StalenessReport, error) {
+// CheckStaleness compares stored file hashes with current hashes computed
+// with opts. The current files are hashed with algorithm, the one the
+// stored hashes were computed with (empty for SHA256), even when
+// opts.Algorithm has since changed, so a switch of algorithm doesn't make
+// every file look modified.
+func CheckStaleness(opts Options, storedHashes map[string]string, algorithm, currentPath string, lastAnalysisTime time.Time) (*StalenessReport, error) {
+	algorithm, err := ParseAlgorithm(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
 	// Compute current hashes
-	hasher := NewFileHasher()
+	hasher := NewFileHasher(opts)
+	hasher.SetAlgorithm(algorithm)
 	currentHashes, err := hasher.ComputeDirectoryHash(currentPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compute current hashes: %w", err)
@@ -0,0 +1,171 @@
+package hash
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultWalkWorkers is how many directories a FileHasher reads at once
+// unless Options.WalkWorkers says otherwise
+const DefaultWalkWorkers = 4
+
+// errStopWalk ends a walk early without failing it
+var errStopWalk = errors.New("stop walk")
+
+// SetWalkWorkers sets how many directories are read at once; below 1 reads
+// them one at a time
+func (h *FileHasher) SetWalkWorkers(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	h.walkWorkers = workers
+}
+
+// walker reads the directories of a tree concurrently, calling fn for one
+// file at a time so callers need no locking of their own
+type walker struct {
+	h        *FileHasher
+	root     string
+	excluded func(path string) bool
+	fn       func(path string, info os.FileInfo) error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	slots  chan struct{} // Bounds directories being read
+	wg     sync.WaitGroup
+
+	mu  sync.Mutex // Serializes fn and guards err and h.skipped
+	err error
+}
+
+// walkMatching walks dirPath, skipping excluded directories, and calls fn
+// for every regular file that matches the configured patterns and isn't
+// skipped as generated. Directories are read concurrently but fn is never
+// called concurrently; files are visited in no particular order. The walk
+// stops when ctx is done, returning its error, or when fn returns an error,
+// returning it unless it is errStopWalk.
+func (h *FileHasher) walkMatching(ctx context.Context, dirPath string, fn func(path string, info os.FileInfo) error) error {
+	info, err := os.Lstat(dirPath)
+	if err != nil {
+		// Only an unreadable root fails the walk
+		return err
+	}
+	if !info.IsDir() {
+		if !h.matchesPattern(dirPath) || h.isGenerated(dirPath) {
+			return nil
+		}
+		if err := fn(dirPath, info); err != nil && err != errStopWalk {
+			return err
+		}
+		return nil
+	}
+
+	workers := h.walkWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	walkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := &walker{
+		h:        h,
+		root:     dirPath,
+		excluded: h.dirExcluder(dirPath),
+		fn:       fn,
+		ctx:      walkCtx,
+		cancel:   cancel,
+		slots:    make(chan struct{}, workers),
+	}
+
+	w.slots <- struct{}{}
+	w.wg.Add(1)
+	w.dir(dirPath)
+	w.wg.Wait()
+
+	if w.err != nil && w.err != errStopWalk {
+		return w.err
+	}
+	if w.err == nil {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// dir reads the directory at path, holding a slot, and visits its entries.
+// Subdirectories are read by new goroutines once a slot is free.
+func (w *walker) dir(path string) {
+	defer w.wg.Done()
+
+	entries, err := os.ReadDir(path)
+	<-w.slots
+	if err != nil {
+		if path == w.root {
+			w.fail(err)
+		} else {
+			w.skip(path)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if w.ctx.Err() != nil {
+			return
+		}
+		child := filepath.Join(path, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			w.skip(child)
+			continue
+		}
+
+		if info.IsDir() {
+			if w.excluded(child) {
+				continue
+			}
+			select {
+			case w.slots <- struct{}{}:
+			case <-w.ctx.Done():
+				return
+			}
+			w.wg.Add(1)
+			go w.dir(child)
+			continue
+		}
+
+		if !w.h.matchesPattern(child) || w.h.isGenerated(child) {
+			continue
+		}
+		w.visit(child, info)
+	}
+}
+
+// visit calls fn for a matching file unless the walk has ended
+func (w *walker) visit(path string, info os.FileInfo) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil || w.ctx.Err() != nil {
+		return
+	}
+	if err := w.fn(path, info); err != nil {
+		w.err = err
+		w.cancel()
+	}
+}
+
+// fail ends the walk with err
+func (w *walker) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+		w.cancel()
+	}
+}
+
+func (w *walker) skip(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.h.skip(w.root, path)
+}
@@ -0,0 +1,47 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectDefaultsFile holds a project's default rlm_analyze arguments,
+// relative to the project root
+const ProjectDefaultsFile = ".rlm/defaults.yaml"
+
+// projectDefaults are the arguments rlm_analyze falls back to when the
+// caller omits them, so a team's analyses of a repo stay consistent
+type projectDefaults struct {
+	Query string   `yaml:"query"`
+	Focus string   `yaml:"focus"`
+	Tags  []string `yaml:"tags"`
+}
+
+// loadProjectDefaults reads the nearest ProjectDefaultsFile at or above
+// path. Returns nil when there is none.
+func loadProjectDefaults(path string) (*projectDefaults, error) {
+	root, err := hash.FindPackageRoot(path, []string{ProjectDefaultsFile})
+	if err != nil {
+		return nil, nil
+	}
+
+	file := filepath.Join(root, ProjectDefaultsFile)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var defaults projectDefaults
+	if err := yaml.Unmarshal(data, &defaults); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", file, err)
+	}
+	return &defaults, nil
+}
@@ -0,0 +1,53 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FocusVocabulary is the configured set of allowed focus values
+type FocusVocabulary struct {
+	values []string
+	strict bool
+}
+
+// NewFocusVocabulary creates a vocabulary of allowed focus values. In strict
+// mode unknown values are rejected; otherwise they are accepted with a
+// warning. An empty vocabulary allows any focus.
+func NewFocusVocabulary(values []string, strict bool) *FocusVocabulary {
+	v := &FocusVocabulary{strict: strict}
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			v.values = append(v.values, value)
+		}
+	}
+	return v
+}
+
+// Values returns the allowed focus values in configured order
+func (v *FocusVocabulary) Values() []string {
+	if v == nil {
+		return nil
+	}
+	return v.values
+}
+
+// Validate returns the configured spelling of focus, matched
+// case-insensitively. known reports whether focus is in the vocabulary; an
+// unknown focus is an error in strict mode and returned unchanged otherwise.
+func (v *FocusVocabulary) Validate(focus string) (normalized string, known bool, err error) {
+	if v == nil || len(v.values) == 0 || focus == "" {
+		return focus, true, nil
+	}
+
+	for _, value := range v.values {
+		if strings.EqualFold(strings.TrimSpace(focus), value) {
+			return value, true, nil
+		}
+	}
+
+	if v.strict {
+		return "", false, fmt.Errorf("unknown focus %q (allowed: %s)", focus, strings.Join(v.values, ", "))
+	}
+	return focus, false, nil
+}
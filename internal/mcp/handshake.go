@@ -0,0 +1,107 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SupportedProtocolVersions are the MCP protocol versions this server
+// speaks, newest first
+var SupportedProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// DefaultProtocolVersion is used for clients that request no version
+const DefaultProtocolVersion = "2024-11-05"
+
+// negotiateProtocolVersion picks the version to answer initialize with:
+// the client's own when supported, or the newest supported one for a
+// client newer than this server, which the client may then accept or
+// disconnect. Versions older than any supported one, and values that are
+// not protocol versions at all, are incompatible.
+func negotiateProtocolVersion(requested string) (string, error) {
+	if requested == "" {
+		return DefaultProtocolVersion, nil
+	}
+	for _, version := range SupportedProtocolVersions {
+		if requested == version {
+			return version, nil
+		}
+	}
+
+	// Versions are dates, so they order as strings
+	if _, err := time.Parse("2006-01-02", requested); err == nil && requested > SupportedProtocolVersions[0] {
+		return SupportedProtocolVersions[0], nil
+	}
+	return "", fmt.Errorf("unsupported protocol version %q (supported: %s)",
+		requested, strings.Join(SupportedProtocolVersions, ", "))
+}
+
+// supportsProgress reports whether the client advertised that it accepts
+// progress notifications. MCP has no standard capability for this, so
+// clients opt in with capabilities.experimental.progress.
+func (s *Server) supportsProgress() bool {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	_, ok := s.capabilities.Experimental["progress"]
+	return ok
+}
+
+// notifierKey carries the function sending notifications to the client
+// of the request being handled
+type notifierKey struct{}
+
+// notifier sends a notification to the client
+type notifier func(method string, params interface{})
+
+func withNotifier(ctx context.Context, notify notifier) context.Context {
+	return context.WithValue(ctx, notifierKey{}, notify)
+}
+
+func notifierFrom(ctx context.Context) notifier {
+	notify, _ := ctx.Value(notifierKey{}).(notifier)
+	return notify
+}
+
+// reportProgress forwards orchestrator events to the client as progress
+// notifications while an analysis runs, if the call asked for progress and
+// the client supports it. The returned function stops forwarding once
+// every event received so far was sent; it must be called before the
+// analysis slot is released.
+func (s *Server) reportProgress(ctx context.Context, meta *RequestMeta) func() {
+	if meta == nil || meta.ProgressToken == nil {
+		return func() {}
+	}
+	notify := notifierFrom(ctx)
+	if notify == nil {
+		return func() {}
+	}
+	if !s.supportsProgress() {
+		s.logger.Debug().Msg("Client did not advertise progress support, not sending progress")
+		return func() {}
+	}
+
+	events, unsubscribe := s.orchestrator.Subscribe(64)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		progress := 0
+		for event := range events {
+			progress++
+			message := string(event.Type)
+			if event.AgentType != "" {
+				message = fmt.Sprintf("%s: %s at depth %d", event.Type, event.AgentType, event.Depth)
+			}
+			notify("notifications/progress", ProgressParams{
+				ProgressToken: meta.ProgressToken,
+				Progress:      float64(progress),
+				Message:       message,
+			})
+		}
+	}()
+
+	return func() {
+		unsubscribe()
+		<-done
+	}
+}
@@ -0,0 +1,130 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// IdempotencyFileName is the file completed idempotency keys are persisted to
+const IdempotencyFileName = "idempotency.json"
+
+// IdempotencyStore remembers rlm_analyze calls by client-supplied key so a
+// retried call returns the original outcome instead of running again.
+// In-flight keys are tracked in memory; completed results are persisted so
+// retries across server restarts are also safe. Keys expire after window.
+type IdempotencyStore struct {
+	mu        sync.Mutex
+	file      string // Empty keeps completed keys in memory only
+	window    time.Duration
+	now       func() time.Time
+	running   map[string]bool
+	completed map[string]idempotencyEntry
+}
+
+type idempotencyEntry struct {
+	Result      *ToolResult `json:"result"`
+	CompletedAt time.Time   `json:"completed_at"`
+}
+
+// NewIdempotencyStore creates a store persisting to dir (empty for memory
+// only) whose keys expire after window. A nil clock uses time.Now.
+func NewIdempotencyStore(dir string, window time.Duration, now func() time.Time) (*IdempotencyStore, error) {
+	if now == nil {
+		now = time.Now
+	}
+
+	s := &IdempotencyStore{
+		window:    window,
+		now:       now,
+		running:   make(map[string]bool),
+		completed: make(map[string]idempotencyEntry),
+	}
+
+	if dir == "" {
+		return s, nil
+	}
+	s.file = filepath.Join(dir, IdempotencyFileName)
+
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read idempotency keys: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.completed); err != nil {
+		return nil, fmt.Errorf("failed to parse idempotency keys: %w", err)
+	}
+	s.pruneExpired()
+
+	return s, nil
+}
+
+// Begin claims key for a new call. If the key is already running it returns
+// inFlight; if it completed within the window it returns the prior result.
+// Otherwise the key is marked running and the caller must call Complete or
+// Abort.
+func (s *IdempotencyStore) Begin(key string) (prior *ToolResult, inFlight bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running[key] {
+		return nil, true
+	}
+	if entry, ok := s.completed[key]; ok && !s.expired(entry) {
+		return entry.Result, false
+	}
+
+	s.running[key] = true
+	return nil, false
+}
+
+// Complete records the result for a running key
+func (s *IdempotencyStore) Complete(key string, result *ToolResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.running, key)
+	s.completed[key] = idempotencyEntry{Result: result, CompletedAt: s.now()}
+	s.pruneExpired()
+
+	return s.save()
+}
+
+// Abort releases a running key without recording a result, so a retry runs again
+func (s *IdempotencyStore) Abort(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.running, key)
+}
+
+func (s *IdempotencyStore) expired(entry idempotencyEntry) bool {
+	return s.now().Sub(entry.CompletedAt) > s.window
+}
+
+// pruneExpired drops completed keys outside the window. Callers must hold mu.
+func (s *IdempotencyStore) pruneExpired() {
+	for key, entry := range s.completed {
+		if s.expired(entry) {
+			delete(s.completed, key)
+		}
+	}
+}
+
+// save persists completed keys. Callers must hold mu.
+func (s *IdempotencyStore) save() error {
+	if s.file == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.completed, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.file, data, 0644)
+}
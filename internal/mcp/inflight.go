@@ -0,0 +1,65 @@
+package mcp
+
+import (
+	"context"
+	"sync"
+)
+
+// inFlightAnalyses tracks rlm_analyze calls by a key normalizing their
+// arguments so a second identical call joins the first instead of paying
+// for the same work again and racing it on storage
+type inFlightAnalyses struct {
+	mu    sync.Mutex
+	calls map[string]*inFlightAnalysis
+}
+
+type inFlightAnalysis struct {
+	done   chan struct{}
+	result *ToolResult
+	err    error
+}
+
+func newInFlightAnalyses() *inFlightAnalyses {
+	return &inFlightAnalyses{calls: make(map[string]*inFlightAnalysis)}
+}
+
+// Do runs fn unless an analysis with key is already in progress, in which
+// case it waits for that analysis and returns its outcome. joined reports
+// whether the outcome came from another call. A waiting caller gives up
+// when ctx is done; the running analysis is unaffected.
+func (f *inFlightAnalyses) Do(ctx context.Context, key string, fn func() (*ToolResult, error)) (result *ToolResult, joined bool, err error) {
+	f.mu.Lock()
+	if call, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.result, true, call.err
+		case <-ctx.Done():
+			return nil, true, ctx.Err()
+		}
+	}
+
+	call := &inFlightAnalysis{done: make(chan struct{})}
+	f.calls[key] = call
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.result, call.err = fn()
+	return call.result, false, call.err
+}
+
+// notStartedError marks an rlm_analyze call refused before it ran, e.g.
+// because no analysis slot was free
+type notStartedError struct {
+	err error
+}
+
+func (e *notStartedError) Error() string { return e.err.Error() }
+
+func (e *notStartedError) Unwrap() error { return e.err }
@@ -0,0 +1,172 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kukks/claude-rlm/internal/fsutil"
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+)
+
+// MetricsFileName is the Prometheus textfile metrics are exported to,
+// relative to the RAG directory
+const MetricsFileName = "metrics.prom"
+
+// Metrics accumulates orchestrator stats across analyses so they can be
+// exported as Prometheus counters, e.g. for node_exporter's textfile
+// collector on hosts without a scrape endpoint
+type Metrics struct {
+	mu        sync.Mutex
+	lastStart time.Time // StartTime of the last recorded run
+	analyses  int
+	total     orchestrator.Stats
+}
+
+// NewMetrics creates an empty metrics accumulator
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// Record adds one analysis run's stats. A snapshot of a run already
+// recorded (an analysis answered without running the orchestrator) is
+// ignored.
+func (m *Metrics) Record(stats orchestrator.Stats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stats.StartTime.IsZero() || stats.StartTime.Equal(m.lastStart) {
+		return
+	}
+	m.lastStart = stats.StartTime
+
+	m.analyses++
+	m.total.TotalSubagentCalls += stats.TotalSubagentCalls
+	m.total.TotalTokens += stats.TotalTokens
+	m.total.TotalCostUSD += stats.TotalCostUSD
+	m.total.CacheHits += stats.CacheHits
+	m.total.CacheSavingsUSD += stats.CacheSavingsUSD
+	for agentType, agent := range stats.ByAgent {
+		if m.total.ByAgent == nil {
+			m.total.ByAgent = make(map[string]orchestrator.AgentStats)
+		}
+		sum := m.total.ByAgent[agentType]
+		sum.Calls += agent.Calls
+		sum.Tokens += agent.Tokens
+		sum.CostUSD += agent.CostUSD
+		m.total.ByAgent[agentType] = sum
+	}
+}
+
+// WritePrometheus writes the accumulated counters in the Prometheus text
+// exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	analyses := m.analyses
+	total := m.total
+	agents := make([]string, 0, len(total.ByAgent))
+	for agentType := range total.ByAgent {
+		agents = append(agents, agentType)
+	}
+	byAgent := make(map[string]orchestrator.AgentStats, len(agents))
+	for _, agentType := range agents {
+		byAgent[agentType] = total.ByAgent[agentType]
+	}
+	m.mu.Unlock()
+	sort.Strings(agents)
+
+	var buf bytes.Buffer
+	counter := func(name, help string) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	}
+	sample := func(name, labels string, value float64) {
+		fmt.Fprintf(&buf, "%s%s %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+	}
+
+	counter("rlm_analyses_total", "Analyses run by the orchestrator.")
+	sample("rlm_analyses_total", "", float64(analyses))
+	counter("rlm_subagent_calls_total", "Subagent calls dispatched.")
+	sample("rlm_subagent_calls_total", "", float64(total.TotalSubagentCalls))
+	counter("rlm_tokens_total", "Tokens used by subagents.")
+	sample("rlm_tokens_total", "", float64(total.TotalTokens))
+	counter("rlm_cost_usd_total", "Subagent cost in US dollars.")
+	sample("rlm_cost_usd_total", "", total.TotalCostUSD)
+	counter("rlm_cache_hits_total", "Subagent results reused from the cache.")
+	sample("rlm_cache_hits_total", "", float64(total.CacheHits))
+	counter("rlm_cache_savings_usd_total", "Original cost of cached results reused, in US dollars.")
+	sample("rlm_cache_savings_usd_total", "", total.CacheSavingsUSD)
+
+	if len(agents) > 0 {
+		counter("rlm_agent_calls_total", "Subagent calls by agent type.")
+		for _, agentType := range agents {
+			sample("rlm_agent_calls_total", agentLabel(agentType), float64(byAgent[agentType].Calls))
+		}
+		counter("rlm_agent_tokens_total", "Tokens used by agent type.")
+		for _, agentType := range agents {
+			sample("rlm_agent_tokens_total", agentLabel(agentType), float64(byAgent[agentType].Tokens))
+		}
+		counter("rlm_agent_cost_usd_total", "Subagent cost by agent type, in US dollars.")
+		for _, agentType := range agents {
+			sample("rlm_agent_cost_usd_total", agentLabel(agentType), byAgent[agentType].CostUSD)
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// agentLabel renders the agent label set, escaped per the exposition format
+func agentLabel(agentType string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(agentType)
+	return `{agent="` + escaped + `"}`
+}
+
+// WriteFile atomically replaces path with the current counters, so a
+// collector never reads a partially written file
+func (m *Metrics) WriteFile(path string) error {
+	var buf bytes.Buffer
+	if err := m.WritePrometheus(&buf); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+	if err := fsutil.WriteFile(path, buf.Bytes(), 0644, true); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
+
+// ExportMetrics writes the server's metrics to path every interval until
+// ctx is done, then once more so the file holds the final counts
+func (s *Server) ExportMetrics(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.metrics.WriteFile(path); err != nil {
+				s.logger.Warn().Err(err).Msg("Failed to export metrics")
+			}
+			return
+		case <-ticker.C:
+			if err := s.metrics.WriteFile(path); err != nil {
+				s.logger.Warn().Err(err).Msg("Failed to export metrics")
+			}
+		}
+	}
+}
+
+// Metrics returns the server's accumulated analysis metrics
+func (s *Server) Metrics() *Metrics {
+	return s.metrics
+}
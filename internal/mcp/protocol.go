@@ -14,6 +14,12 @@ type Request struct {
 	Params  json.RawMessage `json:"params,omitempty"`
 }
 
+// IsNotification reports whether the request is a notification, which has
+// no id and must not be answered
+func (r *Request) IsNotification() bool {
+	return r.ID == nil
+}
+
 // Response represents a JSON-RPC response
 type Response struct {
 	JSONRPC string      `json:"jsonrpc"`
@@ -36,10 +42,35 @@ const (
 	MethodNotFound = -32601
 	InvalidParams  = -32602
 	InternalError  = -32603
+
+	// ServerBusy is returned when the server declines work it has no capacity for
+	ServerBusy = -32000
+	// RateLimited is returned when a tool's rate limit is exceeded
+	RateLimited = -32001
 )
 
 // MCP-specific message types
 
+// InitializeParams are the parameters of the initialize request
+type InitializeParams struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ClientCapabilities `json:"capabilities"`
+	ClientInfo      ClientInfo         `json:"clientInfo"`
+}
+
+// ClientCapabilities are the optional features a client advertised
+type ClientCapabilities struct {
+	Roots        map[string]interface{} `json:"roots,omitempty"`
+	Sampling     map[string]interface{} `json:"sampling,omitempty"`
+	Experimental map[string]interface{} `json:"experimental,omitempty"`
+}
+
+// ClientInfo identifies the client that initialized the session
+type ClientInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
 // InitializeResult is the response to the initialize request
 type InitializeResult struct {
 	ProtocolVersion string               `json:"protocolVersion"`
@@ -74,6 +105,28 @@ type Tool struct {
 type ToolCallParams struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments"`
+	Meta      *RequestMeta           `json:"_meta,omitempty"`
+}
+
+// RequestMeta is the metadata a client may attach to a request
+type RequestMeta struct {
+	// ProgressToken asks for progress notifications referencing it
+	ProgressToken interface{} `json:"progressToken,omitempty"`
+}
+
+// Notification represents a JSON-RPC notification sent to the client
+type Notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// ProgressParams are the parameters of a notifications/progress message
+type ProgressParams struct {
+	ProgressToken interface{} `json:"progressToken"`
+	Progress      float64     `json:"progress"`
+	Total         float64     `json:"total,omitempty"`
+	Message       string      `json:"message,omitempty"`
 }
 
 // ToolResult is the response from calling a tool
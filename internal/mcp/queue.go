@@ -0,0 +1,538 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kukks/claude-rlm/internal/fsutil"
+)
+
+// QueueFileName is the file queued analysis jobs are persisted to
+const QueueFileName = "queue.jsonl"
+
+// JobState is the lifecycle state of a queued analysis
+type JobState string
+
+// Job states, in the order a job passes through them
+const (
+	JobQueued  JobState = "queued"
+	JobRunning JobState = "running"
+	JobDone    JobState = "done"
+	JobFailed  JobState = "failed"
+)
+
+// Job is an rlm_analyze call queued for a worker to run
+type Job struct {
+	ID         string                 `json:"id"`
+	State      JobState               `json:"state"`
+	Arguments  map[string]interface{} `json:"arguments"`
+	Result     *ToolResult            `json:"result,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	EnqueuedAt time.Time              `json:"enqueued_at"`
+	StartedAt  *time.Time             `json:"started_at,omitempty"`
+	FinishedAt *time.Time             `json:"finished_at,omitempty"`
+}
+
+// JobRetention bounds the finished jobs a JobQueue keeps, with their
+// results, for rlm_job_status. Zero fields are unlimited.
+type JobRetention struct {
+	MaxAge      time.Duration // Finished jobs older than this are dropped
+	MaxFinished int           // Only the most recently enqueued finished jobs are kept
+}
+
+// compactMinLines is how many lines the queue file may hold before it is
+// compacted, however few jobs there are
+const compactMinLines = 64
+
+// JobQueue holds rlm_analyze calls to run in the background. Every state
+// change is appended to a JSONL file as a snapshot of the job, the last
+// line per job winning, so queued jobs survive a restart. Jobs that were
+// running when the process stopped are queued again. Finished jobs are
+// dropped as retention allows, and the file is rewritten with one line per
+// job once superseded lines outnumber current ones.
+type JobQueue struct {
+	mu        sync.Mutex
+	file      string // Empty keeps jobs in memory only
+	retention JobRetention
+	jobs      map[string]*Job
+	order     []string      // Job IDs in enqueue order
+	lines     int           // Lines in the queue file
+	wake      chan struct{} // Signalled when a job is queued
+}
+
+// NewJobQueue creates a queue persisting to dir (empty for memory only),
+// restoring the jobs saved there and keeping finished jobs as retention
+// allows
+func NewJobQueue(dir string, retention JobRetention) (*JobQueue, error) {
+	q := &JobQueue{
+		retention: retention,
+		jobs:      make(map[string]*Job),
+		wake:      make(chan struct{}, 1),
+	}
+	if dir == "" {
+		return q, nil
+	}
+	q.file = filepath.Join(dir, QueueFileName)
+
+	data, err := os.ReadFile(q.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return q, nil
+		}
+		return nil, fmt.Errorf("failed to read job queue: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var job Job
+		if err := json.Unmarshal(line, &job); err != nil {
+			return nil, fmt.Errorf("failed to parse job queue: %w", err)
+		}
+		if _, ok := q.jobs[job.ID]; !ok {
+			q.order = append(q.order, job.ID)
+		}
+		q.jobs[job.ID] = &job
+	}
+
+	// Resume jobs interrupted by the restart
+	for _, job := range q.jobs {
+		if job.State == JobRunning {
+			job.State = JobQueued
+			job.StartedAt = nil
+		}
+	}
+	q.prune(time.Now())
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	if q.count(JobQueued) > 0 {
+		q.signal()
+	}
+	return q, nil
+}
+
+// Enqueue adds an rlm_analyze call to the queue
+func (q *JobQueue) Enqueue(args map[string]interface{}) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job := &Job{
+		ID:         uuid.New().String(),
+		State:      JobQueued,
+		Arguments:  args,
+		EnqueuedAt: time.Now(),
+	}
+	if err := q.append(job); err != nil {
+		return nil, err
+	}
+	q.jobs[job.ID] = job
+	q.order = append(q.order, job.ID)
+	q.maybeCompact()
+	q.signal()
+
+	copied := *job
+	return &copied, nil
+}
+
+// Get returns a snapshot of the job with id, or nil if there is none
+func (q *JobQueue) Get(id string) *Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil
+	}
+	copied := *job
+	return &copied
+}
+
+// List returns snapshots of every job in enqueue order
+func (q *JobQueue) List() []*Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]*Job, 0, len(q.order))
+	for _, id := range q.order {
+		copied := *q.jobs[id]
+		jobs = append(jobs, &copied)
+	}
+	return jobs
+}
+
+// Counts returns how many jobs are in each state
+func (q *JobQueue) Counts() map[JobState]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	counts := make(map[JobState]int)
+	for _, job := range q.jobs {
+		counts[job.State]++
+	}
+	return counts
+}
+
+// claim marks the oldest queued job running and returns it, waiting for
+// one to be queued. It returns nil once ctx is done.
+func (q *JobQueue) claim(ctx context.Context) (*Job, error) {
+	for {
+		q.mu.Lock()
+		for _, id := range q.order {
+			job := q.jobs[id]
+			if job.State != JobQueued {
+				continue
+			}
+			started := time.Now()
+			claimed := *job
+			claimed.State = JobRunning
+			claimed.StartedAt = &started
+			if err := q.append(&claimed); err != nil {
+				q.mu.Unlock()
+				return nil, err
+			}
+			*job = claimed
+			q.maybeCompact()
+
+			// Let another worker pick up the rest
+			if q.count(JobQueued) > 0 {
+				q.signal()
+			}
+			q.mu.Unlock()
+			return &claimed, nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.wake:
+		case <-ctx.Done():
+			return nil, nil
+		}
+	}
+}
+
+// finish records the outcome of a running job
+func (q *JobQueue) finish(id string, result *ToolResult, runErr error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return fmt.Errorf("unknown job %s", id)
+	}
+
+	finished := time.Now()
+	done := *job
+	done.State = JobDone
+	done.Result = result
+	done.FinishedAt = &finished
+	if runErr != nil {
+		done.State = JobFailed
+		done.Error = runErr.Error()
+	} else if result != nil && result.IsError {
+		done.State = JobFailed
+	}
+	if err := q.append(&done); err != nil {
+		return err
+	}
+	*job = done
+
+	q.prune(finished)
+	q.maybeCompact()
+	return nil
+}
+
+// prune drops finished jobs beyond the retention limits. Their snapshots
+// stay in the file until the next compaction. Callers must hold mu.
+func (q *JobQueue) prune(now time.Time) {
+	keep := make(map[string]bool, len(q.order))
+	finished := 0
+	// Newest first, so MaxFinished keeps the most recent jobs
+	for i := len(q.order) - 1; i >= 0; i-- {
+		job := q.jobs[q.order[i]]
+		if job.State != JobDone && job.State != JobFailed {
+			keep[job.ID] = true
+			continue
+		}
+		if q.retention.MaxAge > 0 && job.FinishedAt != nil && now.Sub(*job.FinishedAt) > q.retention.MaxAge {
+			continue
+		}
+		if q.retention.MaxFinished > 0 && finished >= q.retention.MaxFinished {
+			continue
+		}
+		finished++
+		keep[job.ID] = true
+	}
+	if len(keep) == len(q.order) {
+		return
+	}
+
+	order := make([]string, 0, len(keep))
+	for _, id := range q.order {
+		if keep[id] {
+			order = append(order, id)
+		} else {
+			delete(q.jobs, id)
+		}
+	}
+	q.order = order
+}
+
+// requeue returns a claimed job to the queue without running it
+func (q *JobQueue) requeue(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return fmt.Errorf("unknown job %s", id)
+	}
+	queued := *job
+	queued.State = JobQueued
+	queued.StartedAt = nil
+	if err := q.append(&queued); err != nil {
+		return err
+	}
+	*job = queued
+	q.maybeCompact()
+	q.signal()
+	return nil
+}
+
+// signal wakes a waiting worker without blocking
+func (q *JobQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// count returns how many jobs are in state. Callers must hold mu.
+func (q *JobQueue) count(state JobState) int {
+	n := 0
+	for _, job := range q.jobs {
+		if job.State == state {
+			n++
+		}
+	}
+	return n
+}
+
+// append persists a job snapshot. Callers must hold mu.
+func (q *JobQueue) append(job *Job) error {
+	if q.file == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(q.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write job queue: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write job queue: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write job queue: %w", err)
+	}
+	q.lines++
+	return nil
+}
+
+// maybeCompact compacts the queue file once superseded snapshots outnumber
+// current ones. A failed compaction leaves the file valid, only longer, so
+// it is retried after the next write. Callers must hold mu and have applied
+// every appended snapshot to jobs.
+func (q *JobQueue) maybeCompact() {
+	if q.lines > compactMinLines && q.lines > 2*len(q.jobs) {
+		_ = q.compact()
+	}
+}
+
+// compact rewrites the queue file with one line per job. Callers must hold
+// mu, except while the queue is being created.
+func (q *JobQueue) compact() error {
+	if q.file == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, id := range q.order {
+		line, err := json.Marshal(q.jobs[id])
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	// Atomic, so an interrupted compaction never loses queued jobs
+	if err := fsutil.WriteFile(q.file, buf.Bytes(), 0644, true); err != nil {
+		return fmt.Errorf("failed to write job queue: %w", err)
+	}
+	q.lines = len(q.order)
+	return nil
+}
+
+// enqueueAnalysis queues an rlm_analyze call instead of running it,
+// validating its path first so a bad call fails now rather than later
+func (s *Server) enqueueAnalysis(args map[string]interface{}) (*ToolResult, error) {
+	if s.queue == nil {
+		return nil, fmt.Errorf("enqueue is not available: the job queue is disabled")
+	}
+	if _, err := s.analyzePath(args); err != nil {
+		return nil, err
+	}
+
+	queued := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if k != "enqueue" {
+			queued[k] = v
+		}
+	}
+	job, err := s.queue.Enqueue(queued)
+	if err != nil {
+		return nil, err
+	}
+
+	response := map[string]interface{}{
+		"job_id": job.ID,
+		"state":  job.State,
+	}
+	responseJSON, _ := json.MarshalIndent(response, "", "  ")
+	return NewToolResult(string(responseJSON)), nil
+}
+
+// RunJobs drains the job queue with the given number of workers until ctx
+// is done. Jobs go through the same idempotency keys and in-flight dedup as
+// interactive calls and share their analysis slots, waiting for one rather
+// than being rejected. A job whose idempotency key another call holds is
+// queued again until that call's result can be returned.
+func (s *Server) RunJobs(ctx context.Context, workers int) {
+	if s.queue == nil {
+		return
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runJobs(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// Delays before a worker retries after failing to claim a job, doubling
+// up to the maximum while claims keep failing
+const (
+	claimRetryMin = 100 * time.Millisecond
+	claimRetryMax = 30 * time.Second
+)
+
+// keyRetryDelay is how long a job whose idempotency key another call holds
+// waits before it is queued again
+const keyRetryDelay = 500 * time.Millisecond
+
+func (s *Server) runJobs(ctx context.Context) {
+	retry := claimRetryMin
+	for {
+		job, err := s.queue.claim(ctx)
+		if err != nil {
+			// E.g. the queue file is briefly unwritable; the job stays queued
+			s.logger.Warn().Err(err).Dur("retry_in", retry).Msg("Failed to claim queued job")
+			select {
+			case <-time.After(retry):
+			case <-ctx.Done():
+				return
+			}
+			retry = min(2*retry, claimRetryMax)
+			continue
+		}
+		if job == nil {
+			return
+		}
+		retry = claimRetryMin
+
+		// Queued jobs dedup against interactive calls and each other the
+		// same way interactive calls do. A joined analysis had identical
+		// arguments, so its result is the job's own.
+		s.logger.Info().Str("job", job.ID).Msg("Running queued analysis")
+		result, outcome, runErr := s.analyze(ctx, job.Arguments, true, nil)
+
+		// The job's idempotency key is held by a call still running, so
+		// there is no result yet. Retrying later returns that call's result.
+		if outcome == analyzeInProgress && ctx.Err() == nil {
+			s.logger.Info().Str("job", job.ID).Dur("retry_in", keyRetryDelay).Msg("Idempotency key in use, requeueing job")
+			select {
+			case <-time.After(keyRetryDelay):
+			case <-ctx.Done():
+			}
+		}
+
+		// An analysis cut short by shutdown, or not started because of it,
+		// runs again after the restart
+		if outcome == analyzeInProgress || ctx.Err() != nil {
+			if err := s.queue.requeue(job.ID); err != nil {
+				s.logger.Warn().Err(err).Str("job", job.ID).Msg("Failed to requeue job")
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		if err := s.queue.finish(job.ID, result, runErr); err != nil {
+			s.logger.Warn().Err(err).Str("job", job.ID).Msg("Failed to record job outcome")
+		}
+	}
+}
+
+// handleJobStatus implements the rlm_job_status tool
+func (s *Server) handleJobStatus(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	if s.queue == nil {
+		return nil, fmt.Errorf("the job queue is disabled")
+	}
+
+	var response interface{}
+	if id, _ := args["job_id"].(string); id != "" {
+		job := s.queue.Get(id)
+		if job == nil {
+			return nil, fmt.Errorf("job %s not found", id)
+		}
+		response = job
+	} else {
+		// Summaries only; results can be large
+		jobs := s.queue.List()
+		summaries := make([]map[string]interface{}, 0, len(jobs))
+		for _, job := range jobs {
+			summaries = append(summaries, map[string]interface{}{
+				"id":          job.ID,
+				"state":       job.State,
+				"enqueued_at": job.EnqueuedAt,
+			})
+		}
+		response = map[string]interface{}{"jobs": summaries}
+	}
+
+	responseJSON, _ := json.MarshalIndent(response, "", "  ")
+	return NewToolResult(string(responseJSON)), nil
+}
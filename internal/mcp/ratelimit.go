@@ -0,0 +1,78 @@
+package mcp
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit configures a token bucket: PerMinute tokens are refilled each
+// minute up to Burst. A Burst of zero defaults to one call.
+type RateLimit struct {
+	PerMinute float64
+	Burst     int
+}
+
+// RateLimiter enforces per-tool token-bucket rate limits. Tools without a
+// configured limit are never limited.
+type RateLimiter struct {
+	mu      sync.Mutex
+	limits  map[string]RateLimit
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a rate limiter for the given per-tool limits.
+// A nil clock uses time.Now.
+func NewRateLimiter(limits map[string]RateLimit, now func() time.Time) *RateLimiter {
+	if now == nil {
+		now = time.Now
+	}
+	return &RateLimiter{
+		limits:  limits,
+		buckets: make(map[string]*bucket),
+		now:     now,
+	}
+}
+
+// Allow takes a token for tool. When none is available it returns false and
+// how long until the next token is refilled.
+func (l *RateLimiter) Allow(tool string) (bool, time.Duration) {
+	limit, ok := l.limits[tool]
+	if !ok || limit.PerMinute <= 0 {
+		return true, 0
+	}
+
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[tool]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[tool] = b
+	}
+
+	// Refill for the time elapsed since the last call
+	perSecond := limit.PerMinute / 60
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	return false, wait.Round(time.Millisecond)
+}
@@ -0,0 +1,77 @@
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// Redactor scrubs MCP traffic before it is written to logs. Long strings are
+// truncated and tagged with a short hash so identical values can still be
+// correlated, and configured fields are omitted entirely.
+type Redactor struct {
+	maxLength int
+	fields    map[string]bool
+}
+
+// NewRedactor creates a redactor truncating strings longer than maxLength
+// and masking the given field names
+func NewRedactor(maxLength int, fields []string) *Redactor {
+	r := &Redactor{
+		maxLength: maxLength,
+		fields:    make(map[string]bool, len(fields)),
+	}
+	for _, f := range fields {
+		r.fields[f] = true
+	}
+	return r
+}
+
+// RedactJSON decodes raw JSON and returns a redacted copy suitable for logging
+func (r *Redactor) RedactJSON(raw []byte) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return r.redactString(string(raw))
+	}
+	return r.Redact(v)
+}
+
+// Redact returns a redacted copy of a decoded JSON value
+func (r *Redactor) Redact(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			if r.fields[k] {
+				out[k] = "[redacted]"
+				continue
+			}
+			out[k] = r.Redact(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = r.Redact(item)
+		}
+		return out
+	case string:
+		return r.redactString(val)
+	default:
+		return v
+	}
+}
+
+// redactString truncates a string that exceeds the configured length
+func (r *Redactor) redactString(s string) string {
+	if r.maxLength <= 0 || len(s) <= r.maxLength {
+		return s
+	}
+
+	sum := sha256.Sum256([]byte(s))
+	return fmt.Sprintf("%s...[truncated %d bytes, sha256:%x]", s[:r.maxLength], len(s)-r.maxLength, sum[:6])
+}
@@ -4,8 +4,13 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/kukks/claude-rlm/internal/hash"
@@ -21,8 +26,47 @@ type Server struct {
 	logger       zerolog.Logger
 	tools        []Tool
 	version      string
+	redactor     *Redactor
+	readOnly     bool
+	maxFiles     int
+	maxBytes     int64
+
+	// analysisSlots bounds in-flight rlm_analyze calls; nil means unlimited
+	analysisSlots chan struct{}
+	queueAnalyses bool
+	rateLimiter   *RateLimiter      // nil disables rate limiting
+	idempotency   *IdempotencyStore // nil ignores idempotency keys
+	queue         *JobQueue         // nil disables enqueueing analyses
+	focuses       *FocusVocabulary  // nil allows any focus
+	rootMarkers   []string          // package root markers for scope=package; nil uses defaults
+	allowedRoots  []string          // canonical roots paths must lie under; nil allows any path
+	quickFiles    int               // files hashed by quick=true analyses
+	quickDepth    int               // recursion depth of quick=true analyses
+	inFlight      *inFlightAnalyses // rlm_analyze calls in progress, keyed by path
+	// runMu serializes orchestrator runs, which share trampoline state
+	runMu sync.Mutex
+	// lastStats snapshots orchestrator stats after each run so status
+	// requests never read them while an analysis is mutating them
+	statsMu   sync.Mutex
+	lastStats orchestrator.Stats
+	metrics   *Metrics // stats accumulated across runs for export
+	// client is the identity sent with initialize and capabilities what
+	// it advertised; empty until received
+	clientMu     sync.Mutex
+	client       ClientInfo
+	capabilities ClientCapabilities
 }
 
+// Defaults for rlm_analyze quick=true
+const (
+	DefaultQuickMaxFiles = 200
+	DefaultQuickMaxDepth = 1
+)
+
+// ErrServerBusy is returned when rlm_analyze is rejected because the
+// concurrent analysis limit is reached and queueing is disabled
+var ErrServerBusy = errors.New("server busy")
+
 // NewServer creates a new MCP server
 func NewServer(orch *orchestrator.Orchestrator, store storage.Backend, logger zerolog.Logger, version string) *Server {
 	s := &Server{
@@ -30,17 +74,267 @@ func NewServer(orch *orchestrator.Orchestrator, store storage.Backend, logger ze
 		storage:      store,
 		logger:       logger,
 		version:      version,
+		lastStats:    orch.GetStats(),
+		metrics:      NewMetrics(),
+		inFlight:     newInFlightAnalyses(),
+		quickFiles:   DefaultQuickMaxFiles,
+		quickDepth:   DefaultQuickMaxDepth,
 	}
 	s.tools = s.defineTools()
 	return s
 }
 
+// SetRedactor sets the redactor applied to requests and responses in debug logs.
+// A nil redactor logs traffic verbatim.
+func (s *Server) SetRedactor(redactor *Redactor) {
+	s.redactor = redactor
+}
+
+// SetReadOnly enables or disables read-only mode. In read-only mode tools
+// that cost money or mutate the store are hidden and rejected.
+func (s *Server) SetReadOnly(readOnly bool) {
+	s.readOnly = readOnly
+	s.tools = s.defineTools()
+}
+
+// SetSizeLimits sets the maximum number of matching files and total bytes
+// rlm_analyze accepts before refusing a path. Zero disables a limit.
+func (s *Server) SetSizeLimits(maxFiles int, maxBytes int64) {
+	s.maxFiles = maxFiles
+	s.maxBytes = maxBytes
+}
+
+// SetMaxConcurrentAnalyses limits how many rlm_analyze calls may be in
+// flight at once. Excess calls wait for a slot when queue is true and are
+// rejected with ErrServerBusy otherwise. Zero removes the limit. Other tools
+// are never limited. Must be called before serving requests.
+func (s *Server) SetMaxConcurrentAnalyses(limit int, queue bool) {
+	s.analysisSlots = nil
+	if limit > 0 {
+		s.analysisSlots = make(chan struct{}, limit)
+	}
+	s.queueAnalyses = queue
+}
+
+// SetRateLimiter sets the per-tool rate limiter. A nil limiter disables
+// rate limiting.
+func (s *Server) SetRateLimiter(limiter *RateLimiter) {
+	s.rateLimiter = limiter
+}
+
+// SetIdempotencyStore sets the store used to deduplicate rlm_analyze calls
+// carrying an idempotency_key. A nil store ignores the key.
+func (s *Server) SetIdempotencyStore(store *IdempotencyStore) {
+	s.idempotency = store
+}
+
+// SetJobQueue sets the queue rlm_analyze enqueue=true adds jobs to; see
+// RunJobs. A nil queue disables enqueueing.
+func (s *Server) SetJobQueue(queue *JobQueue) {
+	s.queue = queue
+}
+
+// SetFocusVocabulary sets the allowed focus values, which rlm_analyze and
+// rlm_search_rag validate and advertise in their schemas. A nil vocabulary
+// allows any focus.
+func (s *Server) SetFocusVocabulary(focuses *FocusVocabulary) {
+	s.focuses = focuses
+	s.tools = s.defineTools()
+}
+
+// SetPackageRootMarkers sets the files marking a package root when
+// rlm_analyze is called with scope=package. Nil uses hash.DefaultRootMarkers.
+func (s *Server) SetPackageRootMarkers(markers []string) {
+	s.rootMarkers = markers
+}
+
+// SetAllowedRoots restricts the paths rlm_analyze and rlm_check_freshness
+// accept, and where rlm_analyze may write output, to the given roots and
+// their descendants. Nil allows any path.
+func (s *Server) SetAllowedRoots(roots []string) {
+	s.allowedRoots = nil
+	for _, root := range roots {
+		s.allowedRoots = append(s.allowedRoots, hash.CanonicalPath(root))
+	}
+}
+
+// checkAllowedPath rejects a path outside the allowed roots
+func (s *Server) checkAllowedPath(path string) error {
+	if s.allowedRoots == nil {
+		return nil
+	}
+	for _, root := range s.allowedRoots {
+		if hash.Within(path, root) {
+			return nil
+		}
+	}
+	return fmt.Errorf("path %s is outside the allowed roots (%s)", path, strings.Join(s.allowedRoots, ", "))
+}
+
+// SetQuickScan sets how many files a quick=true analysis hashes and how
+// deep it may recurse. Non-positive values keep the defaults.
+func (s *Server) SetQuickScan(maxFiles, maxDepth int) {
+	s.quickFiles, s.quickDepth = DefaultQuickMaxFiles, DefaultQuickMaxDepth
+	if maxFiles > 0 {
+		s.quickFiles = maxFiles
+	}
+	if maxDepth > 0 {
+		s.quickDepth = maxDepth
+	}
+}
+
+// validateFocus checks focus against the vocabulary, returning its
+// configured spelling. Unknown values are logged unless rejected.
+func (s *Server) validateFocus(focus string) (string, error) {
+	normalized, known, err := s.focuses.Validate(focus)
+	if err != nil {
+		return "", err
+	}
+	if !known {
+		s.logger.Warn().Str("focus", focus).Strs("allowed", s.focuses.Values()).Msg("Unknown focus")
+	}
+	return normalized, nil
+}
+
+// analyzeOutcome reports how an rlm_analyze call was answered
+type analyzeOutcome int
+
+const (
+	analyzeRan        analyzeOutcome = iota // The call ran its own analysis
+	analyzeJoined                           // An identical running analysis answered it
+	analyzeReplayed                         // Its idempotency key's earlier result answered it
+	analyzeInProgress                       // Its idempotency key is held by a call still running
+)
+
+// analyze runs an rlm_analyze call, interactive or queued. A call whose
+// idempotency key is in flight or completed returns without running, and a
+// call identical to one already running waits for that analysis's result;
+// the outcome says which happened. Otherwise it takes an analysis slot,
+// waiting for one if wait is set; a call refused a slot fails with a
+// *notStartedError.
+func (s *Server) analyze(ctx context.Context, args map[string]interface{}, wait bool, meta *RequestMeta) (*ToolResult, analyzeOutcome, error) {
+	key, _ := args["idempotency_key"].(string)
+	if result, outcome := s.beginIdempotent(key); result != nil {
+		return result, outcome, nil
+	}
+
+	result, joined, err := s.inFlight.Do(ctx, s.analyzeKey(args), func() (*ToolResult, error) {
+		release, busyErr := s.acquire(ctx, wait)
+		if busyErr != nil {
+			return nil, &notStartedError{busyErr}
+		}
+		defer release()
+		defer s.reportProgress(ctx, meta)()
+		return s.handleAnalyze(ctx, args)
+	})
+	outcome := analyzeRan
+	if joined {
+		s.logger.Info().Msg("Joined an identical analysis already in progress")
+		outcome = analyzeJoined
+	}
+
+	if err != nil {
+		s.finishIdempotent(key, nil)
+	} else {
+		s.finishIdempotent(key, result)
+	}
+	return result, outcome, err
+}
+
+// beginIdempotent claims an idempotency key, returning the result to send
+// instead of running the analysis when the key is in flight or completed
+func (s *Server) beginIdempotent(key string) (*ToolResult, analyzeOutcome) {
+	if key == "" || s.idempotency == nil {
+		return nil, analyzeRan
+	}
+
+	prior, inFlight := s.idempotency.Begin(key)
+	if inFlight {
+		return NewToolResult(fmt.Sprintf("An analysis with idempotency_key %q is already in progress. Retry later to get its result.", key)), analyzeInProgress
+	}
+	return prior, analyzeReplayed
+}
+
+// finishIdempotent records a successful result for key, or releases the key
+// when result is nil so a retry runs again
+func (s *Server) finishIdempotent(key string, result *ToolResult) {
+	if key == "" || s.idempotency == nil {
+		return
+	}
+
+	if result == nil || result.IsError {
+		s.idempotency.Abort(key)
+		return
+	}
+	if err := s.idempotency.Complete(key, result); err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to persist idempotency key")
+	}
+}
+
+// acquire reserves an analysis slot and the orchestrator, returning a
+// function that releases both. It waits for a free slot when wait is set
+// and otherwise fails with ErrServerBusy if there is none.
+func (s *Server) acquire(ctx context.Context, wait bool) (func(), error) {
+	if s.analysisSlots != nil {
+		select {
+		case s.analysisSlots <- struct{}{}:
+		default:
+			if !wait {
+				return nil, fmt.Errorf("%w: %d analyses already in progress", ErrServerBusy, cap(s.analysisSlots))
+			}
+			select {
+			case s.analysisSlots <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	s.runMu.Lock()
+	return func() {
+		stats := s.orchestrator.GetStats()
+		s.statsMu.Lock()
+		s.lastStats = stats
+		s.statsMu.Unlock()
+		s.metrics.Record(stats)
+
+		s.runMu.Unlock()
+		if s.analysisSlots != nil {
+			<-s.analysisSlots
+		}
+	}, nil
+}
+
 // RunStdio runs the MCP server on stdio
 func (s *Server) RunStdio(ctx context.Context) error {
 	s.logger.Info().Msg("RLM MCP server starting on stdio")
 
-	scanner := bufio.NewScanner(os.Stdin)
-	writer := bufio.NewWriter(os.Stdout)
+	return s.Serve(ctx, os.Stdin, os.Stdout)
+}
+
+// Serve reads newline-delimited JSON-RPC requests from r and writes responses to w
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	writer := bufio.NewWriter(w)
+
+	// Responses and the notifications sent while handling a request share
+	// the writer
+	var writeMu sync.Mutex
+	send := func(message []byte) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		writer.Write(message)
+		writer.WriteByte('\n')
+		writer.Flush()
+	}
+	ctx = withNotifier(ctx, func(method string, params interface{}) {
+		message, err := json.Marshal(Notification{JSONRPC: "2.0", Method: method, Params: params})
+		if err != nil {
+			s.logger.Error().Err(err).Msg("Failed to marshal notification")
+			return
+		}
+		send(message)
+	})
 
 	for scanner.Scan() {
 		line := scanner.Bytes()
@@ -48,6 +342,14 @@ func (s *Server) RunStdio(ctx context.Context) error {
 			continue
 		}
 
+		// A batch is a JSON array of requests, answered with an array
+		if line[0] == '[' {
+			if responseJSON := s.handleBatch(ctx, line); responseJSON != nil {
+				send(responseJSON)
+			}
+			continue
+		}
+
 		// Parse request
 		var req Request
 		if err := json.Unmarshal(line, &req); err != nil {
@@ -55,8 +357,13 @@ func (s *Server) RunStdio(ctx context.Context) error {
 			continue
 		}
 
+		s.logTraffic("Request received", req.Method, req.Params)
+
 		// Handle request
-		response := s.handleRequest(ctx, &req)
+		response := s.HandleRequest(ctx, &req)
+		if response == nil {
+			continue // Notifications are never answered
+		}
 
 		// Send response
 		responseJSON, err := json.Marshal(response)
@@ -65,9 +372,9 @@ func (s *Server) RunStdio(ctx context.Context) error {
 			continue
 		}
 
-		writer.Write(responseJSON)
-		writer.WriteByte('\n')
-		writer.Flush()
+		s.logTraffic("Response sent", req.Method, responseJSON)
+
+		send(responseJSON)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -77,8 +384,84 @@ func (s *Server) RunStdio(ctx context.Context) error {
 	return nil
 }
 
-// handleRequest processes a JSON-RPC request
-func (s *Server) handleRequest(ctx context.Context, req *Request) *Response {
+// handleBatch answers a JSON-RPC batch, as protocol version 2025-03-26
+// requires servers to accept. Its requests are handled concurrently and
+// their responses returned as one array, or nil when every request was a
+// notification.
+func (s *Server) handleBatch(ctx context.Context, line []byte) []byte {
+	var messages []json.RawMessage
+	if err := json.Unmarshal(line, &messages); err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to parse batch")
+		return nil
+	}
+
+	// An empty batch is answered with a single error, not an array
+	if len(messages) == 0 {
+		responseJSON, _ := json.Marshal(NewErrorResponse(nil, InvalidRequest, "empty batch"))
+		return responseJSON
+	}
+
+	var wg sync.WaitGroup
+	answers := make([]*Response, len(messages))
+	for i, message := range messages {
+		var req Request
+		if err := json.Unmarshal(message, &req); err != nil {
+			answers[i] = NewErrorResponse(nil, InvalidRequest, "invalid request")
+			continue
+		}
+		s.logTraffic("Request received", req.Method, req.Params)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			answers[i] = s.HandleRequest(ctx, &req)
+		}(i)
+	}
+	wg.Wait()
+
+	var responses []*Response
+	for _, answer := range answers {
+		if answer != nil { // Notifications are never answered
+			responses = append(responses, answer)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+
+	responseJSON, err := json.Marshal(responses)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to marshal batch response")
+		return nil
+	}
+	s.logTraffic("Response sent", "batch", responseJSON)
+	return responseJSON
+}
+
+// logTraffic logs a raw JSON payload at debug level, redacting it first if configured
+func (s *Server) logTraffic(msg, method string, payload json.RawMessage) {
+	event := s.logger.Debug()
+	if !event.Enabled() {
+		return
+	}
+
+	event = event.Str("method", method)
+	if s.redactor != nil {
+		event = event.Interface("payload", s.redactor.RedactJSON(payload))
+	} else if len(payload) > 0 {
+		event = event.RawJSON("payload", payload)
+	}
+	event.Msg(msg)
+}
+
+// HandleRequest processes a JSON-RPC request. It is safe for concurrent use.
+// Notifications return nil since they must not be answered.
+func (s *Server) HandleRequest(ctx context.Context, req *Request) *Response {
+	if req.IsNotification() {
+		s.handleNotification(req)
+		return nil
+	}
+
 	switch req.Method {
 	case "initialize":
 		return s.handleInitialize(req)
@@ -91,10 +474,52 @@ func (s *Server) handleRequest(ctx context.Context, req *Request) *Response {
 	}
 }
 
+// handleNotification handles a notification from the client. Unknown
+// notifications are ignored, as JSON-RPC requires.
+func (s *Server) handleNotification(req *Request) {
+	switch req.Method {
+	case "notifications/initialized":
+		s.logger.Debug().Msg("Client initialized")
+	case "notifications/cancelled":
+		s.logTraffic("Client cancelled a request", req.Method, req.Params)
+	default:
+		s.logger.Debug().Str("method", req.Method).Msg("Ignoring unknown notification")
+	}
+}
+
 // handleInitialize handles the initialize request
 func (s *Server) handleInitialize(req *Request) *Response {
+	var params InitializeParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return NewErrorResponse(req.ID, InvalidParams, fmt.Sprintf("invalid initialize parameters: %v", err))
+		}
+	}
+
+	version, err := negotiateProtocolVersion(params.ProtocolVersion)
+	if err != nil {
+		resp := NewErrorResponse(req.ID, InvalidParams, err.Error())
+		resp.Error.Data = map[string]interface{}{
+			"supported": SupportedProtocolVersions,
+			"requested": params.ProtocolVersion,
+		}
+		return resp
+	}
+
+	// Remember who is calling so stored analyses can be attributed, and
+	// what it supports for behavior depending on it
+	s.clientMu.Lock()
+	s.client = params.ClientInfo
+	s.capabilities = params.Capabilities
+	s.clientMu.Unlock()
+
+	s.logger.Debug().
+		Str("requested", params.ProtocolVersion).
+		Str("negotiated", version).
+		Msg("Negotiated protocol version")
+
 	result := InitializeResult{
-		ProtocolVersion: "2024-11-05",
+		ProtocolVersion: version,
 		ServerInfo: ServerInfo{
 			Name:    "rlm",
 			Version: s.version,
@@ -124,18 +549,41 @@ func (s *Server) handleToolCall(ctx context.Context, req *Request) *Response {
 		return NewErrorResponse(req.ID, InvalidParams, "invalid parameters")
 	}
 
+	if s.readOnly && writeTools[params.Name] {
+		return NewErrorResponse(req.ID, InvalidRequest, fmt.Sprintf("tool %s is disabled: server is in read-only mode", params.Name))
+	}
+
+	if s.rateLimiter != nil {
+		if ok, retryAfter := s.rateLimiter.Allow(params.Name); !ok {
+			return NewErrorResponse(req.ID, RateLimited, fmt.Sprintf("rate limited: %s, retry after %s", params.Name, retryAfter))
+		}
+	}
+
 	var result *ToolResult
 	var err error
 
 	switch params.Name {
 	case "rlm_analyze":
-		result, err = s.handleAnalyze(ctx, params.Arguments)
+		if enqueue, _ := params.Arguments["enqueue"].(bool); enqueue {
+			result, err = s.enqueueAnalysis(params.Arguments)
+			break
+		}
+
+		result, _, err = s.analyze(ctx, params.Arguments, s.queueAnalyses, params.Meta)
+		var notStarted *notStartedError
+		if errors.As(err, &notStarted) {
+			return NewErrorResponse(req.ID, ServerBusy, notStarted.Error())
+		}
 	case "rlm_check_freshness":
 		result, err = s.handleCheckFreshness(ctx, params.Arguments)
 	case "rlm_status":
 		result, err = s.handleStatus(ctx, params.Arguments)
+	case "rlm_job_status":
+		result, err = s.handleJobStatus(ctx, params.Arguments)
 	case "rlm_search_rag":
 		result, err = s.handleSearchRAG(ctx, params.Arguments)
+	case "rlm_rate_result":
+		result, err = s.handleRateResult(ctx, params.Arguments)
 	default:
 		return NewErrorResponse(req.ID, MethodNotFound, fmt.Sprintf("unknown tool: %s", params.Name))
 	}
@@ -147,122 +595,532 @@ func (s *Server) handleToolCall(ctx context.Context, req *Request) *Response {
 	return NewResponse(req.ID, result)
 }
 
-// handleAnalyze implements the rlm_analyze tool
-func (s *Server) handleAnalyze(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
-	// Extract parameters
+// analyzePath returns the canonical path rlm_analyze should analyze,
+// widened to its package root for scope=package
+func (s *Server) analyzePath(args map[string]interface{}) (string, error) {
 	path := "."
 	if p, ok := args["path"].(string); ok {
 		path = p
 	}
+	path = hash.CanonicalPath(path)
 
-	query, ok := args["query"].(string)
-	if !ok {
+	// Widen the path to the package containing it
+	switch scope, _ := args["scope"].(string); scope {
+	case "", "path":
+	case "package":
+		root, err := hash.FindPackageRoot(path, s.rootMarkers)
+		if err != nil {
+			return "", fmt.Errorf("scope=package: %w", err)
+		}
+		path = root
+	default:
+		return "", fmt.Errorf("unknown scope %q (expected path or package)", scope)
+	}
+
+	// Checked after widening, so scope=package can't reach outside either
+	if err := s.checkAllowedPath(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// analyzeKey identifies an rlm_analyze call by every argument that shapes
+// its outcome, so only identical calls share one in-flight analysis, even
+// when they spell the path differently or list queries in another order.
+// Invalid arguments fall back to the raw path; handleAnalyze reports them.
+func (s *Server) analyzeKey(args map[string]interface{}) string {
+	path, err := s.analyzePath(args)
+	if err != nil {
+		path, _ = args["path"].(string)
+	}
+
+	var queries []string
+	if q, ok := args["query"].(string); ok && q != "" {
+		queries = append(queries, q)
+	}
+	if qs, ok := args["queries"].([]interface{}); ok {
+		for _, q := range qs {
+			if qStr, ok := q.(string); ok && qStr != "" {
+				queries = append(queries, qStr)
+			}
+		}
+	}
+	sort.Strings(queries)
+
+	// The path and queries are normalized above; how a call is delivered
+	// doesn't change what it computes
+	key := map[string]interface{}{"path": path, "queries": queries}
+	for k, v := range args {
+		switch k {
+		case "path", "scope", "query", "queries", "idempotency_key", "enqueue":
+		default:
+			key[k] = v
+		}
+	}
+	if namespace, _ := key["namespace"].(string); namespace == "" {
+		key["namespace"] = storage.DefaultNamespace
+	}
+
+	// Map keys marshal sorted, so equal arguments give equal keys
+	encoded, _ := json.Marshal(key)
+	return string(encoded)
+}
+
+// handleAnalyze implements the rlm_analyze tool
+func (s *Server) handleAnalyze(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	path, err := s.analyzePath(args)
+	if err != nil {
+		return nil, err
+	}
+
+	outputPath, _ := args["output_path"].(string)
+	if outputPath != "" {
+		if err := s.checkAllowedPath(outputPath); err != nil {
+			return nil, fmt.Errorf("output_path: %w", err)
+		}
+	}
+
+	// Omitted query, focus and tags fall back to the project's defaults
+	defaults, err := loadProjectDefaults(path)
+	if err != nil {
+		return nil, err
+	}
+	if defaults == nil {
+		defaults = &projectDefaults{}
+	}
+
+	// Accept a single query and/or a list of queries
+	var queries []string
+	if q, ok := args["query"].(string); ok && q != "" {
+		queries = append(queries, q)
+	}
+	if qs, ok := args["queries"].([]interface{}); ok {
+		for _, q := range qs {
+			if qStr, ok := q.(string); ok && qStr != "" {
+				queries = append(queries, qStr)
+			}
+		}
+	}
+	if len(queries) == 0 && defaults.Query != "" {
+		queries = append(queries, defaults.Query)
+	}
+	if len(queries) == 0 {
 		return nil, fmt.Errorf("query parameter is required")
 	}
 
-	focus := ""
-	if f, ok := args["focus"].(string); ok {
+	focus := defaults.Focus
+	if f, ok := args["focus"].(string); ok && f != "" {
 		focus = f
 	}
+	focus, err = s.validateFocus(focus)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := defaults.Tags
+	if ts, ok := args["tags"].([]interface{}); ok {
+		tags = nil
+		for _, t := range ts {
+			if tag, ok := t.(string); ok && tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	namespace, _ := args["namespace"].(string)
 
 	forceRefresh := false
 	if fr, ok := args["force_refresh"].(bool); ok {
 		forceRefresh = fr
 	}
 
-	// Check staleness
+	allowLarge := false
+	if al, ok := args["allow_large"].(bool); ok {
+		allowLarge = al
+	}
+
+	// Override the document assembly strategy for this analysis only
+	if assembly, ok := args["assembly"].(string); ok && assembly != "" {
+		manifest, _ := args["manifest"].(string)
+		assembler, err := orchestrator.NewAssembler(assembly, manifest)
+		if err != nil {
+			return nil, err
+		}
+		previous := s.orchestrator.Assembler()
+		s.orchestrator.SetAssembler(assembler)
+		defer s.orchestrator.SetAssembler(previous)
+	}
+
+	// Seed this analysis with the previous one of the same path
+	if warmStart, _ := args["warm_start"].(bool); warmStart {
+		previous := s.orchestrator.WarmStart()
+		s.orchestrator.SetWarmStart(func(ctx context.Context, path string) (map[string]interface{}, error) {
+			return s.priorAnalysisHints(ctx, path, namespace)
+		})
+		defer s.orchestrator.SetWarmStart(previous)
+	}
+
+	// A quick scan hashes a capped sample and recurses shallowly, so it
+	// stays fast on trees too large for a full analysis
+	quick, _ := args["quick"].(bool)
+	if quick {
+		previous := s.orchestrator.MaxRecursionDepth()
+		s.orchestrator.SetMaxRecursionDepth(s.quickDepth)
+		defer s.orchestrator.SetMaxRecursionDepth(previous)
+	}
+
+	// Refuse accidentally huge trees before doing any expensive work
+	if !allowLarge && !quick {
+		if _, err := hash.NewFileHasher(s.orchestrator.HashOptions()).CheckTreeSizeContext(ctx, path, s.maxFiles, s.maxBytes); err != nil {
+			return nil, fmt.Errorf("%w; narrow the path or set allow_large=true to analyze anyway", err)
+		}
+	}
+
+	// Skip the analysis when every query was already answered for this
+	// path and nothing changed since
 	if !forceRefresh {
-		// Load latest analysis to check staleness
-		analyses, err := s.storage.GetAll(ctx)
-		if err == nil && len(analyses) > 0 {
-			// Get most recent analysis for this path
-			latest := analyses[len(analyses)-1]
-			if latest.Path == path {
-				// Check if stale
-				staleness, err := hash.CheckStaleness(latest.FileHashes, path, latest.Timestamp)
-				if err == nil && !staleness.Stale {
-					suggestion := fmt.Sprintf("Previous analysis is still fresh. Use rlm_search_rag to retrieve results, or set force_refresh=true to re-analyze.\nLast analyzed: %s", latest.Timestamp.Format("2006-01-02 15:04:05"))
-					return NewToolResult(suggestion), nil
-				}
-			}
+		if lastAnalyzed, fresh := s.freshAnalysis(ctx, path, namespace, queries, focus); fresh {
+			suggestion := fmt.Sprintf("Previous analysis is still fresh. Use rlm_search_rag to retrieve results, or set force_refresh=true to re-analyze.\nLast analyzed: %s", lastAnalyzed.Format("2006-01-02 15:04:05"))
+			return NewToolResult(suggestion), nil
 		}
 	}
 
 	// Compute file hashes before analysis
-	hasher := hash.NewFileHasher()
-	fileHashes, err := hasher.ComputeDirectoryHash(path)
+	hasher := hash.NewFileHasher(s.orchestrator.HashOptions())
+	var fileHashes map[string]string
+	var fileStats map[string]hash.FileTypeStat
+	if quick {
+		fileHashes, err = hasher.ComputeQuickHash(path, s.quickFiles)
+		fileStats = make(map[string]hash.FileTypeStat)
+	} else {
+		fileHashes, fileStats, err = hasher.ComputeDirectoryHashWithStatsContext(ctx, path)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	if err != nil {
 		s.logger.Warn().Err(err).Msg("Failed to compute file hashes")
 		fileHashes = make(map[string]string)
+		fileStats = make(map[string]hash.FileTypeStat)
+	}
+	skipped := hasher.SkippedFiles()
+	if len(skipped) > 0 {
+		s.logger.Warn().Int("count", len(skipped)).Strs("sample", skippedSample(skipped)).Msg("Skipped unreadable files")
 	}
 
+	// Multiple queries share exploration work in a single run
+	if len(queries) > 1 {
+		return s.analyzeQueries(ctx, path, queries, focus, namespace, tags, quick, hasher.Algorithm(), fileHashes, fileStats, skipped, outputPath)
+	}
+	query := queries[0]
+
 	// Run analysis
 	result, err := s.orchestrator.AnalyzeDocument(ctx, path, query)
+	if errors.Is(err, orchestrator.ErrTimeBudgetExceeded) && result != nil {
+		stats := s.orchestrator.GetStats()
+		return partialResult(err, map[string]interface{}{
+			"path":           path,
+			"query":          query,
+			"focus":          focus,
+			"result":         result.Content,
+			"metadata":       result.Metadata,
+			"details":        result.Details,
+			"stats":          stats,
+			"cost_breakdown": stats.CostBreakdown(),
+		}), nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("analysis failed: %w", err)
 	}
+	if quick {
+		result = markPartial(result)
+	}
+	result = markSkipped(result, skipped)
 
 	// Store results in RAG
-	analysisData := &storage.AnalysisData{
-		Query:      query,
-		Focus:      focus,
-		Timestamp:  time.Now(),
-		Result:     map[string]interface{}{"content": result.Content, "metadata": result.Metadata},
-		Stats:      s.orchestrator.GetStats(),
-		Path:       path,
-		FileHashes: fileHashes,
+	analysisData := s.storeAnalysis(ctx, query, focus, namespace, tags, path, result, hasher.Algorithm(), fileHashes, fileStats)
+
+	// Format response
+	stats := s.orchestrator.GetStats()
+	response := map[string]interface{}{
+		"success":        true,
+		"path":           path,
+		"query":          query,
+		"focus":          focus,
+		"tags":           tags,
+		"result":         result.Content,
+		"details":        result.Details,
+		"stats":          stats,
+		"cost_breakdown": stats.CostBreakdown(),
+		"rag_location":   fmt.Sprintf(".rlm/analysis_%s.json", analysisData.ID),
+		"files_tracked":  len(fileHashes),
+		"file_stats":     fileStats,
+		"partial":        quick,
+		"skipped_files":  len(skipped),
+		"skipped_sample": skippedSample(skipped),
 	}
+	s.writeOutput(outputPath, result.Content, response)
 
-	if err := s.storage.Store(ctx, analysisData); err != nil {
-		s.logger.Warn().Err(err).Msg("Failed to store results")
+	responseJSON, _ := json.MarshalIndent(response, "", "  ")
+	return NewToolResult(string(responseJSON)), nil
+}
+
+// markPartial flags a quick-scan result as approximate in its metadata,
+// which is stored with the analysis
+func markPartial(result *orchestrator.AnalysisResult) *orchestrator.AnalysisResult {
+	return withMetadata(result, "partial", true)
+}
+
+// markSkipped records the files hashing couldn't read in a result's
+// metadata, so the stored analysis shows its blind spots
+func markSkipped(result *orchestrator.AnalysisResult, skipped []string) *orchestrator.AnalysisResult {
+	if len(skipped) == 0 {
+		return result
+	}
+	return withMetadata(result, "skipped_files", skipped)
+}
+
+// withMetadata returns a copy of result with key set in its metadata
+func withMetadata(result *orchestrator.AnalysisResult, key string, value interface{}) *orchestrator.AnalysisResult {
+	metadata := make(map[string]interface{}, len(result.Metadata)+1)
+	for k, v := range result.Metadata {
+		metadata[k] = v
+	}
+	metadata[key] = value
+
+	marked := *result
+	marked.Metadata = metadata
+	return &marked
+}
+
+// skippedSampleSize caps the skipped paths listed in a response
+const skippedSampleSize = 10
+
+// skippedSample returns the first few skipped paths for a response
+func skippedSample(skipped []string) []string {
+	if len(skipped) > skippedSampleSize {
+		return skipped[:skippedSampleSize]
+	}
+	return append([]string{}, skipped...)
+}
+
+// analyzeQueries runs several queries against one path and stores each result
+func (s *Server) analyzeQueries(ctx context.Context, path string, queries []string, focus, namespace string, tags []string, quick bool, hashAlgorithm string, fileHashes map[string]string, fileStats map[string]hash.FileTypeStat, skipped []string, outputPath string) (*ToolResult, error) {
+	results, err := s.orchestrator.AnalyzeQueries(ctx, path, queries)
+	partial := errors.Is(err, orchestrator.ErrTimeBudgetExceeded) && len(results) > 0
+	if err != nil && !partial {
+		return nil, fmt.Errorf("analysis failed: %w", err)
+	}
+
+	formattedResults := make([]map[string]interface{}, len(results))
+	for i, result := range results {
+		// The query the time budget cut short; the ones before it finished
+		if partial && i == len(results)-1 {
+			formattedResults[i] = map[string]interface{}{
+				"query":    queries[i],
+				"result":   result.Content,
+				"metadata": result.Metadata,
+				"partial":  true,
+			}
+			continue
+		}
+		if quick {
+			result = markPartial(result)
+		}
+		result = markSkipped(result, skipped)
+		analysisData := s.storeAnalysis(ctx, queries[i], focus, namespace, tags, path, result, hashAlgorithm, fileHashes, fileStats)
+		formattedResults[i] = map[string]interface{}{
+			"query":        queries[i],
+			"result":       result.Content,
+			"rag_location": fmt.Sprintf(".rlm/analysis_%s.json", analysisData.ID),
+		}
 	}
 
-	// Format response
 	stats := s.orchestrator.GetStats()
 	response := map[string]interface{}{
-		"success":       true,
-		"result":        result.Content,
-		"stats":         stats,
-		"rag_location":  fmt.Sprintf(".rlm/analysis_%s.json", analysisData.ID),
-		"files_tracked": len(fileHashes),
+		"success":        true,
+		"path":           path,
+		"results":        formattedResults,
+		"stats":          stats,
+		"cost_breakdown": stats.CostBreakdown(),
+		"files_tracked":  len(fileHashes),
+		"file_stats":     fileStats,
+		"partial":        quick,
+		"skipped_files":  len(skipped),
+		"skipped_sample": skippedSample(skipped),
 	}
+	if partial {
+		return partialResult(err, response), nil
+	}
+	s.writeOutput(outputPath, orchestrator.FormatQueryResults(queries, results), response)
 
 	responseJSON, _ := json.MarshalIndent(response, "", "  ")
 	return NewToolResult(string(responseJSON)), nil
 }
 
-// handleCheckFreshness implements the rlm_check_freshness tool
-func (s *Server) handleCheckFreshness(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
-	path := "."
-	if p, ok := args["path"].(string); ok {
-		path = p
+// partialResult reports an analysis the time budget stopped early as a
+// failed call whose response still carries the work done so far. The
+// partial result isn't stored, so re-running the call resumes the saved
+// state instead of finding a fresh analysis.
+func partialResult(err error, response map[string]interface{}) *ToolResult {
+	response["success"] = false
+	response["partial"] = true
+	response["error"] = err.Error()
+	response["hint"] = "The analysis stopped at its time budget. Re-run the same call to resume it."
+
+	responseJSON, _ := json.MarshalIndent(response, "", "  ")
+	result := NewToolResult(string(responseJSON))
+	result.IsError = true
+	return result
+}
+
+// writeOutput writes content to the requested output_path, if any, and
+// reports the outcome in response. A write failure doesn't fail the request
+// since the analysis already ran and was stored.
+func (s *Server) writeOutput(outputPath, content string, response map[string]interface{}) {
+	if outputPath == "" {
+		return
 	}
 
-	// Load latest analysis
-	analyses, err := s.storage.GetAll(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load analyses: %w", err)
+	if err := orchestrator.WriteOutputFile(outputPath, content); err != nil {
+		s.logger.Warn().Err(err).Str("path", outputPath).Msg("Failed to write output file")
+		response["output_error"] = err.Error()
+		return
 	}
+	response["output_path"] = outputPath
+}
 
-	if len(analyses) == 0 {
-		return NewToolResult("No previous analyses found."), nil
+// storeAnalysis saves an analysis result in the RAG store, recording
+// hashAlgorithm as the algorithm fileHashes were computed with. Storage
+// failures are logged rather than failing the request, since the analysis
+// already ran.
+func (s *Server) storeAnalysis(ctx context.Context, query, focus, namespace string, tags []string, path string, result *orchestrator.AnalysisResult, hashAlgorithm string, fileHashes map[string]string, fileStats map[string]hash.FileTypeStat) *storage.AnalysisData {
+	analysisData := &storage.AnalysisData{
+		Query:         query,
+		Focus:         focus,
+		Tags:          tags,
+		Namespace:     namespace,
+		Timestamp:     time.Now(),
+		Result:        map[string]interface{}{"content": result.Content, "metadata": result.Metadata, "details": result.Details},
+		Stats:         s.orchestrator.GetStats(),
+		Path:          path,
+		FileHashes:    fileHashes,
+		HashAlgorithm: hashAlgorithm,
+		FileStats:     fileStats,
+		Trace:         result.Trace,
+	}
+
+	s.clientMu.Lock()
+	analysisData.ClientName = s.client.Name
+	analysisData.ClientVersion = s.client.Version
+	s.clientMu.Unlock()
+
+	if err := s.storage.Store(ctx, analysisData); err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to store results")
 	}
 
-	// Find most recent analysis for this path
+	return analysisData
+}
+
+// latestAnalysis returns the newest stored analysis of path in namespace,
+// matching equivalent spellings of the path, or nil if there is none
+func (s *Server) latestAnalysis(ctx context.Context, path, namespace string) (*storage.AnalysisData, error) {
+	return s.newestAnalysis(ctx, func(data *storage.AnalysisData) bool {
+		return hash.SamePath(data.Path, path) && storage.SameNamespace(data.Namespace, namespace)
+	})
+}
+
+// newestAnalysis returns the newest stored analysis match accepts, or nil
+// if there is none
+func (s *Server) newestAnalysis(ctx context.Context, match func(*storage.AnalysisData) bool) (*storage.AnalysisData, error) {
 	var latest *storage.AnalysisData
-	for i := len(analyses) - 1; i >= 0; i-- {
-		if analyses[i].Path == path {
-			latest = analyses[i]
-			break
+	err := s.storage.Walk(ctx, func(data *storage.AnalysisData) error {
+		if match(data) && (latest == nil || data.Timestamp.After(latest.Timestamp)) {
+			latest = data
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load analyses: %w", err)
+	}
+	return latest, nil
+}
+
+// freshAnalysis reports whether each query has a stored analysis of path
+// in namespace, with the same focus, that no file change has made stale.
+// lastAnalyzed is when the oldest of them ran.
+func (s *Server) freshAnalysis(ctx context.Context, path, namespace string, queries []string, focus string) (lastAnalyzed time.Time, fresh bool) {
+	for _, query := range queries {
+		latest, err := s.newestAnalysis(ctx, func(data *storage.AnalysisData) bool {
+			return data.Query == query && data.Focus == focus &&
+				hash.SamePath(data.Path, path) && storage.SameNamespace(data.Namespace, namespace)
+		})
+		if err != nil || latest == nil {
+			return time.Time{}, false
+		}
+		staleness, err := hash.CheckStaleness(s.orchestrator.HashOptions(), latest.FileHashes, latest.HashAlgorithm, path, latest.Timestamp)
+		if err != nil || staleness.Stale {
+			return time.Time{}, false
 		}
+		if lastAnalyzed.IsZero() || latest.Timestamp.Before(lastAnalyzed) {
+			lastAnalyzed = latest.Timestamp
+		}
+	}
+	return lastAnalyzed, len(queries) > 0
+}
+
+// priorAnalysisHints summarizes the newest stored analysis of path in
+// namespace and the files changed since it, for orchestrator.WarmStartLoader.
+// Returns nil when path has never been analyzed there.
+func (s *Server) priorAnalysisHints(ctx context.Context, path, namespace string) (map[string]interface{}, error) {
+	latest, err := s.latestAnalysis(ctx, path, namespace)
+	if err != nil || latest == nil {
+		return nil, err
+	}
+
+	hints := map[string]interface{}{
+		"id":          latest.ID,
+		"query":       latest.Query,
+		"analyzed_at": latest.Timestamp,
+		"result":      latest.Result["content"],
+	}
+	if details, ok := latest.Result["details"]; ok && details != nil {
+		hints["details"] = details
+	}
+
+	// Point the Explorer at what changed; everything else is as before
+	if staleness, err := hash.CheckStaleness(s.orchestrator.HashOptions(), latest.FileHashes, latest.HashAlgorithm, path, latest.Timestamp); err == nil {
+		hints["changed_files"] = staleness.ChangedFiles
+		hints["new_files"] = staleness.NewFiles
+		hints["deleted_files"] = staleness.DeletedFiles
+	}
+
+	return hints, nil
+}
+
+// handleCheckFreshness implements the rlm_check_freshness tool
+func (s *Server) handleCheckFreshness(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	path := "."
+	if p, ok := args["path"].(string); ok {
+		path = p
+	}
+	path = hash.CanonicalPath(path)
+	if err := s.checkAllowedPath(path); err != nil {
+		return nil, err
 	}
 
+	// The same analysis rlm_analyze would build on
+	namespace, _ := args["namespace"].(string)
+	latest, err := s.latestAnalysis(ctx, path, namespace)
+	if err != nil {
+		return nil, err
+	}
 	if latest == nil {
 		return NewToolResult(fmt.Sprintf("No previous analysis found for path: %s", path)), nil
 	}
 
 	// Check staleness
-	report, err := hash.CheckStaleness(latest.FileHashes, path, latest.Timestamp)
+	report, err := hash.CheckStaleness(s.orchestrator.HashOptions(), latest.FileHashes, latest.HashAlgorithm, path, latest.Timestamp)
 	if err != nil {
 		return nil, fmt.Errorf("staleness check failed: %w", err)
 	}
@@ -284,7 +1142,9 @@ func (s *Server) handleCheckFreshness(ctx context.Context, args map[string]inter
 
 // handleStatus implements the rlm_status tool
 func (s *Server) handleStatus(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
-	stats := s.orchestrator.GetStats()
+	s.statsMu.Lock()
+	stats := s.lastStats
+	s.statsMu.Unlock()
 
 	// Check if analysis is in progress
 	hasState := s.orchestrator.HasState()
@@ -294,6 +1154,9 @@ func (s *Server) handleStatus(ctx context.Context, args map[string]interface{})
 		"stats":      stats,
 		"storage":    s.storage.Name(),
 	}
+	if s.queue != nil {
+		status["jobs"] = s.queue.Counts()
+	}
 
 	statusJSON, _ := json.MarshalIndent(status, "", "  ")
 	return NewToolResult(string(statusJSON)), nil
@@ -311,23 +1174,69 @@ func (s *Server) handleSearchRAG(ctx context.Context, args map[string]interface{
 		maxResults = int(mr)
 	}
 
+	minScore := 0.0
+	if ms, ok := args["min_score"].(float64); ok {
+		minScore = ms
+	}
+
+	opts := storage.SearchOptions{Limit: maxResults}
+	if since, ok := args["since"].(string); ok && since != "" {
+		sinceTime, err := storage.ParseSince(since, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		opts.Since = sinceTime
+	}
+	if focus, ok := args["focus"].(string); ok && focus != "" {
+		focus, err := s.validateFocus(focus)
+		if err != nil {
+			return nil, err
+		}
+		opts.Focus = focus
+	}
+	opts.Namespace, _ = args["namespace"].(string)
+
 	// Search
-	results, err := s.storage.Search(ctx, query, maxResults)
+	results := make([]*storage.SearchResult, 0)
+	err := s.storage.SearchStream(ctx, query, opts, func(result *storage.SearchResult) error {
+		results = append(results, result)
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("search failed: %w", err)
 	}
 
+	// Results are ranked best first, so the cutoff only trims the tail
+	if minScore > 0 {
+		for i, r := range results {
+			if r.Score < minScore {
+				results = results[:i]
+				break
+			}
+		}
+	}
+
+	summaryOnly, _ := args["summary_only"].(bool)
+
 	// Format results
 	formattedResults := make([]map[string]interface{}, len(results))
 	for i, r := range results {
-		formattedResults[i] = map[string]interface{}{
+		formatted := map[string]interface{}{
+			"id":            r.Data.ID,
 			"query":         r.Data.Query,
 			"focus":         r.Data.Focus,
+			"namespace":     r.Data.Namespace,
+			"path":          r.Data.Path,
 			"timestamp":     r.Data.Timestamp.Format("2006-01-02 15:04:05"),
 			"score":         r.Score,
+			"rating":        r.Data.Feedback.Rating(),
 			"search_method": r.SearchMethod,
-			"result":        r.Data.Result,
+			"snippet":       storage.Snippet(r.Data, storage.SnippetLength),
 		}
+		if !summaryOnly {
+			formatted["result"] = r.Data.Result
+		}
+		formattedResults[i] = formatted
 	}
 
 	response := map[string]interface{}{
@@ -340,6 +1249,33 @@ func (s *Server) handleSearchRAG(ctx context.Context, args map[string]interface{
 	return NewToolResult(string(responseJSON)), nil
 }
 
+// handleRateResult implements the rlm_rate_result tool
+func (s *Server) handleRateResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	id, ok := args["id"].(string)
+	if !ok || id == "" {
+		return nil, fmt.Errorf("id parameter is required")
+	}
+	helpful, ok := args["helpful"].(bool)
+	if !ok {
+		return nil, fmt.Errorf("helpful parameter is required")
+	}
+
+	data, err := s.storage.Rate(ctx, id, helpful)
+	if err != nil {
+		return nil, fmt.Errorf("rating failed: %w", err)
+	}
+
+	response := map[string]interface{}{
+		"id":        data.ID,
+		"helpful":   data.Feedback.Helpful,
+		"unhelpful": data.Feedback.Unhelpful,
+		"rating":    data.Feedback.Rating(),
+	}
+
+	responseJSON, _ := json.MarshalIndent(response, "", "  ")
+	return NewToolResult(string(responseJSON)), nil
+}
+
 // Close cleanly shuts down the server
 func (s *Server) Close() error {
 	if s.storage != nil {
@@ -1,7 +1,26 @@
 package mcp
 
-// defineTools returns the list of MCP tools provided by this server
+// writeTools are tools that cost money or mutate the RAG store
+var writeTools = map[string]bool{
+	"rlm_analyze":     true,
+	"rlm_rate_result": true,
+}
+
+// defineTools returns the list of MCP tools provided by this server,
+// omitting write tools in read-only mode
 func (s *Server) defineTools() []Tool {
+	tools := make([]Tool, 0)
+	for _, tool := range s.allTools() {
+		if s.readOnly && writeTools[tool.Name] {
+			continue
+		}
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// allTools returns every tool this server can provide
+func (s *Server) allTools() []Tool {
 	return []Tool{
 		{
 			Name:        "rlm_analyze",
@@ -13,25 +32,73 @@ func (s *Server) defineTools() []Tool {
 						"type":        "string",
 						"description": "Path to file or directory to analyze (default: current directory)",
 					},
+					"scope": map[string]interface{}{
+						"type":        "string",
+						"description": "'path' analyzes path as given; 'package' analyzes the nearest enclosing package or module root (go.mod, package.json, ...) instead (default: path)",
+						"enum":        []string{"path", "package"},
+					},
 					"query": map[string]interface{}{
 						"type":        "string",
-						"description": "Analysis objective or question (required)",
+						"description": "Analysis objective or question (required unless queries is given or the project's .rlm/defaults.yaml sets one)",
+					},
+					"queries": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Several questions to answer about the same path in one run, sharing exploration work",
+					},
+					"focus": s.focusSchema("Analysis domain: 'security', 'architecture', 'performance', 'testing', 'documentation', etc."),
+					"tags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Labels stored with the analysis (default: tags from the project's .rlm/defaults.yaml)",
+					},
+					"namespace": map[string]interface{}{
+						"type":        "string",
+						"description": "Store the analysis in this namespace, isolating unrelated projects that share a store (default: default)",
+					},
+					"output_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Also write the result content to this file (e.g. .rlm/latest.md), creating parent directories and overwriting previous output",
+					},
+					"idempotency_key": map[string]interface{}{
+						"type":        "string",
+						"description": "Client-chosen key making retries safe: a repeat while running reports progress, and a repeat after completion returns the original result instead of re-analyzing",
 					},
-					"focus": map[string]interface{}{
+					"enqueue": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Queue the analysis to run in the background and return a job ID at once; check it with rlm_job_status (default: false)",
+					},
+					"assembly": map[string]interface{}{
+						"type":        "string",
+						"description": "Order in which a directory's files are presented: 'path', 'size', 'readme-first' or 'manifest' (default: server config)",
+						"enum":        []string{"path", "size", "readme-first", "manifest"},
+					},
+					"manifest": map[string]interface{}{
 						"type":        "string",
-						"description": "Analysis domain: 'security', 'architecture', 'performance', 'testing', 'documentation', etc.",
+						"description": "Manifest file listing files in order, relative to path (assembly=manifest only; default: .rlm-manifest)",
 					},
 					"force_refresh": map[string]interface{}{
 						"type":        "boolean",
 						"description": "Force re-analysis even if cache is fresh (default: false)",
 					},
+					"allow_large": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Analyze even if the path exceeds the configured file count/size limits (default: false)",
+					},
+					"quick": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Fast, approximate first pass for very large trees: hashes a capped sample of files, recurses shallowly and marks the result partial (default: false)",
+					},
+					"warm_start": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Seed the analysis with what the previous analysis of this path found, and which files changed since, so unchanged areas need not be re-explored (default: false)",
+					},
 				},
-				"required": []string{"query"},
 			},
 		},
 		{
 			Name:        "rlm_check_freshness",
-			Description: "Check if previous analysis results are still current or if files have changed. Uses SHA256 file hashing to detect modifications, additions, or deletions since last analysis.",
+			Description: "Check if previous analysis results are still current or if files have changed. Compares file hashes, computed with the algorithm the analysis recorded, to detect modifications, additions, or deletions since the last analysis.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -39,6 +106,10 @@ func (s *Server) defineTools() []Tool {
 						"type":        "string",
 						"description": "Path that was previously analyzed (default: current directory)",
 					},
+					"namespace": map[string]interface{}{
+						"type":        "string",
+						"description": "Namespace the analysis was stored in (default: default)",
+					},
 				},
 			},
 		},
@@ -50,6 +121,19 @@ func (s *Server) defineTools() []Tool {
 				"properties": map[string]interface{}{},
 			},
 		},
+		{
+			Name:        "rlm_job_status",
+			Description: "Report the state (queued, running, done or failed) of analyses queued with rlm_analyze enqueue=true, and a finished job's result.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"job_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Job ID returned by rlm_analyze (default: list every job)",
+					},
+				},
+			},
+		},
 		{
 			Name:        "rlm_search_rag",
 			Description: "Search previously analyzed content (RAG - Retrieval Augmented Generation). Uses semantic search with Qdrant when available, falls back to keyword search. Results include relevance scores and staleness warnings.",
@@ -66,9 +150,59 @@ func (s *Server) defineTools() []Tool {
 						"minimum":     1,
 						"maximum":     50,
 					},
+					"focus": s.focusSchema("Only return analyses with this focus"),
+					"namespace": map[string]interface{}{
+						"type":        "string",
+						"description": "Only return analyses in this namespace (default: all namespaces)",
+					},
+					"since": map[string]interface{}{
+						"type":        "string",
+						"description": "Only return analyses at or after this time: RFC 3339, YYYY-MM-DD, a duration ago (36h) or days ago (7d)",
+					},
+					"summary_only": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Return only id, query, focus, path, timestamp, score and a snippet per hit, omitting the full result (default: false)",
+					},
+					"min_score": map[string]interface{}{
+						"type":        "number",
+						"description": "Drop results scoring below this relevance (same 0-100 scale as the returned score; default: 0)",
+						"minimum":     0,
+						"maximum":     100,
+					},
 				},
 				"required": []string{"query"},
 			},
 		},
+		{
+			Name:        "rlm_rate_result",
+			Description: "Rate a search result as helpful or unhelpful. Ratings persist with the analysis and boost or demote it in future rlm_search_rag results.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID of the analysis, as returned by rlm_search_rag (required)",
+					},
+					"helpful": map[string]interface{}{
+						"type":        "boolean",
+						"description": "true if the result was helpful, false if not (required)",
+					},
+				},
+				"required": []string{"id", "helpful"},
+			},
+		},
+	}
+}
+
+// focusSchema returns the schema of a focus argument, listing the allowed
+// values when a focus vocabulary is configured
+func (s *Server) focusSchema(description string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":        "string",
+		"description": description,
+	}
+	if values := s.focuses.Values(); len(values) > 0 {
+		schema["enum"] = values
 	}
+	return schema
 }
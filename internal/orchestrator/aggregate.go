@@ -0,0 +1,55 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ResultAggregator combines the results of a parent's children, keyed by
+// ReturnTo, into what the parent sees as its ChildResults when it is
+// dispatched again, e.g. to concatenate them, total their tokens or keep
+// only the best. It runs before every re-dispatch, over all children that
+// have returned so far.
+type ResultAggregator func(parent *Task, children map[string]*AnalysisResult) map[string]interface{}
+
+// dispatchTask returns the task to hand the dispatcher: the current task,
+// or with an Aggregator and child results, a copy carrying the aggregated
+// results. The current task keeps the raw results so later children are
+// aggregated with them and saved state stays resumable.
+func (o *Orchestrator) dispatchTask() (*Task, error) {
+	if o.config.Aggregator == nil || len(o.currentTask.ChildResults) == 0 {
+		return &o.currentTask, nil
+	}
+
+	children, err := childAnalyses(o.currentTask.ChildResults)
+	if err != nil {
+		return nil, err
+	}
+
+	task := o.currentTask
+	task.ChildResults = o.config.Aggregator(&o.currentTask, children)
+	return &task, nil
+}
+
+// childAnalyses converts child results to AnalysisResults. Results restored
+// from saved state are generic JSON maps and are decoded again.
+func childAnalyses(childResults map[string]interface{}) (map[string]*AnalysisResult, error) {
+	children := make(map[string]*AnalysisResult, len(childResults))
+	for returnTo, result := range childResults {
+		if analysis, ok := result.(*AnalysisResult); ok {
+			children[returnTo] = analysis
+			continue
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			return nil, fmt.Errorf("child result %q: %w", returnTo, err)
+		}
+		var analysis AnalysisResult
+		if err := json.Unmarshal(data, &analysis); err != nil {
+			return nil, fmt.Errorf("child result %q: %w", returnTo, err)
+		}
+		children[returnTo] = &analysis
+	}
+	return children, nil
+}
@@ -0,0 +1,75 @@
+package orchestrator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregatorSummarizesChildren(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir()
+	config.CacheEnabled = false
+	config.Aggregator = func(parent *orchestrator.Task, children map[string]*orchestrator.AnalysisResult) map[string]interface{} {
+		tokens := 0
+		for _, child := range children {
+			tokens += child.TokenCount
+		}
+		return map[string]interface{}{"children": len(children), "tokens": tokens}
+	}
+	orch := orchestrator.New(config, zerolog.Nop())
+
+	spawn := []string{"api", "db"}
+	var seen []map[string]interface{}
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		if task.Depth == 0 {
+			snapshot := make(map[string]interface{}, len(task.ChildResults))
+			for k, v := range task.ChildResults {
+				snapshot[k] = v
+			}
+			seen = append(seen, snapshot)
+
+			// The aggregate replaces the raw map, so count children from it
+			count := 0
+			if n, ok := task.ChildResults["children"].(int); ok {
+				count = n
+			}
+			if count < len(spawn) {
+				return &orchestrator.SubagentResult{
+					Type: orchestrator.ResultTypeContinuation,
+					Continuation: &orchestrator.ContinuationRequest{
+						Type:      "CONTINUATION",
+						AgentType: "Worker",
+						Task:      "inspect " + spawn[count],
+						Context:   map[string]interface{}{},
+						ReturnTo:  spawn[count],
+					},
+				}, nil
+			}
+		}
+
+		return &orchestrator.SubagentResult{
+			Type: orchestrator.ResultTypeAnalysis,
+			Analysis: &orchestrator.AnalysisResult{
+				Type:       "RESULT",
+				Content:    "done",
+				Metadata:   map[string]interface{}{},
+				TokenCount: 100,
+			},
+		}, nil
+	})
+
+	_, err := orch.AnalyzeDocument(context.Background(), "test.txt", "aggregate")
+	require.NoError(t, err)
+
+	// The root first runs with no children, then sees each aggregate in
+	// place of the raw child results
+	require.Len(t, seen, 3)
+	assert.Empty(t, seen[0])
+	assert.Equal(t, map[string]interface{}{"children": 1, "tokens": 100}, seen[1])
+	assert.Equal(t, map[string]interface{}{"children": 2, "tokens": 200}, seen[2])
+}
@@ -0,0 +1,218 @@
+package orchestrator
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+)
+
+// Document assembly strategies
+const (
+	AssemblyPath        = "path"
+	AssemblySize        = "size"
+	AssemblyManifest    = "manifest"
+	AssemblyReadmeFirst = "readme-first"
+)
+
+// DefaultManifestFile is the manifest read by the manifest strategy when
+// none is configured, relative to the analyzed directory
+const DefaultManifestFile = ".rlm-manifest"
+
+// DocumentAssembler decides the order in which a directory's files are
+// presented to the Explorer as "the document"
+type DocumentAssembler interface {
+	// Order returns the relative paths of files in presentation order
+	Order(root string, files []hash.FileEntry) ([]string, error)
+}
+
+// NewAssembler returns the assembler for a strategy name. An empty name
+// returns nil, leaving directories unassembled. manifestFile is only used
+// by the manifest strategy and defaults to DefaultManifestFile.
+func NewAssembler(strategy, manifestFile string) (DocumentAssembler, error) {
+	switch strategy {
+	case "":
+		return nil, nil
+	case AssemblyPath:
+		return PathAssembler{}, nil
+	case AssemblySize:
+		return SizeAssembler{}, nil
+	case AssemblyReadmeFirst:
+		return ReadmeFirstAssembler{}, nil
+	case AssemblyManifest:
+		if manifestFile == "" {
+			manifestFile = DefaultManifestFile
+		}
+		return ManifestAssembler{File: manifestFile}, nil
+	default:
+		return nil, fmt.Errorf("unknown assembly strategy %q (expected %s, %s, %s or %s)",
+			strategy, AssemblyPath, AssemblySize, AssemblyReadmeFirst, AssemblyManifest)
+	}
+}
+
+// AssembleDocument lists the files under root that a hasher created with
+// opts would hash and orders them with the assembler
+func AssembleDocument(assembler DocumentAssembler, root string, opts hash.Options) ([]string, error) {
+	files, err := hash.NewFileHasher(opts).ListFiles(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return assembler.Order(root, files)
+}
+
+// Binary file policies, deciding what the Explorer is told about assembled
+// files whose content is binary
+const (
+	BinaryInclude  = "include"  // List them like any other file
+	BinarySkip     = "skip"     // Leave them out
+	BinaryAnnotate = "annotate" // List them apart, as binary_files
+)
+
+// CheckBinaryPolicy returns an error for an unknown binary file policy.
+// Empty is BinaryInclude.
+func CheckBinaryPolicy(policy string) error {
+	switch policy {
+	case "", BinaryInclude, BinarySkip, BinaryAnnotate:
+		return nil
+	default:
+		return fmt.Errorf("unknown binary file policy %q (expected %s, %s or %s)",
+			policy, BinaryInclude, BinarySkip, BinaryAnnotate)
+	}
+}
+
+// SplitBinaryFiles separates the assembled files under root whose content
+// is binary (see hash.IsBinaryFile) from the rest, keeping their order
+func SplitBinaryFiles(root string, files []string) (text, binary []string) {
+	text = make([]string, 0, len(files))
+	for _, file := range files {
+		if hash.IsBinaryFile(filepath.Join(root, filepath.FromSlash(file))) {
+			binary = append(binary, file)
+		} else {
+			text = append(text, file)
+		}
+	}
+	return text, binary
+}
+
+// PathAssembler orders files lexicographically by path
+type PathAssembler struct{}
+
+// Order implements DocumentAssembler
+func (PathAssembler) Order(root string, files []hash.FileEntry) ([]string, error) {
+	sorted := append([]hash.FileEntry(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+	return filePaths(sorted), nil
+}
+
+// SizeAssembler orders files smallest first, so entry points and configs
+// come before large generated or data files. Ties are ordered by path.
+type SizeAssembler struct{}
+
+// Order implements DocumentAssembler
+func (SizeAssembler) Order(root string, files []hash.FileEntry) ([]string, error) {
+	sorted := append([]hash.FileEntry(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Size != sorted[j].Size {
+			return sorted[i].Size < sorted[j].Size
+		}
+		return sorted[i].Path < sorted[j].Path
+	})
+	return filePaths(sorted), nil
+}
+
+// ReadmeFirstAssembler puts README files first, shallowest first, followed
+// by the remaining files in path order
+type ReadmeFirstAssembler struct{}
+
+// Order implements DocumentAssembler
+func (ReadmeFirstAssembler) Order(root string, files []hash.FileEntry) ([]string, error) {
+	sorted := append([]hash.FileEntry(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool {
+		ri, rj := isReadme(sorted[i].Path), isReadme(sorted[j].Path)
+		if ri != rj {
+			return ri
+		}
+		if ri {
+			di, dj := strings.Count(sorted[i].Path, "/"), strings.Count(sorted[j].Path, "/")
+			if di != dj {
+				return di < dj
+			}
+		}
+		return sorted[i].Path < sorted[j].Path
+	})
+	return filePaths(sorted), nil
+}
+
+// ManifestAssembler orders files as listed in a manifest file (one relative
+// path per line; blank lines and # comments ignored). Listed files that do
+// not exist are skipped and unlisted files follow in path order.
+type ManifestAssembler struct {
+	File string // Relative to the analyzed directory unless absolute
+}
+
+// Order implements DocumentAssembler
+func (m ManifestAssembler) Order(root string, files []hash.FileEntry) ([]string, error) {
+	manifestPath := m.File
+	if !filepath.IsAbs(manifestPath) {
+		manifestPath = filepath.Join(root, manifestPath)
+	}
+
+	f, err := os.Open(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	defer f.Close()
+
+	remaining := make(map[string]bool, len(files))
+	for _, file := range files {
+		remaining[file.Path] = true
+	}
+
+	ordered := make([]string, 0, len(files))
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		listed := path.Clean(filepath.ToSlash(line))
+		if remaining[listed] {
+			ordered = append(ordered, listed)
+			delete(remaining, listed)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	rest, _ := PathAssembler{}.Order(root, files)
+	for _, p := range rest {
+		if remaining[p] {
+			ordered = append(ordered, p)
+		}
+	}
+
+	return ordered, nil
+}
+
+// isReadme reports whether a path names a README file
+func isReadme(p string) bool {
+	return strings.HasPrefix(strings.ToLower(path.Base(p)), "readme")
+}
+
+// filePaths returns the paths of files in order
+func filePaths(files []hash.FileEntry) []string {
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+	return paths
+}
@@ -0,0 +1,137 @@
+package orchestrator_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFixtureTree creates a small project tree and returns its root
+func writeFixtureTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]int{
+		"main.go":            300,
+		"README.md":          50,
+		"pkg/api/handler.go": 200,
+		"pkg/api/README.md":  20,
+		"pkg/util.go":        10,
+		"docs/guide.md":      100,
+	}
+	for name, size := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644))
+	}
+	return root
+}
+
+func TestAssemblyStrategies(t *testing.T) {
+	root := writeFixtureTree(t)
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".rlm-manifest"), []byte(
+		"# entry points first\npkg/api/handler.go\n\n./main.go\nmissing.go\n"), 0644))
+
+	tests := []struct {
+		strategy string
+		want     []string
+	}{
+		{orchestrator.AssemblyPath, []string{"README.md", "docs/guide.md", "main.go", "pkg/api/README.md", "pkg/api/handler.go", "pkg/util.go"}},
+		{orchestrator.AssemblySize, []string{"pkg/util.go", "pkg/api/README.md", "README.md", "docs/guide.md", "pkg/api/handler.go", "main.go"}},
+		{orchestrator.AssemblyReadmeFirst, []string{"README.md", "pkg/api/README.md", "docs/guide.md", "main.go", "pkg/api/handler.go", "pkg/util.go"}},
+		{orchestrator.AssemblyManifest, []string{"pkg/api/handler.go", "main.go", "README.md", "docs/guide.md", "pkg/api/README.md", "pkg/util.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			assembler, err := orchestrator.NewAssembler(tt.strategy, "")
+			require.NoError(t, err)
+
+			files, err := orchestrator.AssembleDocument(assembler, root, hash.Options{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, files)
+		})
+	}
+}
+
+func TestNewAssembler(t *testing.T) {
+	assembler, err := orchestrator.NewAssembler("", "")
+	require.NoError(t, err)
+	assert.Nil(t, assembler)
+
+	_, err = orchestrator.NewAssembler("random", "")
+	assert.Error(t, err)
+
+	// A missing manifest is an error rather than a silent fallback
+	assembler, err = orchestrator.NewAssembler(orchestrator.AssemblyManifest, "nope.txt")
+	require.NoError(t, err)
+	_, err = orchestrator.AssembleDocument(assembler, t.TempDir(), hash.Options{})
+	assert.Error(t, err)
+}
+
+func TestAssembledFilesReachDispatcher(t *testing.T) {
+	root := writeFixtureTree(t)
+
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir()
+	config.Assembler = orchestrator.ReadmeFirstAssembler{}
+	orch := orchestrator.New(config, zerolog.Nop())
+
+	var files interface{}
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		files = task.Context["files"]
+		return orchestrator.PlaceholderDispatcher(ctx, task)
+	})
+
+	_, err := orch.AnalyzeDocument(context.Background(), root, "overview")
+	require.NoError(t, err)
+	require.IsType(t, []string{}, files)
+	assert.Equal(t, "README.md", files.([]string)[0])
+}
+
+func TestBinaryFilesPolicy(t *testing.T) {
+	root := writeFixtureTree(t)
+	// A binary file behind a text extension
+	require.NoError(t, os.WriteFile(filepath.Join(root, "data.json"), []byte{0x89, 'P', 'N', 'G', 0x00, 0x1a, 0xff}, 0644))
+
+	analyze := func(policy string) map[string]interface{} {
+		config := orchestrator.DefaultConfig()
+		config.WorkDir = t.TempDir()
+		config.Assembler = orchestrator.PathAssembler{}
+		config.BinaryFiles = policy
+		orch := orchestrator.New(config, zerolog.Nop())
+
+		var seen map[string]interface{}
+		orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+			if task.Depth == 0 {
+				seen = task.Context
+			}
+			return orchestrator.PlaceholderDispatcher(ctx, task)
+		})
+		_, err := orch.AnalyzeDocument(context.Background(), root, "overview")
+		require.NoError(t, err)
+		return seen
+	}
+
+	included := analyze(orchestrator.BinaryInclude)
+	assert.Contains(t, included["files"], "data.json")
+	assert.NotContains(t, included, "binary_files")
+
+	skipped := analyze(orchestrator.BinarySkip)
+	assert.NotContains(t, skipped["files"], "data.json")
+	assert.Contains(t, skipped["files"], "main.go")
+	assert.NotContains(t, skipped, "binary_files")
+
+	annotated := analyze(orchestrator.BinaryAnnotate)
+	assert.NotContains(t, annotated["files"], "data.json")
+	assert.Equal(t, []string{"data.json"}, annotated["binary_files"])
+
+	assert.Error(t, orchestrator.CheckBinaryPolicy("base64"))
+}
@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"sort"
 	"time"
+
+	"github.com/kukks/claude-rlm/internal/fsutil"
 )
 
 const (
@@ -24,6 +26,13 @@ type CacheEntry struct {
 
 // GenerateCacheKey creates a SHA256 hash of the task parameters
 func GenerateCacheKey(task *Task) string {
+	return GenerateVersionedCacheKey(task, "")
+}
+
+// GenerateVersionedCacheKey is GenerateCacheKey salted with version, so
+// bumping the version misses every entry cached under another. The empty
+// version yields the same keys as GenerateCacheKey.
+func GenerateVersionedCacheKey(task *Task, version string) string {
 	// Sort context keys for consistent hashing
 	contextKeys := make([]string, 0, len(task.Context))
 	for k := range task.Context {
@@ -41,17 +50,26 @@ func GenerateCacheKey(task *Task) string {
 
 	// Hash: agent_type + task_description + sorted_context
 	key := fmt.Sprintf("%s|%s|%s", task.AgentType, task.TaskDescription, string(contextJSON))
+	if version != "" {
+		key = version + "|" + key
+	}
 	hash := sha256.Sum256([]byte(key))
 	return fmt.Sprintf("%x", hash)
 }
 
+// cacheKey returns the key task is cached and shared under, salted with
+// Config.CacheVersion
+func (o *Orchestrator) cacheKey(task *Task) string {
+	return GenerateVersionedCacheKey(task, o.config.CacheVersion)
+}
+
 // CheckCache looks for a valid cached result
 func (o *Orchestrator) CheckCache(task *Task) *AnalysisResult {
 	if !o.config.CacheEnabled {
 		return nil
 	}
 
-	cacheKey := GenerateCacheKey(task)
+	cacheKey := o.cacheKey(task)
 	cacheFile := filepath.Join(o.config.WorkDir, CacheDir, cacheKey+".json")
 
 	data, err := os.ReadFile(cacheFile)
@@ -82,7 +100,7 @@ func (o *Orchestrator) StoreCache(task *Task, result *AnalysisResult) error {
 		return nil
 	}
 
-	cacheKey := GenerateCacheKey(task)
+	cacheKey := o.cacheKey(task)
 	cacheDir := filepath.Join(o.config.WorkDir, CacheDir)
 
 	// Create cache directory if it doesn't exist
@@ -93,7 +111,7 @@ func (o *Orchestrator) StoreCache(task *Task, result *AnalysisResult) error {
 	entry := CacheEntry{
 		Result:    result,
 		Timestamp: time.Now(),
-		TTL:       o.config.CacheTTL,
+		TTL:       o.cacheTTL(result),
 	}
 
 	data, err := json.MarshalIndent(entry, "", "  ")
@@ -102,7 +120,18 @@ func (o *Orchestrator) StoreCache(task *Task, result *AnalysisResult) error {
 	}
 
 	cacheFile := filepath.Join(cacheDir, cacheKey+".json")
-	return os.WriteFile(cacheFile, data, 0644)
+	return fsutil.WriteFile(cacheFile, data, 0644, o.config.Fsync)
+}
+
+// cacheTTL scales the configured TTL by what a result cost, so expensive
+// results are kept longer: each USD adds CacheTTLPerUSD times the base TTL.
+// With CacheTTLPerUSD unset every entry keeps the base TTL.
+func (o *Orchestrator) cacheTTL(result *AnalysisResult) time.Duration {
+	base := o.config.CacheTTL
+	if o.config.CacheTTLPerUSD <= 0 || result.CostUSD <= 0 {
+		return base
+	}
+	return base + time.Duration(float64(base)*o.config.CacheTTLPerUSD*result.CostUSD)
 }
 
 // ClearCache removes all cache entries
@@ -0,0 +1,136 @@
+package orchestrator
+
+import (
+	"sort"
+	"time"
+)
+
+// EventType identifies a step of the trampoline
+type EventType string
+
+// Orchestrator events, in the order they typically occur
+const (
+	EventTaskStarted           EventType = "task_started"           // A task is about to be dispatched or served from cache
+	EventCacheHit              EventType = "cache_hit"              // The task was served from the cache or an earlier query
+	EventContinuationRequested EventType = "continuation_requested" // The task asked for a child task
+	EventResultReceived        EventType = "result_received"        // The task produced a result
+	EventCompleted             EventType = "completed"              // The analysis finished
+	EventFailed                EventType = "failed"                 // The analysis failed
+)
+
+// Event describes one step of an analysis. AgentType and Depth refer to the
+// task the event concerns; for EventContinuationRequested that is the
+// requested child.
+type Event struct {
+	Type      EventType `json:"type"`
+	AgentType string    `json:"agent_type,omitempty"`
+	Depth     int       `json:"depth"`
+	ReturnTo  string    `json:"return_to,omitempty"`
+	StackSize int       `json:"stack_size"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Subscribe returns a channel receiving orchestrator events and a function
+// that unsubscribes and closes it. Events are delivered without blocking:
+// when the channel's buffer is full further events are dropped, so a slow
+// subscriber never stalls an analysis.
+func (o *Orchestrator) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+
+	o.subMu.Lock()
+	if o.subscribers == nil {
+		o.subscribers = make(map[chan Event]struct{})
+	}
+	o.subscribers[ch] = struct{}{}
+	o.subMu.Unlock()
+
+	unsubscribe := func() {
+		o.subMu.Lock()
+		defer o.subMu.Unlock()
+		if _, ok := o.subscribers[ch]; ok {
+			delete(o.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// emit delivers an event about task to every subscriber that has room for it
+func (o *Orchestrator) emit(eventType EventType, task *Task, err error) {
+	o.subMu.Lock()
+	defer o.subMu.Unlock()
+	if len(o.subscribers) == 0 {
+		return
+	}
+
+	event := Event{
+		Type:      eventType,
+		StackSize: len(o.stack),
+		Time:      time.Now(),
+	}
+	if task != nil {
+		event.AgentType = task.AgentType
+		event.Depth = task.Depth
+		if task.ReturnTo != nil {
+			event.ReturnTo = *task.ReturnTo
+		}
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	for ch := range o.subscribers {
+		select {
+		case ch <- event:
+		default: // Subscriber is full; drop rather than block
+		}
+	}
+}
+
+// emitResult emits EventResultReceived for task. With Config.DedupResults
+// a result whose cache key was already emitted this run is not emitted
+// again, such as a leaf replayed from the cache when a run resumes from
+// state saved before the leaf completed.
+func (o *Orchestrator) emitResult(task *Task) {
+	if o.config.DedupResults {
+		key := o.cacheKey(task)
+		if o.delivered[key] {
+			o.logger.Debug().Str("agent", task.AgentType).Msg("Result already emitted this run, not emitting again")
+			return
+		}
+		if o.delivered == nil {
+			o.delivered = make(map[string]bool)
+		}
+		o.delivered[key] = true
+	}
+	o.emit(EventResultReceived, task, nil)
+}
+
+// deliveredKeys returns the cache keys of emitted results, sorted, for
+// saving with the state
+func (o *Orchestrator) deliveredKeys() []string {
+	if len(o.delivered) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(o.delivered))
+	for key := range o.delivered {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// restoreDelivered adds the emitted results recorded in saved state to
+// those already emitted by this process, so a resumed run skips both
+func (o *Orchestrator) restoreDelivered(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	if o.delivered == nil {
+		o.delivered = make(map[string]bool, len(keys))
+	}
+	for _, key := range keys {
+		o.delivered[key] = true
+	}
+}
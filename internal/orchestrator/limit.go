@@ -0,0 +1,37 @@
+package orchestrator
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// limitResult enforces Config.MaxResultBytes on a result's content. Oversized
+// content is cut at a UTF-8 boundary and followed by a marker, and the copy
+// returned is flagged Truncated; with RejectOversized it is an error instead.
+func (o *Orchestrator) limitResult(result *AnalysisResult) (*AnalysisResult, error) {
+	limit := o.config.MaxResultBytes
+	if limit <= 0 || len(result.Content) <= limit {
+		return result, nil
+	}
+
+	if o.config.RejectOversized {
+		return nil, fmt.Errorf("%w: %s result is %d bytes, limit %d",
+			ErrResultTooLarge, o.currentTask.AgentType, len(result.Content), limit)
+	}
+
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(result.Content[cut]) {
+		cut--
+	}
+
+	o.logger.Warn().
+		Str("agent", o.currentTask.AgentType).
+		Int("bytes", len(result.Content)).
+		Int("max_bytes", limit).
+		Msg("Truncated oversized result")
+
+	truncated := *result
+	truncated.Content = result.Content[:cut] + fmt.Sprintf("\n\n[truncated: %d of %d bytes shown]", cut, len(result.Content))
+	truncated.Truncated = true
+	return &truncated, nil
+}
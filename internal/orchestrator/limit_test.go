@@ -0,0 +1,64 @@
+package orchestrator_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// oversizedDispatcher returns a result far longer than any sane limit,
+// made of multi-byte runes so a naive cut would split one
+func oversizedDispatcher(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+	return &orchestrator.SubagentResult{
+		Type: orchestrator.ResultTypeAnalysis,
+		Analysis: &orchestrator.AnalysisResult{
+			Type:     "RESULT",
+			Content:  strings.Repeat("é", 10000),
+			Metadata: map[string]interface{}{},
+		},
+	}, nil
+}
+
+func TestMaxResultBytesTruncates(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir()
+	config.MaxResultBytes = 101
+	orch := orchestrator.New(config, zerolog.Nop())
+	orch.SetDispatcher(oversizedDispatcher)
+
+	result, err := orch.AnalyzeDocument(context.Background(), "test.txt", "huge")
+	require.NoError(t, err)
+
+	assert.True(t, result.Truncated)
+	assert.Equal(t, strings.Repeat("é", 50)+"\n\n[truncated: 100 of 20000 bytes shown]", result.Content)
+}
+
+func TestMaxResultBytesRejects(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir()
+	config.MaxResultBytes = 100
+	config.RejectOversized = true
+	orch := orchestrator.New(config, zerolog.Nop())
+	orch.SetDispatcher(oversizedDispatcher)
+
+	_, err := orch.AnalyzeDocument(context.Background(), "test.txt", "huge")
+	assert.ErrorIs(t, err, orchestrator.ErrResultTooLarge)
+}
+
+func TestMaxResultBytesKeepsSmallResults(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir()
+	config.MaxResultBytes = 1 << 20
+	orch := orchestrator.New(config, zerolog.Nop())
+	orch.SetDispatcher(oversizedDispatcher)
+
+	result, err := orch.AnalyzeDocument(context.Background(), "test.txt", "huge")
+	require.NoError(t, err)
+	assert.False(t, result.Truncated)
+	assert.Equal(t, strings.Repeat("é", 10000), result.Content)
+}
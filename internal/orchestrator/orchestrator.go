@@ -5,21 +5,53 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"sync"
 	"time"
 
+	"github.com/kukks/claude-rlm/internal/hash"
 	"github.com/rs/zerolog"
 )
 
 // Config holds orchestrator configuration
 type Config struct {
-	MaxRecursionDepth int
-	MaxIterations     int
-	CacheEnabled      bool
-	CacheTTL          time.Duration
-	WorkDir           string
-	StateFile         string
+	MaxRecursionDepth  int
+	MaxIterations      int
+	MaxChildrenPerTask int // Continuations a single task may request; 0 is unlimited
+	CacheEnabled       bool
+	CacheTTL           time.Duration
+	CacheTTLPerUSD     float64 // Extra CacheTTL per USD a result cost; 0 keeps a flat TTL
+	CacheVersion       string  // Salts cache keys; changing it invalidates every cached result
+	WorkDir            string
+	StateFile          string
+	FailureDumpDir     string            // Directory for failure dumps; empty disables them
+	Assembler          DocumentAssembler // Orders directory files for the Explorer; nil disables
+	BinaryFiles        string            // Binary file policy for assembled files; empty is BinaryInclude
+	Prompts            *PromptTemplates  // Renders Task.Prompt before dispatch; nil disables
+	Processors         []ResultProcessor // Applied in order to the final result
+	Fresh              bool              // Discard saved state instead of resuming it
+	MaxDuration        time.Duration     // Wall-clock budget per analysis; 0 is unlimited
+	WarmStart          WarmStartLoader   // Seeds the root task with a prior analysis; nil disables
+	Aggregator         ResultAggregator  // Summarizes child results before a parent is re-dispatched; nil passes them through
+	DepthSchedule      *DepthSchedule    // Scales depth and iterations with document size; nil disables
+	TraceStore         bool              // Record the task tree on the final result as AnalysisResult.Trace
+	MaxResultBytes     int               // Longest result content accepted from a dispatcher; 0 is unlimited
+	RejectOversized    bool              // Fail on content over MaxResultBytes instead of truncating it
+	Fsync              bool              // Sync state, cache and dump writes to disk before returning
+	DedupResults       bool              // Emit EventResultReceived once per cache key in a run, even across resumes
+	Hashing            hash.Options      // How document files are listed, measured and hashed
+
+	// InitialAgentType is the agent the root task is dispatched to; empty
+	// uses DefaultInitialAgentType. SeedContext is merged into the root
+	// task's context; document_path and query always take precedence.
+	InitialAgentType string
+	SeedContext      map[string]interface{}
 }
 
+// DefaultInitialAgentType is the agent every analysis starts from unless
+// Config.InitialAgentType says otherwise
+const DefaultInitialAgentType = "Explorer"
+
 // DefaultConfig returns default configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -34,23 +66,40 @@ func DefaultConfig() *Config {
 
 // Orchestrator manages the trampoline-based recursion pattern
 type Orchestrator struct {
-	config      *Config
-	logger      zerolog.Logger
-	stack       []Task
-	currentTask Task
-	results     map[string]interface{}
-	stats       Stats
-	dispatcher  SubagentDispatcher
+	config        *Config
+	logger        zerolog.Logger
+	stack         []Task
+	currentTask   Task
+	results       map[string]interface{}
+	childMetadata map[string]map[string]interface{} // Continuation metadata keyed by ReturnTo
+	agentPath     []string                          // Agent types run so far, in order
+	trace         *Trace                            // Task tree so far; nil unless Config.TraceStore
+	delivered     map[string]bool                   // Cache keys of results emitted this run; see Config.DedupResults
+	documentPath  string                            // Document and query of the analysis in progress, saved with its state
+	query         string
+	stats         Stats
+	dispatcher    SubagentDispatcher
+	shared        map[string]*AnalysisResult // Subtask results shared across queries, keyed by cache key
+	subMu         sync.Mutex
+	subscribers   map[chan Event]struct{}
 }
 
 // SubagentDispatcher is a function that dispatches work to a subagent
 // Returns either a ContinuationRequest or AnalysisResult
 type SubagentDispatcher func(ctx context.Context, task *Task) (*SubagentResult, error)
 
+// WarmStartLoader returns hints from a previous analysis of documentPath,
+// such as the files it considered and what changed since, or nil when
+// there is none. The hints seed the root task's "prior_analysis" context.
+type WarmStartLoader func(ctx context.Context, documentPath string) (map[string]interface{}, error)
+
 var (
 	ErrMaxDepthExceeded      = errors.New("maximum recursion depth exceeded")
 	ErrMaxIterationsExceeded = errors.New("maximum iterations exceeded")
+	ErrMaxChildrenExceeded   = errors.New("maximum children per task exceeded")
 	ErrNoDispatcher          = errors.New("no subagent dispatcher configured")
+	ErrTimeBudgetExceeded    = errors.New("analysis time budget exceeded")
+	ErrResultTooLarge        = errors.New("result content exceeds the maximum size")
 )
 
 // New creates a new orchestrator
@@ -60,10 +109,11 @@ func New(config *Config, logger zerolog.Logger) *Orchestrator {
 	}
 
 	return &Orchestrator{
-		config:  config,
-		logger:  logger,
-		stack:   make([]Task, 0),
-		results: make(map[string]interface{}),
+		config:        config,
+		logger:        logger,
+		stack:         make([]Task, 0),
+		results:       make(map[string]interface{}),
+		childMetadata: make(map[string]map[string]interface{}),
 		stats: Stats{
 			StartTime: time.Now(),
 		},
@@ -75,6 +125,44 @@ func (o *Orchestrator) SetDispatcher(dispatcher SubagentDispatcher) {
 	o.dispatcher = dispatcher
 }
 
+// SetAssembler sets the document assembler used for directory analyses.
+// A nil assembler passes directories to the Explorer unassembled.
+func (o *Orchestrator) SetAssembler(assembler DocumentAssembler) {
+	o.config.Assembler = assembler
+}
+
+// Assembler returns the current document assembler
+func (o *Orchestrator) Assembler() DocumentAssembler {
+	return o.config.Assembler
+}
+
+// SetMaxRecursionDepth sets how deep continuations may nest
+func (o *Orchestrator) SetMaxRecursionDepth(depth int) {
+	o.config.MaxRecursionDepth = depth
+}
+
+// MaxRecursionDepth returns the current recursion depth limit
+func (o *Orchestrator) MaxRecursionDepth() int {
+	return o.config.MaxRecursionDepth
+}
+
+// SetWarmStart sets the loader seeding new analyses with a prior analysis
+// of the same path. A nil loader starts every analysis cold.
+func (o *Orchestrator) SetWarmStart(loader WarmStartLoader) {
+	o.config.WarmStart = loader
+}
+
+// HashOptions returns the options document files are hashed with, so
+// analyses and their staleness checks see the same files
+func (o *Orchestrator) HashOptions() hash.Options {
+	return o.config.Hashing
+}
+
+// WarmStart returns the current warm-start loader
+func (o *Orchestrator) WarmStart() WarmStartLoader {
+	return o.config.WarmStart
+}
+
 // Stats returns the current statistics
 func (o *Orchestrator) GetStats() Stats {
 	return o.stats
@@ -91,41 +179,98 @@ func (o *Orchestrator) AnalyzeDocument(ctx context.Context, documentPath, query
 		StartTime: time.Now(),
 	}
 
-	// Try to restore state if exists
-	if o.HasState() {
-		if err := o.LoadState(); err != nil {
-			o.logger.Warn().Err(err).Msg("Failed to restore state, starting fresh")
-		} else {
-			o.logger.Info().Msg("Resumed from previous state")
+	// Try to restore state if exists, unless asked for a clean run. Only
+	// an analysis of the same document and query may resume it.
+	restored := false
+	if o.config.Fresh {
+		if err := o.ClearState(); err != nil {
+			o.logger.Warn().Err(err).Msg("Failed to clear saved state")
+		}
+	} else if state, err := o.readState(); err != nil {
+		o.logger.Warn().Err(err).Msg("Failed to restore state, starting fresh")
+	} else if state != nil && !state.Resumes(documentPath, query) {
+		o.logger.Info().Str("saved_path", state.DocumentPath).Str("saved_query", state.Query).Msg("Saved state belongs to another analysis, starting fresh")
+		if err := o.ClearState(); err != nil {
+			o.logger.Warn().Err(err).Msg("Failed to clear saved state")
 		}
+	} else if state != nil {
+		o.restoreState(state)
+		o.logger.Info().Msg("Resumed from previous state")
+		restored = true
 	}
+	o.documentPath, o.query = documentPath, query
 
 	// Initialize current task if starting fresh
-	if o.currentTask.AgentType == "" {
+	if !restored || o.currentTask.AgentType == "" {
+		o.stack = make([]Task, 0)
+		o.results = make(map[string]interface{})
+		o.childMetadata = make(map[string]map[string]interface{})
+		o.agentPath = nil
+		o.trace = nil
+		o.delivered = nil
+		if o.config.TraceStore {
+			o.trace = &Trace{}
+		}
 		o.currentTask = Task{
-			AgentType:       "Explorer",
+			AgentType:       o.initialAgentType(),
 			TaskDescription: query,
-			Context: map[string]interface{}{
-				"document_path": documentPath,
-				"query":         query,
-			},
-			Depth:        0,
-			ChildResults: make(map[string]interface{}),
+			Context:         o.seedContext(documentPath, query),
+			Depth:           0,
+			ChildResults:    make(map[string]interface{}),
 		}
+
+		// Present a directory's files in the assembler's order
+		if o.config.Assembler != nil {
+			if info, err := os.Stat(documentPath); err == nil && info.IsDir() {
+				files, err := AssembleDocument(o.config.Assembler, documentPath, o.config.Hashing)
+				if err != nil {
+					return nil, fmt.Errorf("document assembly failed: %w", err)
+				}
+				if o.config.BinaryFiles == BinarySkip || o.config.BinaryFiles == BinaryAnnotate {
+					var binary []string
+					files, binary = SplitBinaryFiles(documentPath, files)
+					if len(binary) > 0 && o.config.BinaryFiles == BinaryAnnotate {
+						o.currentTask.Context["binary_files"] = binary
+					}
+				}
+				o.currentTask.Context["files"] = files
+			}
+		}
+
+		// Seed the Explorer with what a previous analysis of this path found
+		if o.config.WarmStart != nil {
+			prior, err := o.config.WarmStart(ctx, documentPath)
+			if err != nil {
+				o.logger.Warn().Err(err).Msg("Failed to load prior analysis, starting cold")
+			} else if prior != nil {
+				o.currentTask.Context["prior_analysis"] = prior
+			}
+		}
+
+		o.traceTask(&o.currentTask, -1)
 	}
 
+	// Size the budget to the document before starting
+	maxDepth, maxIterations := o.budget(documentPath)
+
 	// Trampoline loop
+	started := time.Now()
 	iterations := 0
 	for {
 		iterations++
 
 		// Safety checks (check depth first for better error messages)
-		if o.currentTask.Depth > o.config.MaxRecursionDepth {
-			return nil, ErrMaxDepthExceeded
+		if o.currentTask.Depth > maxDepth {
+			return nil, o.fail(ErrMaxDepthExceeded)
 		}
 
-		if iterations > o.config.MaxIterations {
-			return nil, ErrMaxIterationsExceeded
+		if iterations > maxIterations {
+			return nil, o.fail(ErrMaxIterationsExceeded)
+		}
+
+		// Stop within the time budget, keeping state so a later run resumes
+		if o.config.MaxDuration > 0 && time.Since(started) > o.config.MaxDuration {
+			return o.stopForTimeBudget()
 		}
 
 		// Track max depth
@@ -139,37 +284,78 @@ func (o *Orchestrator) AnalyzeDocument(ctx context.Context, documentPath, query
 			Int("stack_size", len(o.stack)).
 			Msg("Dispatching subagent")
 
+		o.emit(EventTaskStarted, &o.currentTask, nil)
+		o.agentPath = append(o.agentPath, o.currentTask.AgentType)
+
 		var result *SubagentResult
+		cached := false
 
-		// Check cache first
-		if cachedResult := o.CheckCache(&o.currentTask); cachedResult != nil {
+		// Check results shared by earlier queries, then the cache
+		if sharedResult := o.lookupShared(&o.currentTask); sharedResult != nil {
+			o.stats.CacheHits++
+			o.logger.Debug().Msg("Using result shared from a previous query")
+			cached = true
+			result = &SubagentResult{
+				Type:     ResultTypeAnalysis,
+				Analysis: sharedResult,
+			}
+		} else if cachedResult := o.CheckCache(&o.currentTask); cachedResult != nil {
 			o.stats.CacheHits++
 			o.logger.Debug().Msg("Using cached result")
+			cached = true
 			result = &SubagentResult{
 				Type:     ResultTypeAnalysis,
 				Analysis: cachedResult,
 			}
 		} else {
+			task, err := o.dispatchTask()
+			if err != nil {
+				return nil, o.fail(err)
+			}
+
+			// Render the configured prompt for the dispatcher
+			if o.config.Prompts != nil {
+				prompt, err := o.config.Prompts.Render(task)
+				if err != nil {
+					return nil, o.fail(err)
+				}
+				task.Prompt = prompt
+				o.currentTask.Prompt = prompt
+			}
+
 			// Dispatch to subagent
-			var err error
-			result, err = o.dispatcher(ctx, &o.currentTask)
+			result, err = o.dispatcher(ctx, task)
 			if err != nil {
-				return nil, fmt.Errorf("subagent dispatch failed: %w", err)
+				return nil, o.fail(fmt.Errorf("subagent dispatch failed: %w", err))
 			}
 			o.stats.TotalSubagentCalls++
+			o.stats.recordAgent(o.currentTask.AgentType, 1, 0, 0)
+		}
+
+		if cached {
+			o.emit(EventCacheHit, &o.currentTask, nil)
 		}
+		o.traceStep(result, cached)
 
 		// Process result (both cached and dispatched results)
-		if err := o.processResult(ctx, result); err != nil {
-			return nil, err
+		done, err := o.processResult(ctx, result, cached)
+		if err != nil {
+			return nil, o.fail(err)
 		}
 
 		// Check if we're done
-		if len(o.stack) == 0 && result.IsAnalysis() {
+		if done {
 			// Clean up state file on completion
 			o.ClearState()
+			o.delivered = nil
 
-			return result.Analysis, nil
+			final, err := o.postProcess(o.finalizeResult(result.Analysis))
+			if err != nil {
+				return nil, o.fail(err)
+			}
+			final.Trace = o.trace
+			o.emit(EventCompleted, &o.currentTask, nil)
+			return final, nil
 		}
 
 		// Save state after each iteration
@@ -179,8 +365,152 @@ func (o *Orchestrator) AnalyzeDocument(ctx context.Context, documentPath, query
 	}
 }
 
-// processResult handles the result from a subagent dispatch
-func (o *Orchestrator) processResult(ctx context.Context, result *SubagentResult) error {
+// initialAgentType returns the agent type of the root task
+func (o *Orchestrator) initialAgentType() string {
+	if o.config.InitialAgentType != "" {
+		return o.config.InitialAgentType
+	}
+	return DefaultInitialAgentType
+}
+
+// seedContext builds the root task's context: the configured SeedContext
+// plus the document path and query
+func (o *Orchestrator) seedContext(documentPath, query string) map[string]interface{} {
+	seeded := make(map[string]interface{}, len(o.config.SeedContext)+2)
+	for k, v := range o.config.SeedContext {
+		seeded[k] = v
+	}
+	seeded["document_path"] = documentPath
+	seeded["query"] = query
+	return seeded
+}
+
+// fail records a failure dump for a terminal error, notifies subscribers
+// and returns the error unchanged
+func (o *Orchestrator) fail(err error) error {
+	o.emit(EventFailed, &o.currentTask, err)
+
+	dumpFile, dumpErr := o.DumpFailure(err)
+	if dumpErr != nil {
+		o.logger.Warn().Err(dumpErr).Msg("Failed to write failure dump")
+	} else if dumpFile != "" {
+		o.logger.Info().Str("file", dumpFile).Msg("Wrote failure dump")
+	}
+	return err
+}
+
+// stopForTimeBudget saves resumable state and returns the work completed so
+// far alongside ErrTimeBudgetExceeded. Unlike fail it writes no failure
+// dump: running out of time is expected, and the next run picks up here.
+func (o *Orchestrator) stopForTimeBudget() (*AnalysisResult, error) {
+	if err := o.SaveState(); err != nil {
+		o.logger.Warn().Err(err).Msg("Failed to save state")
+	}
+
+	o.logger.Warn().
+		Dur("max_duration", o.config.MaxDuration).
+		Int("stack_size", len(o.stack)).
+		Int("results", len(o.results)).
+		Msg("Time budget exceeded, saved state for resume")
+
+	o.emit(EventFailed, &o.currentTask, ErrTimeBudgetExceeded)
+	return o.partialResult(), ErrTimeBudgetExceeded
+}
+
+// partialResult reports the child results gathered before the analysis
+// stopped early, with the tokens and cost spent on them
+func (o *Orchestrator) partialResult() *AnalysisResult {
+	results := make(map[string]interface{}, len(o.results))
+	for k, v := range o.results {
+		results[k] = v
+	}
+
+	metadata := map[string]interface{}{
+		"partial": true,
+		"results": results,
+	}
+	if len(o.childMetadata) > 0 {
+		metadata["continuations"] = o.childMetadata
+	}
+
+	return &AnalysisResult{
+		Type:       "RESULT",
+		Content:    fmt.Sprintf("Partial analysis: %d of the requested subtasks completed before the time budget ran out", len(results)),
+		Metadata:   metadata,
+		TokenCount: o.stats.TotalTokens,
+		CostUSD:    o.stats.TotalCostUSD,
+		Details:    o.resultMetadata(),
+		Trace:      o.trace,
+	}
+}
+
+// AnalyzeQueries answers several queries about the same document in one run.
+// Exploration work below the root task is shared between queries, so a
+// subtask that an earlier query already completed is not dispatched again.
+// The returned results are in the same order as queries. When a query runs
+// out of time, the results so far are returned with ErrTimeBudgetExceeded,
+// ending with that query's partial result.
+func (o *Orchestrator) AnalyzeQueries(ctx context.Context, documentPath string, queries []string) ([]*AnalysisResult, error) {
+	o.shared = make(map[string]*AnalysisResult)
+	defer func() { o.shared = nil }()
+
+	var total Stats
+	results := make([]*AnalysisResult, 0, len(queries))
+	for i, query := range queries {
+		result, err := o.AnalyzeDocument(ctx, documentPath, query)
+		if err != nil && (result == nil || !errors.Is(err, ErrTimeBudgetExceeded)) {
+			return nil, fmt.Errorf("query %q failed: %w", query, err)
+		}
+		results = append(results, result)
+
+		// Accumulate stats across queries
+		if i == 0 {
+			total.StartTime = o.stats.StartTime
+		}
+		total.TotalSubagentCalls += o.stats.TotalSubagentCalls
+		total.TotalTokens += o.stats.TotalTokens
+		total.TotalCostUSD += o.stats.TotalCostUSD
+		total.CacheHits += o.stats.CacheHits
+		total.CacheSavingsUSD += o.stats.CacheSavingsUSD
+		if o.stats.MaxDepthReached > total.MaxDepthReached {
+			total.MaxDepthReached = o.stats.MaxDepthReached
+		}
+		for agentType, agent := range o.stats.ByAgent {
+			total.recordAgent(agentType, agent.Calls, agent.Tokens, agent.CostUSD)
+		}
+
+		if err != nil {
+			o.stats = total
+			return results, fmt.Errorf("query %q failed: %w", query, err)
+		}
+	}
+
+	o.stats = total
+	return results, nil
+}
+
+// lookupShared returns a subtask result completed by an earlier query
+func (o *Orchestrator) lookupShared(task *Task) *AnalysisResult {
+	if o.shared == nil || task.Depth == 0 {
+		return nil
+	}
+	return o.shared[o.cacheKey(task)]
+}
+
+// storeShared records a subtask result for reuse by later queries.
+// Root tasks are query-specific and never shared.
+func (o *Orchestrator) storeShared(task *Task, result *AnalysisResult) {
+	if o.shared == nil || task.Depth == 0 {
+		return
+	}
+	o.shared[o.cacheKey(task)] = result
+}
+
+// processResult handles the result from a subagent dispatch. cached marks
+// results reused from the cache or an earlier query, whose cost was not
+// spent again. It reports done only for the root task's own result; a child
+// result pops back to the root, which is re-dispatched with its children.
+func (o *Orchestrator) processResult(ctx context.Context, result *SubagentResult, cached bool) (bool, error) {
 	if result.IsContinuation() {
 		// CONTINUATION: Push current task to stack, create new task
 		o.logger.Debug().
@@ -188,11 +518,19 @@ func (o *Orchestrator) processResult(ctx context.Context, result *SubagentResult
 			Str("return_to", result.Continuation.ReturnTo).
 			Msg("Continuation requested")
 
+		// Bound fan-out from a single task
+		if o.config.MaxChildrenPerTask > 0 && o.currentTask.ChildrenSpawned >= o.config.MaxChildrenPerTask {
+			return false, fmt.Errorf("%w: %s task at depth %d already spawned %d",
+				ErrMaxChildrenExceeded, o.currentTask.AgentType, o.currentTask.Depth, o.currentTask.ChildrenSpawned)
+		}
+		o.currentTask.ChildrenSpawned++
+
 		// Push current task onto stack
 		o.stack = append(o.stack, o.currentTask)
 
 		// Create new task from continuation
 		returnTo := result.Continuation.ReturnTo
+		parent := o.currentTask.TraceNode
 		o.currentTask = Task{
 			AgentType:       result.Continuation.AgentType,
 			TaskDescription: result.Continuation.Task,
@@ -200,26 +538,50 @@ func (o *Orchestrator) processResult(ctx context.Context, result *SubagentResult
 			Depth:           o.currentTask.Depth + 1,
 			ReturnTo:        &returnTo,
 			ChildResults:    make(map[string]interface{}),
+			Metadata:        result.Continuation.Metadata,
 		}
+		o.traceTask(&o.currentTask, parent)
 
-		return nil // Continue trampolining
+		o.emit(EventContinuationRequested, &o.currentTask, nil)
+
+		// Record continuation hints so they survive into the final result
+		if len(result.Continuation.Metadata) > 0 {
+			o.childMetadata[returnTo] = result.Continuation.Metadata
+		}
+
+		return false, nil // Continue trampolining
 	}
 
 	if result.IsAnalysis() {
+		// Bound untrusted dispatcher output before it is cached or stored
+		limited, err := o.limitResult(result.Analysis)
+		if err != nil {
+			return false, err
+		}
+		result.Analysis = limited
+
 		// RESULT: Store result and pop stack
 		o.logger.Debug().
 			Int("token_count", result.Analysis.TokenCount).
 			Float64("cost_usd", result.Analysis.CostUSD).
 			Msg("Analysis result received")
 
-		// Update stats
-		o.stats.TotalTokens += result.Analysis.TokenCount
-		o.stats.TotalCostUSD += result.Analysis.CostUSD
+		o.emitResult(&o.currentTask)
+
+		// Update stats; a reused result saves what it originally cost
+		if cached {
+			o.stats.CacheSavingsUSD += result.Analysis.CostUSD
+		} else {
+			o.stats.TotalTokens += result.Analysis.TokenCount
+			o.stats.TotalCostUSD += result.Analysis.CostUSD
+			o.stats.recordAgent(o.currentTask.AgentType, 0, result.Analysis.TokenCount, result.Analysis.CostUSD)
+		}
 
 		// Store result in cache
 		if err := o.StoreCache(&o.currentTask, result.Analysis); err != nil {
 			o.logger.Warn().Err(err).Msg("Failed to cache result")
 		}
+		o.storeShared(&o.currentTask, result.Analysis)
 
 		// If stack is not empty, inject result into parent and continue
 		if len(o.stack) > 0 {
@@ -234,10 +596,7 @@ func (o *Orchestrator) processResult(ctx context.Context, result *SubagentResult
 
 			// Inject child results into parent
 			if o.currentTask.ReturnTo != nil {
-				if parentTask.ChildResults == nil {
-					parentTask.ChildResults = make(map[string]interface{})
-				}
-				parentTask.ChildResults[*o.currentTask.ReturnTo] = result.Analysis
+				parentTask.addChildResult(*o.currentTask.ReturnTo, result.Analysis)
 			}
 
 			o.currentTask = parentTask
@@ -247,14 +606,78 @@ func (o *Orchestrator) processResult(ctx context.Context, result *SubagentResult
 				Int("stack_size", len(o.stack)).
 				Msg("Popped stack, continuing with parent task")
 
-			return nil // Continue trampolining
+			return false, nil // Continue trampolining
 		}
 
 		// Stack is empty and we have a result - we're done
-		return nil
+		return true, nil
+	}
+
+	return false, fmt.Errorf("unknown result type")
+}
+
+// finalizeResult attaches run-level metadata to the root result
+func (o *Orchestrator) finalizeResult(result *AnalysisResult) *AnalysisResult {
+	finalized := *result
+	finalized.Details = o.resultMetadata()
+	if len(o.childMetadata) == 0 {
+		return &finalized
+	}
+
+	// Copy so we never mutate a map owned by the dispatcher or task context
+	metadata := make(map[string]interface{}, len(result.Metadata)+1)
+	for k, v := range result.Metadata {
+		metadata[k] = v
+	}
+	metadata["continuations"] = o.childMetadata
+
+	finalized.Metadata = metadata
+	return &finalized
+}
+
+// resultMetadata describes the current run. The root task is always at the
+// bottom of the stack, or current when the stack is empty.
+func (o *Orchestrator) resultMetadata() *ResultMetadata {
+	root := o.currentTask
+	if len(o.stack) > 0 {
+		root = o.stack[0]
 	}
 
-	return fmt.Errorf("unknown result type")
+	documentPath, _ := root.Context["document_path"].(string)
+	query, _ := root.Context["query"].(string)
+
+	return &ResultMetadata{
+		DocumentPath:    documentPath,
+		Query:           query,
+		FilesConsidered: filesConsidered(documentPath, root.Context["files"]),
+		MaxDepthReached: o.stats.MaxDepthReached,
+		AgentPath:       append([]string(nil), o.agentPath...),
+		StartedAt:       o.stats.StartTime,
+		CompletedAt:     time.Now(),
+	}
+}
+
+// filesConsidered lists the assembled files given to the Explorer, or the
+// document itself when a single file was analyzed. Assembled files restored
+// from saved state decode as []interface{}.
+func filesConsidered(documentPath string, assembled interface{}) []string {
+	switch files := assembled.(type) {
+	case []string:
+		return append([]string(nil), files...)
+	case []interface{}:
+		paths := make([]string, 0, len(files))
+		for _, file := range files {
+			if path, ok := file.(string); ok {
+				paths = append(paths, path)
+			}
+		}
+		return paths
+	}
+
+	if info, err := os.Stat(documentPath); err == nil && !info.IsDir() {
+		return []string{documentPath}
+	}
+	return nil
 }
 
 // PlaceholderDispatcher is a placeholder for the actual subagent dispatcher
@@ -272,15 +695,21 @@ func PlaceholderDispatcher(ctx context.Context, task *Task) (*SubagentResult, er
 		Analysis: &AnalysisResult{
 			Type:       "RESULT",
 			Content:    fmt.Sprintf("Analysis of '%s' at depth %d", task.TaskDescription, task.Depth),
-			Metadata:   task.Context,
+			Metadata:   map[string]interface{}{},
 			TokenCount: 1000,
 			CostUSD:    0.003,
 		},
 	}, nil
 }
 
-// ParseSubagentResponse parses JSON response from subagent
+// ParseSubagentResponse parses JSON response from subagent, tolerating
+// markdown fences and surrounding prose (see ResponseParser)
 func ParseSubagentResponse(data []byte) (*SubagentResult, error) {
+	return (&ResponseParser{}).Parse(data)
+}
+
+// parseSubagentJSON parses a subagent's JSON response object
+func parseSubagentJSON(data []byte) (*SubagentResult, error) {
 	// Try to determine type by checking for "type" field
 	var typeCheck struct {
 		Type string `json:"type"`
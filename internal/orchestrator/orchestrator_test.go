@@ -2,6 +2,11 @@ package orchestrator_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -56,6 +61,58 @@ func TestTrampolinePattern(t *testing.T) {
 	assert.Equal(t, 100, stats.TotalTokens)
 }
 
+func TestCompletionReturnsRootResult(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir() // Use temp directory for cache/state
+	logger := zerolog.Nop()
+
+	orch := orchestrator.New(config, logger)
+
+	rootCalls := 0
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		if task.Depth == 0 {
+			rootCalls++
+			if len(task.ChildResults) == 0 {
+				return &orchestrator.SubagentResult{
+					Type: orchestrator.ResultTypeContinuation,
+					Continuation: &orchestrator.ContinuationRequest{
+						Type:      "CONTINUATION",
+						AgentType: "Worker",
+						Task:      "inspect parser",
+						Context:   map[string]interface{}{},
+						ReturnTo:  "parser",
+					},
+				}, nil
+			}
+			return &orchestrator.SubagentResult{
+				Type: orchestrator.ResultTypeAnalysis,
+				Analysis: &orchestrator.AnalysisResult{
+					Type:     "RESULT",
+					Content:  "root synthesis",
+					Metadata: map[string]interface{}{},
+				},
+			}, nil
+		}
+
+		return &orchestrator.SubagentResult{
+			Type: orchestrator.ResultTypeAnalysis,
+			Analysis: &orchestrator.AnalysisResult{
+				Type:     "RESULT",
+				Content:  "child finding",
+				Metadata: map[string]interface{}{},
+			},
+		}, nil
+	})
+
+	result, err := orch.AnalyzeDocument(context.Background(), "test.txt", "completion test")
+	require.NoError(t, err)
+
+	// The child popping back to the root must not end the run
+	assert.Equal(t, "root synthesis", result.Content)
+	assert.Equal(t, 2, rootCalls)
+	assert.Equal(t, 3, orch.GetStats().TotalSubagentCalls)
+}
+
 func TestCaching(t *testing.T) {
 	config := orchestrator.DefaultConfig()
 	config.WorkDir = t.TempDir() // Use temp directory for cache/state
@@ -98,6 +155,196 @@ func TestCaching(t *testing.T) {
 	assert.Equal(t, 1, stats.CacheHits)
 }
 
+func TestCacheVersion(t *testing.T) {
+	task := &orchestrator.Task{AgentType: "Worker", TaskDescription: "inspect", Context: map[string]interface{}{"file": "a.go"}}
+
+	// No version keeps the keys of entries cached before versions existed
+	assert.Equal(t, orchestrator.GenerateCacheKey(task), orchestrator.GenerateVersionedCacheKey(task, ""))
+	assert.NotEqual(t, orchestrator.GenerateVersionedCacheKey(task, "v1"), orchestrator.GenerateVersionedCacheKey(task, "v2"))
+	assert.NotEqual(t, orchestrator.GenerateCacheKey(task), orchestrator.GenerateVersionedCacheKey(task, "v1"))
+
+	workDir := t.TempDir()
+	callCount := 0
+	analyze := func(version string) {
+		config := orchestrator.DefaultConfig()
+		config.WorkDir = workDir
+		config.CacheVersion = version
+		orch := orchestrator.New(config, zerolog.Nop())
+		orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+			callCount++
+			return orchestrator.PlaceholderDispatcher(ctx, task)
+		})
+		_, err := orch.AnalyzeDocument(context.Background(), "test.txt", "versioned")
+		require.NoError(t, err)
+	}
+
+	analyze("v1")
+	analyze("v1")
+	assert.Equal(t, 1, callCount, "same version reuses the cache")
+
+	analyze("v2")
+	assert.Equal(t, 2, callCount, "a new version misses the cache")
+}
+
+func TestCostAwareCacheTTL(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir() // Use temp directory for cache/state
+	config.CacheTTL = time.Hour
+	config.CacheTTLPerUSD = 10
+	logger := zerolog.Nop()
+
+	orch := orchestrator.New(config, logger)
+
+	storedTTL := func(description string, costUSD float64) time.Duration {
+		task := &orchestrator.Task{AgentType: "Worker", TaskDescription: description, Context: map[string]interface{}{}}
+		require.NoError(t, orch.StoreCache(task, &orchestrator.AnalysisResult{Type: "RESULT", Content: description, CostUSD: costUSD}))
+
+		data, err := os.ReadFile(filepath.Join(config.WorkDir, orchestrator.CacheDir, orchestrator.GenerateCacheKey(task)+".json"))
+		require.NoError(t, err)
+		var entry orchestrator.CacheEntry
+		require.NoError(t, json.Unmarshal(data, &entry))
+		return entry.TTL
+	}
+
+	free := storedTTL("free", 0)
+	cheap := storedTTL("cheap", 0.001)
+	costly := storedTTL("costly", 2.00)
+
+	assert.Equal(t, time.Hour, free)
+	assert.Greater(t, costly, cheap)
+	assert.Equal(t, 21*time.Hour, costly) // 1h base + 2 USD * 10 * 1h
+}
+
+func TestCostBreakdownCacheSavings(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir() // Use temp directory for cache/state
+	config.CacheTTL = 1 * time.Hour
+	orch := orchestrator.New(config, zerolog.Nop())
+
+	// Each query's root spawns the same worker, so the second query's worker
+	// is served from the cache
+	const rootCost, workerCost = 0.002, 0.005
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		if task.Depth == 0 && len(task.ChildResults) == 0 {
+			return &orchestrator.SubagentResult{
+				Type: orchestrator.ResultTypeContinuation,
+				Continuation: &orchestrator.ContinuationRequest{
+					Type:      "CONTINUATION",
+					AgentType: "Worker",
+					Task:      "map structure",
+					Context:   map[string]interface{}{},
+					ReturnTo:  "structure",
+				},
+			}, nil
+		}
+		cost := rootCost
+		if task.Depth > 0 {
+			cost = workerCost
+		}
+		return &orchestrator.SubagentResult{
+			Type: orchestrator.ResultTypeAnalysis,
+			Analysis: &orchestrator.AnalysisResult{
+				Type:     "RESULT",
+				Content:  "done",
+				Metadata: map[string]interface{}{},
+				CostUSD:  cost,
+			},
+		}, nil
+	})
+
+	ctx := context.Background()
+	_, err := orch.AnalyzeDocument(ctx, "test.txt", "first question")
+	require.NoError(t, err)
+	first := orch.GetStats().CostBreakdown()
+	assert.Equal(t, 0, first.CacheHits)
+	assert.Zero(t, first.CacheSavingsUSD)
+	assert.InDelta(t, rootCost+workerCost, first.TotalUSD, 1e-9)
+
+	_, err = orch.AnalyzeDocument(ctx, "test.txt", "second question")
+	require.NoError(t, err)
+	second := orch.GetStats().CostBreakdown()
+	assert.Equal(t, 1, second.CacheHits)
+	assert.InDelta(t, workerCost, second.CacheSavingsUSD, 1e-9)
+	// Only the root was paid for; the worker's cost was avoided
+	assert.InDelta(t, rootCost, second.TotalUSD, 1e-9)
+	assert.InDelta(t, rootCost, second.ByAgentUSD["Explorer"], 1e-9)
+	assert.NotContains(t, second.ByAgentUSD, "Worker")
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir() // Use temp directory for cache/state
+	config.CacheEnabled = false
+	orch := orchestrator.New(config, zerolog.Nop())
+
+	// Root spawns one worker and completes once it returns
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		if task.Depth == 0 && len(task.ChildResults) == 0 {
+			return &orchestrator.SubagentResult{
+				Type: orchestrator.ResultTypeContinuation,
+				Continuation: &orchestrator.ContinuationRequest{
+					Type:      "CONTINUATION",
+					AgentType: "Worker",
+					Task:      "inspect auth",
+					Context:   map[string]interface{}{},
+					ReturnTo:  "auth",
+				},
+			}, nil
+		}
+		return orchestrator.PlaceholderDispatcher(ctx, task)
+	})
+
+	events, unsubscribe := orch.Subscribe(32)
+	// A subscriber that never reads must not stall the analysis
+	_, stalled := orch.Subscribe(0)
+	defer stalled()
+
+	_, err := orch.AnalyzeDocument(context.Background(), "test.txt", "events")
+	require.NoError(t, err)
+	unsubscribe()
+
+	type step struct {
+		Type      orchestrator.EventType
+		AgentType string
+		Depth     int
+	}
+	var got []step
+	for event := range events {
+		got = append(got, step{event.Type, event.AgentType, event.Depth})
+	}
+
+	assert.Equal(t, []step{
+		{orchestrator.EventTaskStarted, "Explorer", 0},
+		{orchestrator.EventContinuationRequested, "Worker", 1},
+		{orchestrator.EventTaskStarted, "Worker", 1},
+		{orchestrator.EventResultReceived, "Worker", 1},
+		{orchestrator.EventTaskStarted, "Explorer", 0},
+		{orchestrator.EventResultReceived, "Explorer", 0},
+		{orchestrator.EventCompleted, "Explorer", 0},
+	}, got)
+}
+
+func TestSubscribeFailedEvent(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir() // Use temp directory for cache/state
+	orch := orchestrator.New(config, zerolog.Nop())
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		return nil, errors.New("subagent crashed")
+	})
+
+	events, unsubscribe := orch.Subscribe(8)
+	_, err := orch.AnalyzeDocument(context.Background(), "test.txt", "events")
+	require.Error(t, err)
+	unsubscribe()
+
+	var last orchestrator.Event
+	for event := range events {
+		last = event
+	}
+	assert.Equal(t, orchestrator.EventFailed, last.Type)
+	assert.Contains(t, last.Error, "subagent crashed")
+}
+
 func TestMaxDepthLimit(t *testing.T) {
 	config := orchestrator.DefaultConfig()
 	config.WorkDir = t.TempDir() // Use temp directory for cache/state
@@ -127,3 +374,559 @@ func TestMaxDepthLimit(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, orchestrator.ErrMaxDepthExceeded, err)
 }
+
+func TestMaxChildrenPerTask(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir() // Use temp directory for cache/state
+	config.CacheEnabled = false  // Every child must be dispatched
+	config.MaxChildrenPerTask = 3
+	logger := zerolog.Nop()
+
+	orch := orchestrator.New(config, logger)
+
+	// The root keeps spawning another child; children complete immediately
+	spawned := 0
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		if task.Depth == 0 {
+			spawned++
+			return &orchestrator.SubagentResult{
+				Type: orchestrator.ResultTypeContinuation,
+				Continuation: &orchestrator.ContinuationRequest{
+					Type:      "CONTINUATION",
+					AgentType: "Worker",
+					Task:      fmt.Sprintf("chunk %d", spawned),
+					Context:   map[string]interface{}{},
+					ReturnTo:  fmt.Sprintf("chunk-%d", spawned),
+				},
+			}, nil
+		}
+		return &orchestrator.SubagentResult{
+			Type: orchestrator.ResultTypeAnalysis,
+			Analysis: &orchestrator.AnalysisResult{
+				Type:     "RESULT",
+				Content:  "done",
+				Metadata: map[string]interface{}{},
+			},
+		}, nil
+	})
+
+	_, err := orch.AnalyzeDocument(context.Background(), "test.txt", "fan out")
+	assert.ErrorIs(t, err, orchestrator.ErrMaxChildrenExceeded)
+
+	// The fourth request is refused; only three children ran
+	assert.Equal(t, 4, spawned)
+	assert.Equal(t, 3, orch.GetStats().ByAgent["Worker"].Calls)
+}
+
+func TestContinuationMetadataPropagation(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir() // Use temp directory for cache/state
+	logger := zerolog.Nop()
+
+	orch := orchestrator.New(config, logger)
+
+	var childMetadata map[string]interface{}
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		// Root task spawns one worker, then completes once the child returns
+		if task.Depth == 0 && len(task.ChildResults) == 0 {
+			return &orchestrator.SubagentResult{
+				Type: orchestrator.ResultTypeContinuation,
+				Continuation: &orchestrator.ContinuationRequest{
+					Type:      "CONTINUATION",
+					AgentType: "Worker",
+					Task:      "inspect auth module",
+					Context:   map[string]interface{}{},
+					ReturnTo:  "auth",
+					Metadata:  map[string]interface{}{"priority": "high"},
+				},
+			}, nil
+		}
+
+		if task.Depth == 1 {
+			childMetadata = task.Metadata
+		}
+
+		return &orchestrator.SubagentResult{
+			Type: orchestrator.ResultTypeAnalysis,
+			Analysis: &orchestrator.AnalysisResult{
+				Type:     "RESULT",
+				Content:  "done",
+				Metadata: map[string]interface{}{},
+			},
+		}, nil
+	})
+
+	ctx := context.Background()
+	result, err := orch.AnalyzeDocument(ctx, "test.txt", "metadata test")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"priority": "high"}, childMetadata)
+
+	continuations, ok := result.Metadata["continuations"].(map[string]map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "high", continuations["auth"]["priority"])
+}
+
+func TestChildResultOrdering(t *testing.T) {
+	// The root spawns children in non-alphabetical order, one at a time
+	spawn := []string{"parser", "auth", "storage"}
+
+	run := func() ([]string, string) {
+		config := orchestrator.DefaultConfig()
+		config.WorkDir = t.TempDir() // Use temp directory for cache/state
+		orch := orchestrator.New(config, zerolog.Nop())
+
+		var order []string
+		var serialized string
+		orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+			if task.Depth == 0 && len(task.ChildResults) < len(spawn) {
+				next := spawn[len(task.ChildResults)]
+				return &orchestrator.SubagentResult{
+					Type: orchestrator.ResultTypeContinuation,
+					Continuation: &orchestrator.ContinuationRequest{
+						Type:      "CONTINUATION",
+						AgentType: "Worker",
+						Task:      "inspect " + next,
+						Context:   map[string]interface{}{},
+						ReturnTo:  next,
+					},
+				}, nil
+			}
+
+			if task.Depth == 0 {
+				for _, child := range task.OrderedChildResults() {
+					order = append(order, child.ReturnTo)
+				}
+				data, err := json.Marshal(task)
+				require.NoError(t, err)
+				serialized = string(data)
+			}
+
+			return &orchestrator.SubagentResult{
+				Type: orchestrator.ResultTypeAnalysis,
+				Analysis: &orchestrator.AnalysisResult{
+					Type:     "RESULT",
+					Content:  "summary of " + task.TaskDescription,
+					Metadata: map[string]interface{}{},
+				},
+			}, nil
+		})
+
+		_, err := orch.AnalyzeDocument(context.Background(), "test.txt", "ordering test")
+		require.NoError(t, err)
+		return order, serialized
+	}
+
+	firstOrder, firstJSON := run()
+	assert.Equal(t, spawn, firstOrder)
+
+	for i := 0; i < 5; i++ {
+		order, serialized := run()
+		assert.Equal(t, firstOrder, order)
+		assert.Equal(t, firstJSON, serialized)
+	}
+}
+
+func TestAnalyzeQueriesSharesExploration(t *testing.T) {
+	// Root tasks spawn the same structural exploration regardless of query
+	newDispatcher := func(calls *int) orchestrator.SubagentDispatcher {
+		return func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+			*calls++
+			if task.Depth == 0 && len(task.ChildResults) == 0 {
+				return &orchestrator.SubagentResult{
+					Type: orchestrator.ResultTypeContinuation,
+					Continuation: &orchestrator.ContinuationRequest{
+						Type:      "CONTINUATION",
+						AgentType: "Worker",
+						Task:      "map document structure",
+						Context:   map[string]interface{}{"document_path": "test.txt"},
+						ReturnTo:  "structure",
+					},
+				}, nil
+			}
+			return &orchestrator.SubagentResult{
+				Type: orchestrator.ResultTypeAnalysis,
+				Analysis: &orchestrator.AnalysisResult{
+					Type:       "RESULT",
+					Content:    "answer to " + task.TaskDescription,
+					Metadata:   map[string]interface{}{},
+					TokenCount: 100,
+				},
+			}, nil
+		}
+	}
+
+	newOrchestrator := func(calls *int) *orchestrator.Orchestrator {
+		config := orchestrator.DefaultConfig()
+		config.WorkDir = t.TempDir() // Use temp directory for cache/state
+		config.CacheEnabled = false  // Isolate sharing from the on-disk cache
+		orch := orchestrator.New(config, zerolog.Nop())
+		orch.SetDispatcher(newDispatcher(calls))
+		return orch
+	}
+
+	ctx := context.Background()
+	queries := []string{"what does it do?", "where is auth handled?"}
+
+	separateCalls := 0
+	separate := newOrchestrator(&separateCalls)
+	for _, q := range queries {
+		_, err := separate.AnalyzeDocument(ctx, "test.txt", q)
+		require.NoError(t, err)
+	}
+
+	sharedCalls := 0
+	shared := newOrchestrator(&sharedCalls)
+	results, err := shared.AnalyzeQueries(ctx, "test.txt", queries)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "answer to what does it do?", results[0].Content)
+	assert.Equal(t, "answer to where is auth handled?", results[1].Content)
+
+	assert.Less(t, sharedCalls, separateCalls)
+	assert.Equal(t, sharedCalls, shared.GetStats().TotalSubagentCalls)
+}
+
+func TestStatsByAgent(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir() // Use temp directory for cache/state
+	logger := zerolog.Nop()
+
+	orch := orchestrator.New(config, logger)
+
+	// Explorer spawns two workers, one at a time, then summarizes
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		if task.AgentType == "Explorer" && len(task.ChildResults) < 2 {
+			part := fmt.Sprintf("part-%d", len(task.ChildResults))
+			return &orchestrator.SubagentResult{
+				Type: orchestrator.ResultTypeContinuation,
+				Continuation: &orchestrator.ContinuationRequest{
+					Type:      "CONTINUATION",
+					AgentType: "Worker",
+					Task:      "analyze " + part,
+					Context:   map[string]interface{}{"part": part},
+					ReturnTo:  part,
+				},
+			}, nil
+		}
+
+		tokens := 300
+		if task.AgentType == "Worker" {
+			tokens = 100
+		}
+		return &orchestrator.SubagentResult{
+			Type: orchestrator.ResultTypeAnalysis,
+			Analysis: &orchestrator.AnalysisResult{
+				Type:       "RESULT",
+				Content:    "done",
+				Metadata:   map[string]interface{}{},
+				TokenCount: tokens,
+				CostUSD:    float64(tokens) / 100000,
+			},
+		}, nil
+	})
+
+	_, err := orch.AnalyzeDocument(context.Background(), "test.txt", "per-agent stats")
+	require.NoError(t, err)
+
+	stats := orch.GetStats()
+	require.Contains(t, stats.ByAgent, "Explorer")
+	require.Contains(t, stats.ByAgent, "Worker")
+	assert.Equal(t, 3, stats.ByAgent["Explorer"].Calls)
+	assert.Equal(t, 2, stats.ByAgent["Worker"].Calls)
+	assert.Equal(t, 200, stats.ByAgent["Worker"].Tokens)
+
+	calls, tokens, cost := 0, 0, 0.0
+	for _, agent := range stats.ByAgent {
+		calls += agent.Calls
+		tokens += agent.Tokens
+		cost += agent.CostUSD
+	}
+	assert.Equal(t, stats.TotalSubagentCalls, calls)
+	assert.Equal(t, stats.TotalTokens, tokens)
+	assert.InDelta(t, stats.TotalCostUSD, cost, 1e-9)
+}
+
+func TestFailureDump(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir() // Use temp directory for cache/state
+	config.FailureDumpDir = filepath.Join(config.WorkDir, ".rlm")
+	config.MaxIterations = 3
+	logger := zerolog.Nop()
+
+	orch := orchestrator.New(config, logger)
+
+	// Dispatcher that keeps requesting continuations
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		return &orchestrator.SubagentResult{
+			Type: orchestrator.ResultTypeContinuation,
+			Continuation: &orchestrator.ContinuationRequest{
+				Type:      "CONTINUATION",
+				AgentType: "Worker",
+				Task:      "deeper analysis",
+				Context:   map[string]interface{}{},
+				ReturnTo:  "test",
+			},
+		}, nil
+	})
+
+	_, err := orch.AnalyzeDocument(context.Background(), "test.txt", "test query")
+	assert.Equal(t, orchestrator.ErrMaxIterationsExceeded, err)
+
+	dumps, err := filepath.Glob(filepath.Join(config.FailureDumpDir, "failure_*.json"))
+	require.NoError(t, err)
+	require.Len(t, dumps, 1)
+
+	data, err := os.ReadFile(dumps[0])
+	require.NoError(t, err)
+
+	var dump orchestrator.FailureDump
+	require.NoError(t, json.Unmarshal(data, &dump))
+	assert.Equal(t, orchestrator.ErrMaxIterationsExceeded.Error(), dump.Error)
+	assert.Len(t, dump.Stack, 3)
+	assert.Equal(t, "Explorer", dump.Stack[0].AgentType)
+	assert.Equal(t, "Worker", dump.CurrentTask.AgentType)
+}
+
+func TestMaxDurationSavesState(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir() // Use temp directory for cache/state
+	config.CacheEnabled = false
+	config.MaxDuration = 50 * time.Millisecond
+	logger := zerolog.Nop()
+
+	orch := orchestrator.New(config, logger)
+
+	// A slow dispatcher that keeps recursing would never finish on its own
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		time.Sleep(20 * time.Millisecond)
+		return &orchestrator.SubagentResult{
+			Type: orchestrator.ResultTypeContinuation,
+			Continuation: &orchestrator.ContinuationRequest{
+				Type:      "CONTINUATION",
+				AgentType: "Worker",
+				Task:      "deeper analysis",
+				Context:   map[string]interface{}{},
+				ReturnTo:  fmt.Sprintf("depth-%d", task.Depth),
+			},
+		}, nil
+	})
+
+	start := time.Now()
+	result, err := orch.AnalyzeDocument(context.Background(), "test.txt", "test query")
+	assert.ErrorIs(t, err, orchestrator.ErrTimeBudgetExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// Partial result and stats cover the work done before stopping
+	require.NotNil(t, result)
+	assert.Equal(t, true, result.Metadata["partial"])
+	assert.Greater(t, orch.GetStats().TotalSubagentCalls, 0)
+
+	// State was kept for a later run to resume
+	assert.True(t, orch.HasState())
+	data, err := os.ReadFile(filepath.Join(config.WorkDir, orchestrator.StateFileName))
+	require.NoError(t, err)
+
+	var state orchestrator.State
+	require.NoError(t, json.Unmarshal(data, &state))
+	assert.NotEmpty(t, state.Stack)
+	assert.Equal(t, "Worker", state.CurrentTask.AgentType)
+}
+
+func TestSavedStateResumesOnlyTheSameAnalysis(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir() // Use temp directory for cache/state
+	config.CacheEnabled = false
+	logger := zerolog.Nop()
+
+	orch := orchestrator.New(config, logger)
+
+	// Leave a Worker's task behind for a.txt
+	stopEarly := func() {
+		config.MaxDuration = 30 * time.Millisecond
+		defer func() { config.MaxDuration = 0 }()
+		orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+			time.Sleep(20 * time.Millisecond)
+			return &orchestrator.SubagentResult{
+				Type: orchestrator.ResultTypeContinuation,
+				Continuation: &orchestrator.ContinuationRequest{
+					Type:      "CONTINUATION",
+					AgentType: "Worker",
+					Task:      "deeper analysis",
+					Context:   map[string]interface{}{},
+					ReturnTo:  fmt.Sprintf("depth-%d", task.Depth),
+				},
+			}, nil
+		})
+		_, err := orch.AnalyzeDocument(context.Background(), "a.txt", "q")
+		require.ErrorIs(t, err, orchestrator.ErrTimeBudgetExceeded)
+		require.True(t, orch.HasState())
+	}
+
+	// firstAgent runs an analysis and returns the agent dispatched first
+	firstAgent := func(documentPath, query string) string {
+		var agents []string
+		orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+			agents = append(agents, task.AgentType)
+			return orchestrator.PlaceholderDispatcher(ctx, task)
+		})
+		_, err := orch.AnalyzeDocument(context.Background(), documentPath, query)
+		require.NoError(t, err)
+		require.NotEmpty(t, agents)
+		return agents[0]
+	}
+
+	// Another document or query starts over and discards the state
+	stopEarly()
+	assert.Equal(t, "Explorer", firstAgent("b.txt", "q"))
+	assert.False(t, orch.HasState())
+
+	stopEarly()
+	assert.Equal(t, "Explorer", firstAgent("a.txt", "other"))
+	assert.False(t, orch.HasState())
+
+	// The same analysis picks up where it stopped
+	stopEarly()
+	assert.Equal(t, "Worker", firstAgent("a.txt", "q"))
+}
+
+func TestResultMetadata(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir() // Use temp directory for cache/state
+	logger := zerolog.Nop()
+
+	doc := filepath.Join(t.TempDir(), "doc.txt")
+	require.NoError(t, os.WriteFile(doc, []byte("hello"), 0644))
+
+	orch := orchestrator.New(config, logger)
+
+	// The root delegates once to a Worker, then answers
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		if task.Depth == 0 && len(task.ChildResults) == 0 {
+			return &orchestrator.SubagentResult{
+				Type: orchestrator.ResultTypeContinuation,
+				Continuation: &orchestrator.ContinuationRequest{
+					Type:      "CONTINUATION",
+					AgentType: "Worker",
+					Task:      "read the file",
+					Context:   map[string]interface{}{},
+					ReturnTo:  "worker",
+				},
+			}, nil
+		}
+		return orchestrator.PlaceholderDispatcher(ctx, task)
+	})
+
+	before := time.Now()
+	result, err := orch.AnalyzeDocument(context.Background(), doc, "what is this?")
+	require.NoError(t, err)
+
+	// Structured details replace the raw task context echo
+	assert.NotContains(t, result.Metadata, "document_path")
+	require.NotNil(t, result.Details)
+	assert.Equal(t, doc, result.Details.DocumentPath)
+	assert.Equal(t, "what is this?", result.Details.Query)
+	assert.Equal(t, []string{doc}, result.Details.FilesConsidered)
+	assert.Equal(t, 1, result.Details.MaxDepthReached)
+	assert.Equal(t, []string{"Explorer", "Worker", "Explorer"}, result.Details.AgentPath)
+	assert.False(t, result.Details.StartedAt.Before(before))
+	assert.False(t, result.Details.CompletedAt.Before(result.Details.StartedAt))
+}
+
+func TestInitialAgentTypeAndSeedContext(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir()
+	config.InitialAgentType = "Planner"
+	config.SeedContext = map[string]interface{}{"team": "payments", "query": "overridden"}
+	orch := orchestrator.New(config, zerolog.Nop())
+
+	var first *orchestrator.Task
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		if first == nil {
+			copied := *task
+			first = &copied
+		}
+		return orchestrator.PlaceholderDispatcher(ctx, task)
+	})
+
+	_, err := orch.AnalyzeDocument(context.Background(), "test.txt", "plan the audit")
+	require.NoError(t, err)
+
+	require.NotNil(t, first)
+	assert.Equal(t, "Planner", first.AgentType)
+	assert.Equal(t, "payments", first.Context["team"])
+	assert.Equal(t, "plan the audit", first.Context["query"])
+	assert.Equal(t, "test.txt", first.Context["document_path"])
+
+	// The configured seed is copied, never written to
+	assert.Equal(t, map[string]interface{}{"team": "payments", "query": "overridden"}, config.SeedContext)
+}
+
+func TestDedupResultsAcrossResume(t *testing.T) {
+	for _, dedup := range []bool{true, false} {
+		t.Run(fmt.Sprintf("dedup=%v", dedup), func(t *testing.T) {
+			workDir := t.TempDir()
+			config := orchestrator.DefaultConfig()
+			config.WorkDir = workDir
+			config.DedupResults = dedup
+			orch := orchestrator.New(config, zerolog.Nop())
+
+			// Root spawns one worker; the first run fails once the worker
+			// returns, after saving the state from before the worker ran
+			stateFile := filepath.Join(workDir, orchestrator.StateFileName)
+			var beforeWorker []byte
+			failRoot := true
+			orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+				if task.AgentType == "Worker" {
+					data, err := os.ReadFile(stateFile)
+					require.NoError(t, err)
+					beforeWorker = data
+				} else if len(task.ChildResults) == 0 {
+					return &orchestrator.SubagentResult{
+						Type: orchestrator.ResultTypeContinuation,
+						Continuation: &orchestrator.ContinuationRequest{
+							Type:      "CONTINUATION",
+							AgentType: "Worker",
+							Task:      "inspect auth",
+							Context:   map[string]interface{}{},
+							ReturnTo:  "auth",
+						},
+					}, nil
+				} else if failRoot {
+					return nil, errors.New("interrupted")
+				}
+				return orchestrator.PlaceholderDispatcher(ctx, task)
+			})
+
+			events, unsubscribe := orch.Subscribe(32)
+
+			_, err := orch.AnalyzeDocument(context.Background(), "test.txt", "resume")
+			require.Error(t, err)
+
+			// Resume as if the run stopped before saving the worker's result,
+			// so the worker is replayed from the cache
+			require.NoError(t, os.WriteFile(stateFile, beforeWorker, 0644))
+			failRoot = false
+			_, err = orch.AnalyzeDocument(context.Background(), "test.txt", "resume")
+			require.NoError(t, err)
+			unsubscribe()
+
+			var workerResults, cacheHits int
+			for event := range events {
+				switch {
+				case event.Type == orchestrator.EventResultReceived && event.AgentType == "Worker":
+					workerResults++
+				case event.Type == orchestrator.EventCacheHit:
+					cacheHits++
+				}
+			}
+
+			assert.Equal(t, 1, cacheHits)
+			if dedup {
+				assert.Equal(t, 1, workerResults)
+			} else {
+				assert.Equal(t, 2, workerResults)
+			}
+		})
+	}
+}
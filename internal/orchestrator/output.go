@@ -0,0 +1,39 @@
+package orchestrator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteOutputFile writes analysis content to path, creating parent
+// directories and replacing any previous content
+func WriteOutputFile(path, content string) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
+
+// FormatQueryResults renders the results of several queries as one document
+// with a heading per query
+func FormatQueryResults(queries []string, results []*AnalysisResult) string {
+	var sb strings.Builder
+	for i, result := range results {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "## %s\n\n%s\n", queries[i], strings.TrimSpace(result.Content))
+	}
+	return sb.String()
+}
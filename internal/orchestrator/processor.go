@@ -0,0 +1,104 @@
+package orchestrator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Built-in result processors
+const (
+	ProcessorRedactSecrets  = "redact_secrets"
+	ProcessorTrimWhitespace = "trim_whitespace"
+)
+
+// ResultProcessor post-processes the final result of an analysis before it
+// is stored or returned. Processors run in order on a copy of the result.
+type ResultProcessor interface {
+	Process(result *AnalysisResult) error
+}
+
+// ResultProcessorFunc adapts a function to a ResultProcessor
+type ResultProcessorFunc func(result *AnalysisResult) error
+
+// Process implements ResultProcessor
+func (f ResultProcessorFunc) Process(result *AnalysisResult) error {
+	return f(result)
+}
+
+// NewResultProcessors returns the built-in processors for the given names,
+// in order
+func NewResultProcessors(names []string) ([]ResultProcessor, error) {
+	processors := make([]ResultProcessor, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case ProcessorRedactSecrets:
+			processors = append(processors, SecretRedactor{})
+		case ProcessorTrimWhitespace:
+			processors = append(processors, WhitespaceTrimmer{})
+		default:
+			return nil, fmt.Errorf("unknown result processor %q (expected %s or %s)",
+				name, ProcessorRedactSecrets, ProcessorTrimWhitespace)
+		}
+	}
+	return processors, nil
+}
+
+// RedactedSecret replaces secrets masked by SecretRedactor
+const RedactedSecret = "[REDACTED]"
+
+// secretPatterns match well-known credential formats in their entirety
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),                                   // AWS access key ID
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),                         // GitHub token
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),                                // OpenAI/Anthropic style API key
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),                         // Slack token
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`), // JWT
+}
+
+// secretAssignment matches credential-looking assignments such as
+// api_key = "..." and keeps the name while masking the value
+var secretAssignment = regexp.MustCompile(`(?i)\b((?:api[_-]?key|secret|token|password|passwd)["']?\s*[:=]\s*["']?)([^\s"']{8,})`)
+
+// SecretRedactor masks credentials in the result content
+type SecretRedactor struct{}
+
+// Process implements ResultProcessor
+func (SecretRedactor) Process(result *AnalysisResult) error {
+	content := result.Content
+	for _, pattern := range secretPatterns {
+		content = pattern.ReplaceAllString(content, RedactedSecret)
+	}
+	result.Content = secretAssignment.ReplaceAllString(content, "${1}"+RedactedSecret)
+	return nil
+}
+
+// WhitespaceTrimmer strips trailing whitespace from each line of the result
+// content and surrounding blank lines from the whole
+type WhitespaceTrimmer struct{}
+
+// Process implements ResultProcessor
+func (WhitespaceTrimmer) Process(result *AnalysisResult) error {
+	lines := strings.Split(result.Content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	result.Content = strings.TrimSpace(strings.Join(lines, "\n"))
+	return nil
+}
+
+// postProcess runs the configured processors on a copy of the final result
+func (o *Orchestrator) postProcess(result *AnalysisResult) (*AnalysisResult, error) {
+	if len(o.config.Processors) == 0 {
+		return result, nil
+	}
+
+	processed := *result
+	for _, processor := range o.config.Processors {
+		if err := processor.Process(&processed); err != nil {
+			return nil, fmt.Errorf("result processing failed: %w", err)
+		}
+	}
+	return &processed, nil
+}
@@ -0,0 +1,80 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/template"
+)
+
+// DefaultPromptKey names the template used for agent types without one
+const DefaultPromptKey = "default"
+
+// PromptTemplates renders the prompt handed to a subagent from its Task.
+// Templates are Go text/template sources keyed by agent type and executed
+// with the *Task as data, e.g. "Explore {{.Context.document_path}} to
+// answer: {{.TaskDescription}}". A "json" function renders values as
+// indented JSON, which is handy for ChildResults; range over
+// .OrderedChildResults to list children in a stable order.
+type PromptTemplates struct {
+	templates map[string]*template.Template
+}
+
+var promptFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		return string(data), err
+	},
+}
+
+// NewPromptTemplates parses and validates prompt templates keyed by agent
+// type. Each template is also rendered against a sample task so references
+// to unknown Task fields fail here rather than mid-analysis.
+func NewPromptTemplates(sources map[string]string) (*PromptTemplates, error) {
+	agentTypes := make([]string, 0, len(sources))
+	for agentType := range sources {
+		agentTypes = append(agentTypes, agentType)
+	}
+	sort.Strings(agentTypes)
+
+	sample := &Task{
+		AgentType:       "Explorer",
+		TaskDescription: "sample task",
+		Context:         map[string]interface{}{"document_path": ".", "query": "sample task"},
+		ChildResults:    map[string]interface{}{},
+		Metadata:        map[string]interface{}{},
+	}
+
+	p := &PromptTemplates{templates: make(map[string]*template.Template, len(sources))}
+	for _, agentType := range agentTypes {
+		tmpl, err := template.New(agentType).Funcs(promptFuncs).Parse(sources[agentType])
+		if err != nil {
+			return nil, fmt.Errorf("invalid prompt template for %s: %w", agentType, err)
+		}
+		if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+			return nil, fmt.Errorf("invalid prompt template for %s: %w", agentType, err)
+		}
+		p.templates[agentType] = tmpl
+	}
+
+	return p, nil
+}
+
+// Render renders the prompt for task using its agent type's template, or
+// the default template. It returns an empty prompt when neither exists.
+func (p *PromptTemplates) Render(task *Task) (string, error) {
+	tmpl, ok := p.templates[task.AgentType]
+	if !ok {
+		tmpl, ok = p.templates[DefaultPromptKey]
+	}
+	if !ok {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, task); err != nil {
+		return "", fmt.Errorf("failed to render prompt for %s: %w", task.AgentType, err)
+	}
+	return buf.String(), nil
+}
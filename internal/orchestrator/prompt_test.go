@@ -0,0 +1,78 @@
+package orchestrator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptTemplatesRender(t *testing.T) {
+	prompts, err := orchestrator.NewPromptTemplates(map[string]string{
+		"Explorer": "[{{.AgentType}} depth {{.Depth}}] Explore {{.Context.document_path}} to answer: {{.TaskDescription}}",
+		"default":  "Do: {{.TaskDescription}}\nChild results:\n{{json .ChildResults}}",
+	})
+	require.NoError(t, err)
+
+	explorer, err := prompts.Render(&orchestrator.Task{
+		AgentType:       "Explorer",
+		TaskDescription: "How is auth wired?",
+		Context:         map[string]interface{}{"document_path": "src/auth"},
+		Depth:           1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "[Explorer depth 1] Explore src/auth to answer: How is auth wired?", explorer)
+
+	// Agent types without a template use the default
+	worker, err := prompts.Render(&orchestrator.Task{
+		AgentType:       "Worker",
+		TaskDescription: "summarize",
+		ChildResults:    map[string]interface{}{"a": "done"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Do: summarize\nChild results:\n{\n  \"a\": \"done\"\n}", worker)
+}
+
+func TestPromptTemplatesValidation(t *testing.T) {
+	_, err := orchestrator.NewPromptTemplates(map[string]string{"Explorer": "Explore {{.TaskDescription"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid prompt template for Explorer")
+
+	// Unknown fields are caught at load time, not mid-analysis
+	_, err = orchestrator.NewPromptTemplates(map[string]string{"Worker": "{{.Query}}"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid prompt template for Worker")
+	assert.Contains(t, err.Error(), "Query")
+
+	// Without a matching or default template the prompt is empty
+	prompts, err := orchestrator.NewPromptTemplates(map[string]string{"Explorer": "x"})
+	require.NoError(t, err)
+	prompt, err := prompts.Render(&orchestrator.Task{AgentType: "Worker"})
+	require.NoError(t, err)
+	assert.Empty(t, prompt)
+}
+
+func TestPromptRenderedBeforeDispatch(t *testing.T) {
+	prompts, err := orchestrator.NewPromptTemplates(map[string]string{
+		"default": "{{.AgentType}}: {{.TaskDescription}}",
+	})
+	require.NoError(t, err)
+
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir()
+	config.Prompts = prompts
+	orch := orchestrator.New(config, zerolog.Nop())
+
+	var prompt string
+	orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+		prompt = task.Prompt
+		return orchestrator.PlaceholderDispatcher(ctx, task)
+	})
+
+	_, err = orch.AnalyzeDocument(context.Background(), "doc.txt", "find bugs")
+	require.NoError(t, err)
+	assert.Equal(t, "Explorer: find bugs", prompt)
+}
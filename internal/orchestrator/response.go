@@ -0,0 +1,122 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ResponseParser turns a subagent's raw output into a SubagentResult.
+// Models often wrap the JSON object in markdown fences or explain it in
+// prose; by default the parser extracts the object from such output.
+type ResponseParser struct {
+	// Strict requires the output to be exactly one JSON object, rejecting
+	// fenced or prose-wrapped responses
+	Strict bool
+}
+
+// Parse extracts the response object from data and parses it by its
+// top-level type (CONTINUATION or RESULT)
+func (p *ResponseParser) Parse(data []byte) (*SubagentResult, error) {
+	if p.Strict {
+		return parseSubagentJSON(data)
+	}
+
+	object := extractJSONObject(data)
+	if object == nil {
+		return nil, fmt.Errorf("no JSON object in subagent response")
+	}
+	return parseSubagentJSON(object)
+}
+
+// extractJSONObject returns the first balanced, valid JSON object in data,
+// looking inside ``` fences before the rest of the text. Returns nil when
+// there is none.
+func extractJSONObject(data []byte) []byte {
+	data = bytes.TrimSpace(data)
+	if json.Valid(data) && bytes.HasPrefix(data, []byte("{")) {
+		return data
+	}
+
+	for _, block := range fencedBlocks(data) {
+		if object := firstJSONObject(block); object != nil {
+			return object
+		}
+	}
+	return firstJSONObject(data)
+}
+
+// fencedBlocks returns the contents of the ``` fenced blocks in data, without
+// the fence lines and their language tags
+func fencedBlocks(data []byte) [][]byte {
+	fence := []byte("```")
+	var blocks [][]byte
+	for {
+		start := bytes.Index(data, fence)
+		if start < 0 {
+			return blocks
+		}
+		rest := data[start+len(fence):]
+
+		// Skip the language tag, e.g. ```json
+		if newline := bytes.IndexByte(rest, '\n'); newline >= 0 {
+			rest = rest[newline+1:]
+		}
+
+		end := bytes.Index(rest, fence)
+		if end < 0 {
+			return append(blocks, rest)
+		}
+		blocks = append(blocks, rest[:end])
+		data = rest[end+len(fence):]
+	}
+}
+
+// firstJSONObject returns the first balanced {...} span in data that is
+// valid JSON, skipping braces that only look like objects (e.g. in prose)
+func firstJSONObject(data []byte) []byte {
+	for start := bytes.IndexByte(data, '{'); start >= 0; {
+		if end := balancedEnd(data[start:]); end > 0 {
+			if candidate := data[start : start+end]; json.Valid(candidate) {
+				return candidate
+			}
+		}
+
+		next := bytes.IndexByte(data[start+1:], '{')
+		if next < 0 {
+			return nil
+		}
+		start += next + 1
+	}
+	return nil
+}
+
+// balancedEnd returns the length of the brace-balanced span at the start of
+// data, ignoring braces inside JSON strings, or 0 when it never closes
+func balancedEnd(data []byte) int {
+	depth := 0
+	inString, escaped := false, false
+	for i, c := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package orchestrator_test
+
+import (
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubagentResponse(t *testing.T) {
+	result := `{"type": "RESULT", "content": "Uses {braces} in \"quotes\"", "metadata": {}, "token_count": 10}`
+
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{"bare", result},
+		{"fenced", "```json\n" + result + "\n```"},
+		{"preamble", "Here is my analysis of the {module}:\n\n" + result + "\n\nLet me know if you need more."},
+		{"fenced after prose", "Done. The result {as requested}:\n```\n" + result + "\n```\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := orchestrator.ParseSubagentResponse([]byte(tt.output))
+			require.NoError(t, err)
+			require.Equal(t, orchestrator.ResultTypeAnalysis, parsed.Type)
+			assert.Equal(t, `Uses {braces} in "quotes"`, parsed.Analysis.Content)
+		})
+	}
+}
+
+func TestParseSubagentResponseContinuation(t *testing.T) {
+	output := "I need to look deeper.\n```json\n" +
+		`{"type": "CONTINUATION", "agent_type": "Worker", "task": "inspect auth", "return_to": "root"}` +
+		"\n```"
+
+	parsed, err := orchestrator.ParseSubagentResponse([]byte(output))
+	require.NoError(t, err)
+	require.Equal(t, orchestrator.ResultTypeContinuation, parsed.Type)
+	assert.Equal(t, "Worker", parsed.Continuation.AgentType)
+}
+
+func TestParseSubagentResponseRejects(t *testing.T) {
+	_, err := orchestrator.ParseSubagentResponse([]byte("I could not finish the analysis."))
+	assert.Error(t, err)
+
+	_, err = orchestrator.ParseSubagentResponse([]byte(`{"type": "SUMMARY"}`))
+	assert.Error(t, err)
+}
+
+func TestResponseParserStrict(t *testing.T) {
+	strict := &orchestrator.ResponseParser{Strict: true}
+	result := `{"type": "RESULT", "content": "done"}`
+
+	parsed, err := strict.Parse([]byte(result))
+	require.NoError(t, err)
+	assert.Equal(t, "done", parsed.Analysis.Content)
+
+	_, err = strict.Parse([]byte("```json\n" + result + "\n```"))
+	assert.Error(t, err)
+	_, err = strict.Parse([]byte("Here you go: " + result))
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package orchestrator
+
+import (
+	"math"
+	"os"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+)
+
+// DefaultScheduleFullScale is the document size, in files, at which a
+// DepthSchedule grants the configured maximum depth and iterations
+const DefaultScheduleFullScale = 10000
+
+// scheduleBytesPerFile converts bytes to file equivalents, so a few very
+// large files count like many small ones
+const scheduleBytesPerFile = 20 * 1024
+
+// DepthSchedule scales an analysis' recursion depth and iteration budget
+// with the size of its document, between the schedule's minimums and the
+// configured MaxRecursionDepth and MaxIterations. Small documents finish
+// shallow; large ones may use the full budget.
+type DepthSchedule struct {
+	MinDepth      int
+	MinIterations int
+	FullScale     int // Files at which the maximums apply; 0 uses DefaultScheduleFullScale
+}
+
+// Budget returns the depth and iteration limits for a document of the
+// given size. The limits grow with the logarithm of the size, from the
+// minimums for a single file to maxDepth and maxIterations at FullScale
+// files or more. Minimums above the maximums are capped at them.
+func (s *DepthSchedule) Budget(size hash.TreeSize, maxDepth, maxIterations int) (depth, iterations int) {
+	fullScale := s.FullScale
+	if fullScale <= 1 {
+		fullScale = DefaultScheduleFullScale
+	}
+
+	scale := float64(size.Files)
+	if byBytes := float64(size.Bytes) / scheduleBytesPerFile; byBytes > scale {
+		scale = byBytes
+	}
+
+	fraction := 0.0
+	if scale > 1 {
+		fraction = math.Min(math.Log(scale)/math.Log(float64(fullScale)), 1)
+	}
+
+	return interpolate(s.MinDepth, maxDepth, fraction), interpolate(s.MinIterations, maxIterations, fraction)
+}
+
+// interpolate returns the value fraction of the way from lo to hi,
+// capping lo at hi
+func interpolate(lo, hi int, fraction float64) int {
+	if lo > hi {
+		lo = hi
+	}
+	return lo + int(math.Round(fraction*float64(hi-lo)))
+}
+
+// budget returns the depth and iteration limits for analyzing documentPath:
+// the configured maximums, or the schedule's budget for its measured size
+func (o *Orchestrator) budget(documentPath string) (depth, iterations int) {
+	depth, iterations = o.config.MaxRecursionDepth, o.config.MaxIterations
+	if o.config.DepthSchedule == nil {
+		return depth, iterations
+	}
+
+	size, err := hash.NewFileHasher(o.config.Hashing).MeasureTree(documentPath)
+	if err != nil {
+		o.logger.Warn().Err(err).Msg("Failed to measure document, using the full depth budget")
+		return depth, iterations
+	}
+
+	// A single file the hasher's patterns don't cover still has a size
+	if size.Files == 0 {
+		if info, err := os.Stat(documentPath); err == nil && !info.IsDir() {
+			size.Files, size.Bytes = 1, info.Size()
+		}
+	}
+
+	depth, iterations = o.config.DepthSchedule.Budget(*size, depth, iterations)
+	o.logger.Info().
+		Int("files", size.Files).
+		Int64("bytes", size.Bytes).
+		Int("max_depth", depth).
+		Int("max_iterations", iterations).
+		Msg("Scheduled analysis budget")
+	return depth, iterations
+}
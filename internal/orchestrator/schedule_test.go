@@ -0,0 +1,97 @@
+package orchestrator_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepthScheduleBudget(t *testing.T) {
+	schedule := &orchestrator.DepthSchedule{MinDepth: 2, MinIterations: 50, FullScale: 10000}
+
+	// A single file gets the minimums, full scale the maximums
+	depth, iterations := schedule.Budget(hash.TreeSize{Files: 1}, 10, 1000)
+	assert.Equal(t, 2, depth)
+	assert.Equal(t, 50, iterations)
+
+	depth, iterations = schedule.Budget(hash.TreeSize{Files: 10000}, 10, 1000)
+	assert.Equal(t, 10, depth)
+	assert.Equal(t, 1000, iterations)
+
+	// Budgets grow with size in between and never pass the maximums
+	prevDepth, prevIterations := 0, 0
+	for _, files := range []int{1, 10, 100, 1000, 10000, 1000000} {
+		depth, iterations := schedule.Budget(hash.TreeSize{Files: files}, 10, 1000)
+		assert.GreaterOrEqual(t, depth, prevDepth, "%d files", files)
+		assert.GreaterOrEqual(t, iterations, prevIterations, "%d files", files)
+		assert.LessOrEqual(t, depth, 10)
+		assert.LessOrEqual(t, iterations, 1000)
+		prevDepth, prevIterations = depth, iterations
+	}
+	depth, _ = schedule.Budget(hash.TreeSize{Files: 100}, 10, 1000)
+	assert.Equal(t, 6, depth)
+
+	// A few large files count like many small ones
+	depth, _ = schedule.Budget(hash.TreeSize{Files: 2, Bytes: 200 * 1024 * 1024}, 10, 1000)
+	assert.Equal(t, 10, depth)
+
+	// Minimums above the maximums are capped
+	depth, iterations = schedule.Budget(hash.TreeSize{Files: 1}, 1, 20)
+	assert.Equal(t, 1, depth)
+	assert.Equal(t, 20, iterations)
+}
+
+func TestDepthScheduleLimitsSmallDocuments(t *testing.T) {
+	small := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(small, "main.go"), []byte("package main"), 0644))
+
+	large := t.TempDir()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(large, fmt.Sprintf("file%d.go", i)), []byte("package main"), 0644))
+	}
+
+	// Each analysis continues until depth 4, then answers
+	analyze := func(path string) (int, error) {
+		config := orchestrator.DefaultConfig()
+		config.WorkDir = t.TempDir()
+		config.CacheEnabled = false
+		config.MaxRecursionDepth = 10
+		config.DepthSchedule = &orchestrator.DepthSchedule{MinDepth: 2, MinIterations: 50, FullScale: 1000}
+
+		orch := orchestrator.New(config, zerolog.Nop())
+		orch.SetDispatcher(func(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+			if task.Depth < 4 && len(task.ChildResults) == 0 {
+				return &orchestrator.SubagentResult{
+					Type: orchestrator.ResultTypeContinuation,
+					Continuation: &orchestrator.ContinuationRequest{
+						Type:      "CONTINUATION",
+						AgentType: "Worker",
+						Task:      "deeper",
+						ReturnTo:  fmt.Sprintf("depth%d", task.Depth),
+					},
+				}, nil
+			}
+			return orchestrator.PlaceholderDispatcher(ctx, task)
+		})
+
+		_, err := orch.AnalyzeDocument(context.Background(), path, "q")
+		return orch.GetStats().MaxDepthReached, err
+	}
+
+	// One file is held to the minimum depth
+	_, err := analyze(small)
+	assert.ErrorIs(t, err, orchestrator.ErrMaxDepthExceeded)
+
+	// A hundred files may go deeper
+	reached, err := analyze(large)
+	require.NoError(t, err)
+	assert.Equal(t, 4, reached)
+}
@@ -2,9 +2,13 @@ package orchestrator
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/kukks/claude-rlm/internal/fsutil"
+	"github.com/kukks/claude-rlm/internal/hash"
 )
 
 const (
@@ -14,11 +18,17 @@ const (
 // SaveState persists the orchestrator state to disk
 func (o *Orchestrator) SaveState() error {
 	state := State{
-		Stack:       o.stack,
-		CurrentTask: o.currentTask,
-		Results:     o.results,
-		Stats:       o.stats,
-		Timestamp:   time.Now(),
+		DocumentPath:  o.documentPath,
+		Query:         o.query,
+		Stack:         o.stack,
+		CurrentTask:   o.currentTask,
+		Results:       o.results,
+		ChildMetadata: o.childMetadata,
+		AgentPath:     o.agentPath,
+		Trace:         o.trace,
+		Delivered:     o.deliveredKeys(),
+		Stats:         o.stats,
+		Timestamp:     time.Now(),
 	}
 
 	data, err := json.MarshalIndent(state, "", "  ")
@@ -27,37 +37,68 @@ func (o *Orchestrator) SaveState() error {
 	}
 
 	stateFile := filepath.Join(o.config.WorkDir, StateFileName)
-	return os.WriteFile(stateFile, data, 0644)
+	return fsutil.WriteFile(stateFile, data, 0644, o.config.Fsync)
 }
 
 // LoadState restores the orchestrator state from disk
 func (o *Orchestrator) LoadState() error {
+	state, err := o.readState()
+	if err != nil || state == nil {
+		return err
+	}
+	o.restoreState(state)
+	return nil
+}
+
+// readState reads the state file, returning nil if there is none
+func (o *Orchestrator) readState() (*State, error) {
 	stateFile := filepath.Join(o.config.WorkDir, StateFileName)
 
 	data, err := os.ReadFile(stateFile)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil // No state file is fine (fresh start)
+			return nil, nil // No state file is fine (fresh start)
 		}
-		return err
+		return nil, err
 	}
 
 	var state State
 	if err := json.Unmarshal(data, &state); err != nil {
-		return err
+		return nil, err
 	}
+	return &state, nil
+}
 
+// restoreState makes state the orchestrator's current state
+func (o *Orchestrator) restoreState(state *State) {
+	o.documentPath = state.DocumentPath
+	o.query = state.Query
 	o.stack = state.Stack
 	o.currentTask = state.CurrentTask
 	o.results = state.Results
+	o.childMetadata = state.ChildMetadata
+	o.agentPath = state.AgentPath
+	o.trace = state.Trace
 	o.stats = state.Stats
+	o.restoreDelivered(state.Delivered)
+
+	if o.results == nil {
+		o.results = make(map[string]interface{})
+	}
+	if o.childMetadata == nil {
+		o.childMetadata = make(map[string]map[string]interface{})
+	}
 
 	o.logger.Info().
 		Int("stack_depth", len(o.stack)).
 		Int("results", len(o.results)).
 		Msg("Restored state from disk")
+}
 
-	return nil
+// Resumes reports whether the state was saved by an analysis of
+// documentPath for query, and so may be resumed by one
+func (s *State) Resumes(documentPath, query string) bool {
+	return s.Query == query && s.DocumentPath != "" && hash.SamePath(s.DocumentPath, documentPath)
 }
 
 // ClearState removes the state file
@@ -76,3 +117,34 @@ func (o *Orchestrator) HasState() bool {
 	_, err := os.Stat(stateFile)
 	return err == nil
 }
+
+// DumpFailure writes the current stack, task and partial results to a
+// failure_<timestamp>.json file in FailureDumpDir so a failed recursion can
+// be inspected. It returns the path written, or "" when dumps are disabled.
+func (o *Orchestrator) DumpFailure(cause error) (string, error) {
+	if o.config.FailureDumpDir == "" {
+		return "", nil
+	}
+
+	now := time.Now()
+	dump := FailureDump{
+		Error:       cause.Error(),
+		Stack:       o.stack,
+		CurrentTask: o.currentTask,
+		Results:     o.results,
+		Stats:       o.stats,
+		Timestamp:   now,
+	}
+
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(o.config.FailureDumpDir, 0755); err != nil {
+		return "", err
+	}
+
+	dumpFile := filepath.Join(o.config.FailureDumpDir, fmt.Sprintf("failure_%s.json", now.Format("20060102-150405.000000")))
+	return dumpFile, fsutil.WriteFile(dumpFile, data, 0644, o.config.Fsync)
+}
@@ -1,6 +1,9 @@
 package orchestrator
 
-import "time"
+import (
+	"sort"
+	"time"
+)
 
 // Task represents work to be performed by a subagent
 type Task struct {
@@ -10,6 +13,56 @@ type Task struct {
 	Depth           int                    `json:"depth"`
 	ReturnTo        *string                `json:"return_to,omitempty"`
 	ChildResults    map[string]interface{} `json:"child_results,omitempty"`
+	ChildOrder      []string               `json:"child_order,omitempty"`      // ChildResults keys in completion order
+	ChildrenSpawned int                    `json:"children_spawned,omitempty"` // Continuations requested by this task
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`         // Hints from the spawning continuation
+	Prompt          string                 `json:"prompt,omitempty"`           // Rendered from the configured prompt templates
+	TraceNode       int                    `json:"trace_node,omitempty"`       // This task's node in the run's Trace
+}
+
+// ChildResult is one child's result as seen by its parent task
+type ChildResult struct {
+	ReturnTo string      `json:"return_to"`
+	Result   interface{} `json:"result"`
+}
+
+// addChildResult records a child's result, keeping the position of the
+// first completion when the same ReturnTo completes again
+func (t *Task) addChildResult(returnTo string, result interface{}) {
+	if t.ChildResults == nil {
+		t.ChildResults = make(map[string]interface{})
+	}
+	if _, exists := t.ChildResults[returnTo]; !exists {
+		t.ChildOrder = append(t.ChildOrder, returnTo)
+	}
+	t.ChildResults[returnTo] = result
+}
+
+// OrderedChildResults returns the child results in the order the children
+// completed. Results missing from ChildOrder, e.g. from state saved by an
+// older version, follow in ReturnTo order.
+func (t *Task) OrderedChildResults() []ChildResult {
+	ordered := make([]ChildResult, 0, len(t.ChildResults))
+	seen := make(map[string]bool, len(t.ChildOrder))
+	for _, returnTo := range t.ChildOrder {
+		if result, ok := t.ChildResults[returnTo]; ok && !seen[returnTo] {
+			ordered = append(ordered, ChildResult{ReturnTo: returnTo, Result: result})
+			seen[returnTo] = true
+		}
+	}
+
+	rest := make([]string, 0)
+	for returnTo := range t.ChildResults {
+		if !seen[returnTo] {
+			rest = append(rest, returnTo)
+		}
+	}
+	sort.Strings(rest)
+	for _, returnTo := range rest {
+		ordered = append(ordered, ChildResult{ReturnTo: returnTo, Result: t.ChildResults[returnTo]})
+	}
+
+	return ordered
 }
 
 // ContinuationRequest signals that recursion is needed
@@ -29,20 +82,95 @@ type AnalysisResult struct {
 	Metadata   map[string]interface{} `json:"metadata"`
 	TokenCount int                    `json:"token_count"`
 	CostUSD    float64                `json:"cost_usd"`
+	Details    *ResultMetadata        `json:"details,omitempty"`   // Set by the orchestrator on the final result
+	Trace      *Trace                 `json:"-"`                   // Set on the final result when Config.TraceStore is enabled
+	Truncated  bool                   `json:"truncated,omitempty"` // Content was cut to Config.MaxResultBytes
+}
+
+// ResultMetadata describes how the orchestrator produced a final result,
+// so a stored analysis is self-describing
+type ResultMetadata struct {
+	DocumentPath    string    `json:"document_path"`
+	Query           string    `json:"query"`
+	FilesConsidered []string  `json:"files_considered,omitempty"`
+	MaxDepthReached int       `json:"max_depth_reached"`
+	AgentPath       []string  `json:"agent_path"` // Agent types in the order they ran
+	StartedAt       time.Time `json:"started_at"`
+	CompletedAt     time.Time `json:"completed_at"`
 }
 
 // Stats tracks analysis metrics
 type Stats struct {
-	TotalSubagentCalls int       `json:"total_subagent_calls"`
-	TotalTokens        int       `json:"total_tokens"`
-	TotalCostUSD       float64   `json:"total_cost_usd"`
-	MaxDepthReached    int       `json:"max_depth_reached"`
-	CacheHits          int       `json:"cache_hits"`
-	StartTime          time.Time `json:"start_time"`
+	TotalSubagentCalls int                   `json:"total_subagent_calls"`
+	TotalTokens        int                   `json:"total_tokens"`
+	TotalCostUSD       float64               `json:"total_cost_usd"`
+	MaxDepthReached    int                   `json:"max_depth_reached"`
+	CacheHits          int                   `json:"cache_hits"`
+	CacheSavingsUSD    float64               `json:"cache_savings_usd"` // Original cost of results reused instead of dispatched
+	StartTime          time.Time             `json:"start_time"`
+	ByAgent            map[string]AgentStats `json:"by_agent,omitempty"`
+}
+
+// AgentStats tracks usage for a single agent type
+type AgentStats struct {
+	Calls   int     `json:"calls"`
+	Tokens  int     `json:"tokens"`
+	CostUSD float64 `json:"cost_usd"`
+}
+
+// recordAgent adds usage to the breakdown for an agent type
+func (s *Stats) recordAgent(agentType string, calls, tokens int, costUSD float64) {
+	if s.ByAgent == nil {
+		s.ByAgent = make(map[string]AgentStats)
+	}
+	agent := s.ByAgent[agentType]
+	agent.Calls += calls
+	agent.Tokens += tokens
+	agent.CostUSD += costUSD
+	s.ByAgent[agentType] = agent
+}
+
+// CostBreakdown itemizes what an analysis cost and what caching saved
+type CostBreakdown struct {
+	TotalUSD        float64            `json:"total_usd"`
+	ByAgentUSD      map[string]float64 `json:"by_agent_usd"`
+	CacheHits       int                `json:"cache_hits"`
+	CacheSavingsUSD float64            `json:"cache_savings_usd"`
+}
+
+// CostBreakdown itemizes the stats' spend by agent type alongside the cost
+// avoided by cache hits
+func (s Stats) CostBreakdown() CostBreakdown {
+	breakdown := CostBreakdown{
+		TotalUSD:        s.TotalCostUSD,
+		ByAgentUSD:      make(map[string]float64, len(s.ByAgent)),
+		CacheHits:       s.CacheHits,
+		CacheSavingsUSD: s.CacheSavingsUSD,
+	}
+	for agentType, agent := range s.ByAgent {
+		breakdown.ByAgentUSD[agentType] = agent.CostUSD
+	}
+	return breakdown
 }
 
 // State represents the orchestrator state for persistence
 type State struct {
+	DocumentPath  string                            `json:"document_path,omitempty"` // What the saved analysis is of
+	Query         string                            `json:"query,omitempty"`
+	Stack         []Task                            `json:"stack"`
+	CurrentTask   Task                              `json:"current_task"`
+	Results       map[string]interface{}            `json:"results"`
+	ChildMetadata map[string]map[string]interface{} `json:"child_metadata,omitempty"`
+	AgentPath     []string                          `json:"agent_path,omitempty"`
+	Trace         *Trace                            `json:"trace,omitempty"`
+	Delivered     []string                          `json:"delivered,omitempty"` // Cache keys of results already emitted
+	Stats         Stats                             `json:"stats"`
+	Timestamp     time.Time                         `json:"timestamp"`
+}
+
+// FailureDump captures the trampoline at the moment an analysis failed
+type FailureDump struct {
+	Error       string                 `json:"error"`
 	Stack       []Task                 `json:"stack"`
 	CurrentTask Task                   `json:"current_task"`
 	Results     map[string]interface{} `json:"results"`
@@ -0,0 +1,80 @@
+package orchestrator
+
+// Trace is the complete trampoline tree of one analysis: every task that
+// ran, which task spawned it, and what each of its dispatches returned. It
+// is recorded when Config.TraceStore is set so a run can be inspected, or
+// replayed from its recorded results, after the fact.
+type Trace struct {
+	Nodes []TraceNode `json:"nodes"` // Indexed by TraceNode.ID; the root is node 0
+}
+
+// TraceNode is one task in the tree. A task that requested continuations is
+// dispatched again after each child returns, so it has one step per
+// dispatch.
+type TraceNode struct {
+	ID              int                    `json:"id"`
+	Parent          int                    `json:"parent"` // -1 for the root
+	AgentType       string                 `json:"agent_type"`
+	TaskDescription string                 `json:"task_description"`
+	Context         map[string]interface{} `json:"context,omitempty"`
+	Depth           int                    `json:"depth"`
+	ReturnTo        string                 `json:"return_to,omitempty"`
+	Steps           []TraceStep            `json:"steps"`
+}
+
+// TraceStep is what one dispatch of a task returned: a continuation
+// spawning the next node, or a result
+type TraceStep struct {
+	Cached       bool                 `json:"cached,omitempty"` // Served from the cache or an earlier query
+	Continuation *ContinuationRequest `json:"continuation,omitempty"`
+	Result       *AnalysisResult      `json:"result,omitempty"`
+}
+
+// Children returns the IDs of the nodes spawned by node id, in the order
+// they were requested
+func (t *Trace) Children(id int) []int {
+	var children []int
+	for _, node := range t.Nodes {
+		if node.Parent == id && node.ID != id {
+			children = append(children, node.ID)
+		}
+	}
+	return children
+}
+
+// traceTask adds task to the trace as a child of parent (-1 for the root)
+// and points the task at its node. No-op unless tracing is enabled.
+func (o *Orchestrator) traceTask(task *Task, parent int) {
+	if o.trace == nil {
+		return
+	}
+
+	node := TraceNode{
+		ID:              len(o.trace.Nodes),
+		Parent:          parent,
+		AgentType:       task.AgentType,
+		TaskDescription: task.TaskDescription,
+		Context:         task.Context,
+		Depth:           task.Depth,
+		Steps:           []TraceStep{},
+	}
+	if task.ReturnTo != nil {
+		node.ReturnTo = *task.ReturnTo
+	}
+	task.TraceNode = node.ID
+	o.trace.Nodes = append(o.trace.Nodes, node)
+}
+
+// traceStep records what the current task's dispatch returned
+func (o *Orchestrator) traceStep(result *SubagentResult, cached bool) {
+	if o.trace == nil || o.currentTask.TraceNode >= len(o.trace.Nodes) {
+		return
+	}
+
+	node := &o.trace.Nodes[o.currentTask.TraceNode]
+	node.Steps = append(node.Steps, TraceStep{
+		Cached:       cached,
+		Continuation: result.Continuation,
+		Result:       result.Analysis,
+	})
+}
@@ -0,0 +1,100 @@
+package orchestrator_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// treeDispatcher runs a two-level recursion: the root spawns "api" and
+// "db" Workers in turn, and the api Worker spawns a Reader
+func treeDispatcher(ctx context.Context, task *orchestrator.Task) (*orchestrator.SubagentResult, error) {
+	continuation := func(agentType, description, returnTo string) (*orchestrator.SubagentResult, error) {
+		return &orchestrator.SubagentResult{
+			Type: orchestrator.ResultTypeContinuation,
+			Continuation: &orchestrator.ContinuationRequest{
+				Type:      "CONTINUATION",
+				AgentType: agentType,
+				Task:      description,
+				Context:   map[string]interface{}{"area": returnTo},
+				ReturnTo:  returnTo,
+			},
+		}, nil
+	}
+
+	switch {
+	case task.Depth == 0 && len(task.ChildResults) == 0:
+		return continuation("Worker", "inspect the api", "api")
+	case task.Depth == 0 && len(task.ChildResults) == 1:
+		return continuation("Worker", "inspect the db", "db")
+	case task.TaskDescription == "inspect the api" && len(task.ChildResults) == 0:
+		return continuation("Reader", "read the handlers", "handlers")
+	}
+
+	return &orchestrator.SubagentResult{
+		Type: orchestrator.ResultTypeAnalysis,
+		Analysis: &orchestrator.AnalysisResult{
+			Type:     "RESULT",
+			Content:  "done: " + task.TaskDescription,
+			Metadata: map[string]interface{}{},
+		},
+	}, nil
+}
+
+func TestTraceStore(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir()
+	config.CacheEnabled = false
+	config.TraceStore = true
+	orch := orchestrator.New(config, zerolog.Nop())
+	orch.SetDispatcher(treeDispatcher)
+
+	result, err := orch.AnalyzeDocument(context.Background(), "test.txt", "map the service")
+	require.NoError(t, err)
+	require.NotNil(t, result.Trace)
+
+	// The trace survives being stored as JSON
+	data, err := json.Marshal(result.Trace)
+	require.NoError(t, err)
+	var trace orchestrator.Trace
+	require.NoError(t, json.Unmarshal(data, &trace))
+
+	require.Len(t, trace.Nodes, 4)
+	root, api, handlers, db := trace.Nodes[0], trace.Nodes[1], trace.Nodes[2], trace.Nodes[3]
+
+	assert.Equal(t, -1, root.Parent)
+	assert.Equal(t, "map the service", root.TaskDescription)
+	assert.Equal(t, []int{api.ID, db.ID}, trace.Children(root.ID))
+	assert.Equal(t, []int{handlers.ID}, trace.Children(api.ID))
+	assert.Empty(t, trace.Children(handlers.ID))
+	assert.Empty(t, trace.Children(db.ID))
+
+	assert.Equal(t, "api", api.ReturnTo)
+	assert.Equal(t, 2, handlers.Depth)
+	assert.Equal(t, "Reader", handlers.AgentType)
+
+	// The root was dispatched once per child plus once to finish; every
+	// dispatch's outcome is recorded
+	require.Len(t, root.Steps, 3)
+	assert.Equal(t, "api", root.Steps[0].Continuation.ReturnTo)
+	assert.Equal(t, "db", root.Steps[1].Continuation.ReturnTo)
+	assert.Equal(t, "done: map the service", root.Steps[2].Result.Content)
+	require.Len(t, handlers.Steps, 1)
+	assert.Equal(t, "done: read the handlers", handlers.Steps[0].Result.Content)
+}
+
+func TestTraceStoreDisabled(t *testing.T) {
+	config := orchestrator.DefaultConfig()
+	config.WorkDir = t.TempDir()
+	orch := orchestrator.New(config, zerolog.Nop())
+	orch.SetDispatcher(treeDispatcher)
+
+	result, err := orch.AnalyzeDocument(context.Background(), "test.txt", "map the service")
+	require.NoError(t, err)
+	assert.Nil(t, result.Trace)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 )
 
 // Backend defines the interface for storage implementations
@@ -14,9 +15,34 @@ type Backend interface {
 	// Search performs a search query
 	Search(ctx context.Context, query string, limit int) ([]*SearchResult, error)
 
+	// SearchStream performs a search query, calling fn for each result in
+	// rank order. Returning an error from fn stops the search.
+	SearchStream(ctx context.Context, query string, opts SearchOptions, fn func(*SearchResult) error) error
+
+	// Get retrieves a single analysis by ID
+	Get(ctx context.Context, id string) (*AnalysisData, error)
+
 	// GetAll retrieves all stored analyses
 	GetAll(ctx context.Context) ([]*AnalysisData, error)
 
+	// Walk calls fn for each stored analysis without loading them all into
+	// memory. Returning an error from fn stops the walk.
+	Walk(ctx context.Context, fn func(*AnalysisData) error) error
+
+	// PruneExpired deletes analyses older than the configured TTL
+	PruneExpired(ctx context.Context) (int, error)
+
+	// Consolidate keeps only the newest analysis per query/focus and
+	// namespace for a path
+	Consolidate(ctx context.Context, path string) error
+
+	// Rate records a helpful or unhelpful rating for an analysis, which
+	// boosts or demotes it in later searches, and returns it updated
+	Rate(ctx context.Context, id string, helpful bool) (*AnalysisData, error)
+
+	// Reindex rebuilds search structures from the stored analysis files
+	Reindex(ctx context.Context) error
+
 	// Close cleans up resources
 	Close() error
 
@@ -24,9 +50,88 @@ type Backend interface {
 	Name() string
 }
 
+// SearchOptions narrows a search
+type SearchOptions struct {
+	Limit int       // Maximum results; zero is unlimited
+	Since time.Time // Only analyses at or after this time; zero is unbounded
+	Focus string    // Only analyses with this focus; empty matches any
+
+	// Namespace limits results to one namespace; empty matches any
+	Namespace string
+}
+
 // Config holds storage configuration
 type Config struct {
-	RAGDir string
+	RAGDir      string
+	AnalysisTTL time.Duration // Zero disables expiry
+	Tokenizer   *Tokenizer    // Nil uses DefaultTokenizer
+
+	// DedupScoreGap collapses search hits for the same path and focus whose
+	// normalized scores are within this gap, keeping the newest. Zero disables.
+	DedupScoreGap float64
+
+	// MaxPerPath keeps only the newest N analyses per path, pruning older
+	// ones on store. Zero keeps everything.
+	MaxPerPath int
+
+	// Compress gzips per-analysis files (analysis_<id>.json.gz). Existing
+	// uncompressed files remain readable either way; the index is never
+	// compressed.
+	Compress bool
+
+	// IndexFields selects the text indexed for search. Nil uses
+	// DefaultIndexFields; see extractFields for the accepted names.
+	IndexFields []string
+
+	// DeterministicIDs derives IDs for new analyses from their inputs (see
+	// DeterministicID) instead of random UUIDs, so storing the same analysis
+	// again overwrites it
+	DeterministicIDs bool
+
+	// CompactJSON writes analysis and index files without indentation,
+	// roughly halving their size. Both layouts are always readable.
+	CompactJSON bool
+
+	// RewriteMigrated writes analyses from older schema versions back to
+	// disk once migrated on startup. Otherwise they are migrated on every
+	// read and the files are left as they were.
+	RewriteMigrated bool
+
+	// RecencyHalfLife favors fresher analyses when relevance is close: a
+	// new analysis' score is raised by RecencyBoost, a boost that halves
+	// with every RecencyHalfLife of age. Zero ranks on relevance alone.
+	RecencyHalfLife time.Duration
+
+	// Backends names the backends analyses are written to, primary first;
+	// see NewBackend. Empty uses bm25 alone.
+	Backends []string
+
+	// ParallelSearch makes searches across several Backends query them all
+	// at once; nil tries them in order
+	ParallelSearch *ParallelSearch
+
+	// Fsync makes analysis and index writes durable: each file is synced
+	// to disk and atomically renamed into place before Store returns.
+	// Slower, so off by default.
+	Fsync bool
+
+	// OpTimeout bounds every backend operation, so a stalled backend
+	// returns ErrTimeout instead of hanging its caller. Zero only honors
+	// the caller's context.
+	OpTimeout time.Duration
+
+	// SigningKey makes analyses tamper-evident: each is signed with an
+	// HMAC under this key when written and verified when read, the result
+	// reported in AnalysisData.Integrity. Nil disables signing.
+	SigningKey []byte
+
+	// DeltaEncoding stores the result content of a re-run analysis as a
+	// line diff against the previous analysis of the same path, query,
+	// focus and namespace when nearly identical, reconstructing it on
+	// load. Every DeltaSnapshotEvery-th version is stored in full (zero
+	// uses DefaultDeltaSnapshotEvery).
+	DeltaEncoding      bool
+	DeltaSnapshotEvery int
 }
 
 // DefaultConfig returns default storage configuration
@@ -36,14 +141,62 @@ func DefaultConfig(ragDir string) *Config {
 	}
 }
 
-// NewBackend creates a storage backend
+// NewBackend creates a storage backend. When config.Backends names more
+// than one backend they are combined in a MultiBackend with the first as
+// primary.
 func NewBackend(ctx context.Context, config *Config) (Backend, error) {
-	// Use BM25 backend (pure Go, zero external dependencies)
-	backend, err := NewBM25Backend(config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize BM25 backend: %w", err)
+	names := config.Backends
+	if len(names) == 0 {
+		names = []string{"bm25"}
+	}
+
+	seen := make(map[string]bool)
+	backends := make([]Backend, 0, len(names))
+	for _, name := range names {
+		if seen[name] {
+			closeAll(backends)
+			return nil, fmt.Errorf("storage backend %q listed more than once", name)
+		}
+		seen[name] = true
+
+		backend, err := newNamedBackend(name, config)
+		if err != nil {
+			closeAll(backends)
+			return nil, err
+		}
+		if config.OpTimeout > 0 {
+			backend = NewTimeoutBackend(backend, config.OpTimeout)
+		}
+		backends = append(backends, backend)
 	}
 
-	fmt.Fprintf(os.Stderr, "Using BM25 backend (pure Go search, zero dependencies)\n")
-	return backend, nil
+	if len(backends) == 1 {
+		return backends[0], nil
+	}
+	multi := NewMultiBackend(backends[0], backends[1:]...)
+	multi.SetParallelSearch(config.ParallelSearch)
+	return multi, nil
+}
+
+// newNamedBackend creates a single backend by name
+func newNamedBackend(name string, config *Config) (Backend, error) {
+	switch name {
+	case "bm25":
+		// Use BM25 backend (pure Go, zero external dependencies)
+		backend, err := NewBM25Backend(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize BM25 backend: %w", err)
+		}
+
+		fmt.Fprintf(os.Stderr, "Using BM25 backend (pure Go search, zero dependencies)\n")
+		return backend, nil
+	default:
+		return nil, fmt.Errorf("unsupported storage backend %q (available: bm25)", name)
+	}
+}
+
+func closeAll(backends []Backend) {
+	for _, b := range backends {
+		b.Close()
+	}
 }
@@ -4,23 +4,52 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/crawlab-team/bm25"
 	"github.com/google/uuid"
+	"github.com/kukks/claude-rlm/internal/fsutil"
+	"github.com/kukks/claude-rlm/internal/hash"
 )
 
 // BM25Backend implements storage using BM25 search algorithm
 type BM25Backend struct {
-	ragDir string
-	index  bm25.BM25 // BM25 interface
-	corpus []string  // Document corpus for BM25
-	docIDs []string  // Document IDs corresponding to corpus
-	mu     sync.RWMutex
+	ragDir      string
+	analysisTTL time.Duration
+	dedupGap    float64
+	maxPerPath  int
+	compress    bool
+	indexFields []string
+	stableIDs   bool
+	compactJSON bool
+	rewriteOld  bool
+	tokenizer   *Tokenizer
+	index       bm25.BM25 // BM25 interface
+	corpus      []string  // Document corpus for BM25
+	docIDs      []string  // Document IDs corresponding to corpus
+	mu          sync.RWMutex
+
+	// docs holds what scoring needs beyond relevance, by document ID
+	docs       map[string]docScoring
+	halfLife   time.Duration
+	fsync      bool
+	signingKey []byte // Nil stores analyses unsigned
+
+	// snapshotEvery is the delta chain length after which content is
+	// stored in full; zero disables delta encoding
+	snapshotEvery int
+}
+
+// docScoring is the per-document state that adjusts relevance scores
+type docScoring struct {
+	rating    int
+	timestamp time.Time
 }
 
 // NewBM25Backend creates a new BM25 storage backend
@@ -30,10 +59,39 @@ func NewBM25Backend(config *Config) (*BM25Backend, error) {
 		return nil, fmt.Errorf("failed to create RAG directory: %w", err)
 	}
 
+	tokenizer := config.Tokenizer
+	if tokenizer == nil {
+		tokenizer = DefaultTokenizer()
+	}
+
+	indexFields := config.IndexFields
+	if len(indexFields) == 0 {
+		indexFields = DefaultIndexFields
+	}
+
 	backend := &BM25Backend{
-		ragDir: config.RAGDir,
-		corpus: make([]string, 0),
-		docIDs: make([]string, 0),
+		ragDir:      config.RAGDir,
+		analysisTTL: config.AnalysisTTL,
+		dedupGap:    config.DedupScoreGap,
+		maxPerPath:  config.MaxPerPath,
+		compress:    config.Compress,
+		indexFields: indexFields,
+		stableIDs:   config.DeterministicIDs,
+		compactJSON: config.CompactJSON,
+		rewriteOld:  config.RewriteMigrated,
+		tokenizer:   tokenizer,
+		corpus:      make([]string, 0),
+		docIDs:      make([]string, 0),
+		docs:        make(map[string]docScoring),
+		halfLife:    config.RecencyHalfLife,
+		fsync:       config.Fsync,
+		signingKey:  config.SigningKey,
+	}
+	if config.DeltaEncoding {
+		backend.snapshotEvery = config.DeltaSnapshotEvery
+		if backend.snapshotEvery <= 0 {
+			backend.snapshotEvery = DefaultDeltaSnapshotEvery
+		}
 	}
 
 	// Load existing documents from disk
@@ -51,65 +109,225 @@ func (b *BM25Backend) Store(ctx context.Context, data *AnalysisData) error {
 
 	// Generate ID if not provided
 	if data.ID == "" {
-		data.ID = uuid.New().String()
+		if b.stableIDs {
+			data.ID = DeterministicID(data)
+		} else {
+			data.ID = uuid.New().String()
+		}
 	}
 
 	data.Backend = "bm25"
-	data.Version = "3.0"
-
-	// Create searchable content string
-	contentJSON, _ := json.Marshal(data.Result)
-	content := fmt.Sprintf("%s %s %s", data.Query, data.Focus, string(contentJSON))
-
-	// Add to corpus
-	b.corpus = append(b.corpus, content)
-	b.docIDs = append(b.docIDs, data.ID)
+	data.Version = SchemaVersion
 
-	// Rebuild BM25 index with new corpus
-	b.rebuildIndex()
+	// Deltas against the analysis being replaced would no longer apply
+	if b.docIndex(data.ID) >= 0 {
+		index, err := b.loadIndexFile()
+		if err != nil {
+			return err
+		}
+		if err := b.storeDependentsInFull(index, map[string]bool{data.ID: true}); err != nil {
+			return err
+		}
+	}
 
 	// Save full data to JSON file
-	if err := b.saveJSONFile(data); err != nil {
+	stored := data
+	if b.snapshotEvery > 0 {
+		stored = b.deltaEncode(data)
+	}
+	if err := b.saveJSONFile(stored); err != nil {
 		return fmt.Errorf("failed to save JSON file: %w", err)
 	}
+	data.Signature, data.Integrity = stored.Signature, stored.Integrity
 
 	// Update index
 	if err := b.updateIndex(data); err != nil {
 		return fmt.Errorf("failed to update index: %w", err)
 	}
 
+	// Add to corpus only once persisted, keeping corpus and docIDs aligned.
+	// Storing an existing ID again replaces it.
+	if i := b.docIndex(data.ID); i >= 0 {
+		b.corpus[i] = b.searchableContent(data)
+	} else {
+		b.corpus = append(b.corpus, b.searchableContent(data))
+		b.docIDs = append(b.docIDs, data.ID)
+	}
+	b.track(data)
+
+	// Rebuild BM25 index with new corpus
+	b.rebuildIndex()
+
+	if err := b.enforceRetention(data.Path); err != nil {
+		return fmt.Errorf("failed to enforce retention: %w", err)
+	}
+
 	return nil
 }
 
 // Search performs BM25-based search
 func (b *BM25Backend) Search(ctx context.Context, query string, limit int) ([]*SearchResult, error) {
+	results := make([]*SearchResult, 0)
+	err := b.SearchStream(ctx, query, SearchOptions{Limit: limit}, func(result *SearchResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// SearchStream performs BM25-based search, loading and emitting results one
+// at a time. When collapsing near-duplicates every candidate has to be
+// loaded first, so results are only streamed after collapsing.
+func (b *BM25Backend) SearchStream(ctx context.Context, query string, opts SearchOptions, fn func(*SearchResult) error) error {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
+	scoredResults, method, err := b.score(query)
+	if err != nil {
+		return err
+	}
+
+	// Filter before truncating so excluded hits don't use up the limit
+	if !opts.Since.IsZero() || opts.Focus != "" || opts.Namespace != "" {
+		scoredResults, err = b.filterIndexed(scoredResults, func(entry IndexEntry) bool {
+			if entry.Timestamp.Before(opts.Since) {
+				return false
+			}
+			if opts.Namespace != "" && !SameNamespace(entry.Namespace, opts.Namespace) {
+				return false
+			}
+			return opts.Focus == "" || entry.Focus == opts.Focus
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	limit := opts.Limit
+	// Limit results. When collapsing duplicates, every candidate is loaded
+	// so the limit applies to distinct results.
+	if limit > 0 && len(scoredResults) > limit && b.dedupGap <= 0 {
+		scoredResults = scoredResults[:limit]
+	}
+
+	// Load full data for top results
+	results := make([]*SearchResult, 0, len(scoredResults))
+	for _, sr := range scoredResults {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		data, err := b.loadJSONFile(sr.id)
+		if err != nil {
+			continue // Skip if JSON file not found
+		}
+
+		// Normalize score to 0-100 range
+		result := &SearchResult{
+			Data:         data,
+			Score:        math.Min(math.Max(sr.score, 0), 100),
+			SearchMethod: method,
+		}
+
+		if b.dedupGap <= 0 {
+			if err := fn(result); err != nil {
+				return err
+			}
+			continue
+		}
+		results = append(results, result)
+	}
+
+	if b.dedupGap <= 0 {
+		return nil
+	}
+
+	results = collapseNearDuplicates(results, b.dedupGap)
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	for _, result := range results {
+		if err := fn(result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// filterIndexed keeps the scored results whose index entry satisfies keep,
+// using the index so no analysis files need to be loaded. Results missing
+// from the index are dropped. Callers must hold the read lock.
+func (b *BM25Backend) filterIndexed(scoredResults []scoredResult, keep func(IndexEntry) bool) ([]scoredResult, error) {
+	index, err := b.loadIndexFile()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]IndexEntry, len(index))
+	for _, entry := range index {
+		entries[entry.ID] = entry
+	}
+
+	filtered := scoredResults[:0]
+	for _, sr := range scoredResults {
+		if entry, ok := entries[sr.id]; ok && keep(entry) {
+			filtered = append(filtered, sr)
+		}
+	}
+	return filtered, nil
+}
+
+// scoredResult is a document ID with its normalized score after recency
+// and rating boosts. It may leave the 0-100 range; it is only clamped for
+// display so boosts still break ties between top-scoring documents.
+type scoredResult struct {
+	id    string
+	score float64
+}
+
+// score returns the documents matching query with a positive score, best
+// first after recency and rating boosts, and the search method used. When
+// the BM25 index could not be built it falls back to keyword scoring.
+// Callers must hold the read lock.
+func (b *BM25Backend) score(query string) ([]scoredResult, string, error) {
 	if len(b.corpus) == 0 {
-		return []*SearchResult{}, nil
+		return nil, "bm25", nil
 	}
 
 	// Tokenize query
-	queryTokens := tokenize(query)
+	queryTokens := b.tokenizer.Tokenize(query)
+	if len(queryTokens) == 0 {
+		return nil, "bm25", nil
+	}
 
 	// Get BM25 scores for all documents
-	scores, err := b.index.GetScores(queryTokens)
-	if err != nil {
-		return nil, fmt.Errorf("BM25 scoring failed: %w", err)
+	method := "bm25"
+	var scores []float64
+	if b.index != nil {
+		var err error
+		scores, err = b.index.GetScores(queryTokens)
+		if err != nil {
+			return nil, "", fmt.Errorf("BM25 scoring failed: %w", err)
+		}
+	} else {
+		method = "keyword"
+		scores = b.keywordScores(queryTokens)
 	}
 
 	// Create scored results
-	type scoredResult struct {
-		id    string
-		score float64
-	}
+	now := time.Now()
 	scoredResults := make([]scoredResult, 0, len(scores))
 	for i, score := range scores {
-		if score > 0 { // Only include results with positive scores
+		if score > 0 && i < len(b.docIDs) { // Only include results with positive scores
+			id := b.docIDs[i]
 			scoredResults = append(scoredResults, scoredResult{
-				id:    b.docIDs[i],
-				score: score,
+				id:    id,
+				score: b.adjustScore(id, normalizeScore(score), now),
 			})
 		}
 	}
@@ -119,53 +337,345 @@ func (b *BM25Backend) Search(ctx context.Context, query string, limit int) ([]*S
 		return scoredResults[i].score > scoredResults[j].score
 	})
 
-	// Limit results
-	if limit > 0 && len(scoredResults) > limit {
-		scoredResults = scoredResults[:limit]
+	return scoredResults, method, nil
+}
+
+// keywordScores scores each document by the fraction of its tokens that
+// match the query. Used only when the BM25 index is unavailable.
+func (b *BM25Backend) keywordScores(queryTokens []string) []float64 {
+	terms := make(map[string]bool, len(queryTokens))
+	for _, token := range queryTokens {
+		terms[token] = true
 	}
 
-	// Load full data for top results
-	results := make([]*SearchResult, 0, len(scoredResults))
-	for _, sr := range scoredResults {
-		data, err := b.loadJSONFile(sr.id)
-		if err != nil {
-			continue // Skip if JSON file not found
+	scores := make([]float64, len(b.corpus))
+	for i, doc := range b.corpus {
+		tokens := b.tokenizer.Tokenize(doc)
+		if len(tokens) == 0 {
+			continue
 		}
 
-		// Normalize score to 0-100 range
-		normalizedScore := normalizeScore(sr.score)
+		matches := 0
+		for _, token := range tokens {
+			if terms[token] {
+				matches++
+			}
+		}
+		// Scaled so a document made entirely of query terms normalizes to 100
+		scores[i] = 10 * float64(matches) / float64(len(tokens))
+	}
+	return scores
+}
 
-		results = append(results, &SearchResult{
-			Data:         data,
-			Score:        normalizedScore,
-			SearchMethod: "bm25",
-		})
+// Get retrieves a single analysis by ID
+func (b *BM25Backend) Get(ctx context.Context, id string) (*AnalysisData, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	data, err := b.loadJSONFile(id)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("analysis %s not found", id)
+		}
+		return nil, err
 	}
 
-	return results, nil
+	return data, nil
 }
 
 // GetAll retrieves all stored analyses
 func (b *BM25Backend) GetAll(ctx context.Context) ([]*AnalysisData, error) {
+	results := make([]*AnalysisData, 0)
+	err := b.Walk(ctx, func(data *AnalysisData) error {
+		results = append(results, data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Walk calls fn for each stored analysis in insertion order
+func (b *BM25Backend) Walk(ctx context.Context, fn func(*AnalysisData) error) error {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
 	// Read index file
 	index, err := b.loadIndexFile()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	results := make([]*AnalysisData, 0, len(index))
 	for _, entry := range index {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		data, err := b.loadJSONFile(entry.ID)
 		if err != nil {
 			continue
 		}
-		results = append(results, data)
+		if err := fn(data); err != nil {
+			return err
+		}
 	}
 
-	return results, nil
+	return nil
+}
+
+// PruneExpired deletes analyses older than the configured TTL.
+// Entries without a timestamp are never pruned.
+func (b *BM25Backend) PruneExpired(ctx context.Context) (int, error) {
+	if b.analysisTTL <= 0 {
+		return 0, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	index, err := b.loadIndexFile()
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-b.analysisTTL)
+	expired := make(map[string]bool)
+	for _, entry := range index {
+		if entry.Timestamp.IsZero() {
+			continue
+		}
+		if entry.Timestamp.Before(cutoff) {
+			expired[entry.ID] = true
+		}
+	}
+
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	if err := b.deleteEntries(index, expired); err != nil {
+		return 0, err
+	}
+
+	return len(expired), nil
+}
+
+// Consolidate removes superseded analyses for a path, keeping the newest
+// analysis for each distinct query/focus pair in each namespace
+func (b *BM25Backend) Consolidate(ctx context.Context, path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	index, err := b.loadIndexFile()
+	if err != nil {
+		return err
+	}
+
+	newest := make(map[string]IndexEntry)
+	superseded := make(map[string]bool)
+	for _, entry := range index {
+		if !hash.SamePath(entry.Path, path) {
+			continue
+		}
+
+		key := entry.Query + "\x00" + entry.Focus + "\x00" + normalizeNamespace(entry.Namespace)
+		current, exists := newest[key]
+		if !exists {
+			newest[key] = entry
+			continue
+		}
+
+		// Later index entries win ties, matching insertion order
+		if entry.Timestamp.Before(current.Timestamp) {
+			superseded[entry.ID] = true
+		} else {
+			superseded[current.ID] = true
+			newest[key] = entry
+		}
+	}
+
+	if len(superseded) == 0 {
+		return nil
+	}
+
+	return b.deleteEntries(index, superseded)
+}
+
+// enforceRetention deletes the oldest analyses for path beyond maxPerPath.
+// Callers must hold the write lock.
+func (b *BM25Backend) enforceRetention(path string) error {
+	if b.maxPerPath <= 0 {
+		return nil
+	}
+
+	index, err := b.loadIndexFile()
+	if err != nil {
+		return err
+	}
+
+	// Collect entries for the path in reverse insertion order so the stable
+	// sort below keeps later entries ahead on equal timestamps
+	entries := make([]IndexEntry, 0)
+	for i := len(index) - 1; i >= 0; i-- {
+		if hash.SamePath(index[i].Path, path) {
+			entries = append(entries, index[i])
+		}
+	}
+	if len(entries) <= b.maxPerPath {
+		return nil
+	}
+
+	// Newest first
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+
+	excess := make(map[string]bool)
+	for _, entry := range entries[b.maxPerPath:] {
+		excess[entry.ID] = true
+	}
+
+	return b.deleteEntries(index, excess)
+}
+
+// Rate records a helpful or unhelpful rating, persisting it in the
+// analysis file
+func (b *BM25Backend) Rate(ctx context.Context, id string, helpful bool) (*AnalysisData, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data, err := b.loadJSONFile(id)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("analysis %s not found", id)
+		}
+		return nil, err
+	}
+
+	// Re-signing would vouch for the tampered content
+	if data.Integrity == IntegrityTampered {
+		return nil, fmt.Errorf("analysis %s: %w", id, ErrTampered)
+	}
+
+	if data.Feedback == nil {
+		data.Feedback = &Feedback{}
+	}
+	if helpful {
+		data.Feedback.Helpful++
+	} else {
+		data.Feedback.Unhelpful++
+	}
+
+	if err := b.saveJSONFile(data); err != nil {
+		return nil, fmt.Errorf("failed to save rating: %w", err)
+	}
+	b.track(data)
+
+	return data, nil
+}
+
+// track caches an analysis' rating and timestamp for scoring. Callers
+// must hold the write lock.
+func (b *BM25Backend) track(data *AnalysisData) {
+	b.docs[data.ID] = docScoring{rating: data.Feedback.Rating(), timestamp: data.Timestamp}
+}
+
+// adjustScore applies the recency boost, when configured, and the rating
+// boost to a document's normalized relevance score. Callers must hold the
+// read lock.
+func (b *BM25Backend) adjustScore(id string, score float64, now time.Time) float64 {
+	doc := b.docs[id]
+	if b.halfLife > 0 && !doc.timestamp.IsZero() {
+		score *= 1 + RecencyBoost*recencyWeight(now.Sub(doc.timestamp), b.halfLife)
+	}
+	return score + RatingBoost*float64(doc.rating)
+}
+
+// Reindex rebuilds index.json and the BM25 corpus from the analysis files
+// on disk, recovering from a lost or drifted index. Unreadable files are
+// skipped with a warning.
+func (b *BM25Backend) Reindex(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ids, err := b.analysisIDs()
+	if err != nil {
+		return err
+	}
+
+	analyses := make([]*AnalysisData, 0, len(ids))
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		data, err := b.loadJSONFile(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping unreadable analysis %s: %v\n", id, err)
+			continue
+		}
+		data.ID = id
+		analyses = append(analyses, data)
+	}
+
+	// Restore insertion order as closely as the files allow
+	sort.SliceStable(analyses, func(i, j int) bool {
+		return analyses[i].Timestamp.Before(analyses[j].Timestamp)
+	})
+
+	index := make([]IndexEntry, 0, len(analyses))
+	b.corpus = make([]string, 0, len(analyses))
+	b.docIDs = make([]string, 0, len(analyses))
+	b.docs = make(map[string]docScoring)
+	for _, data := range analyses {
+		index = append(index, newIndexEntry(data))
+		b.corpus = append(b.corpus, b.searchableContent(data))
+		b.docIDs = append(b.docIDs, data.ID)
+		b.track(data)
+	}
+
+	b.index = nil
+	b.rebuildIndex()
+
+	return b.saveIndexFile(index)
+}
+
+// analysisIDs returns the IDs of all analysis files in the RAG directory,
+// compressed or not, sorted
+func (b *BM25Backend) analysisIDs() ([]string, error) {
+	entries, err := os.ReadDir(b.ragDir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "analysis_") {
+			continue
+		}
+
+		id := strings.TrimPrefix(name, "analysis_")
+		switch {
+		case strings.HasSuffix(id, ".json.gz"):
+			id = strings.TrimSuffix(id, ".json.gz")
+		case strings.HasSuffix(id, ".json"):
+			id = strings.TrimSuffix(id, ".json")
+		default:
+			continue
+		}
+
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+	return ids, nil
 }
 
 // Close cleans up resources
@@ -187,10 +697,12 @@ func (b *BM25Backend) rebuildIndex() {
 
 	// Create new BM25 index using Okapi variant
 	// Parameters: k1=1.5, b=0.75 (standard BM25 parameters), logger=nil
-	index, err := bm25.NewBM25Okapi(b.corpus, tokenize, 1.5, 0.75, nil)
+	index, err := bm25.NewBM25Okapi(b.corpus, b.tokenizer.Tokenize, 1.5, 0.75, nil)
 	if err != nil {
-		// Log error but don't fail - we'll continue with nil index
-		fmt.Fprintf(os.Stderr, "Warning: Failed to build BM25 index: %v\n", err)
+		// Log error but don't fail - a stale index would no longer line up
+		// with docIDs, so drop it and let Search fall back to keyword scoring
+		fmt.Fprintf(os.Stderr, "Warning: Failed to build BM25 index, falling back to keyword search: %v\n", err)
+		b.index = nil
 		return
 	}
 	b.index = index
@@ -210,17 +722,25 @@ func (b *BM25Backend) loadFromDisk() error {
 
 	// Load documents and build corpus
 	for _, entry := range index {
-		data, err := b.loadJSONFile(entry.ID)
+		data, err := b.readJSONFile(entry.ID)
 		if err != nil {
 			continue // Skip missing files
 		}
 
-		// Recreate searchable content
-		contentJSON, _ := json.Marshal(data.Result)
-		content := fmt.Sprintf("%s %s %s", data.Query, data.Focus, string(contentJSON))
+		// Upgrade files from older versions, persisting the result if asked
+		if MigrateAnalysis(data) && b.rewriteOld && data.Integrity != IntegrityTampered {
+			if err := b.saveJSONFile(data); err != nil {
+				return fmt.Errorf("failed to rewrite migrated analysis %s: %w", data.ID, err)
+			}
+		}
+		if err := b.resolveDelta(data, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping analysis %s: %v\n", entry.ID, err)
+			continue
+		}
 
-		b.corpus = append(b.corpus, content)
+		b.corpus = append(b.corpus, b.searchableContent(data))
 		b.docIDs = append(b.docIDs, data.ID)
+		b.track(data)
 	}
 
 	// Rebuild index with loaded corpus
@@ -229,23 +749,96 @@ func (b *BM25Backend) loadFromDisk() error {
 	return nil
 }
 
-// saveJSONFile saves the full analysis data to a JSON file
+// analysisFile returns the path of an analysis JSON file
+func (b *BM25Backend) analysisFile(id string, compressed bool) string {
+	name := fmt.Sprintf("analysis_%s.json", id)
+	if compressed {
+		name += ".gz"
+	}
+	return filepath.Join(b.ragDir, name)
+}
+
+// marshalJSON encodes v for a stored file, indented unless compact JSON
+// is configured
+func (b *BM25Backend) marshalJSON(v interface{}) ([]byte, error) {
+	if b.compactJSON {
+		return json.Marshal(v)
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// saveJSONFile saves the full analysis data to a JSON file, gzipped when
+// compression is enabled
 func (b *BM25Backend) saveJSONFile(data *AnalysisData) error {
-	filename := filepath.Join(b.ragDir, fmt.Sprintf("analysis_%s.json", data.ID))
+	if b.signingKey != nil {
+		signature, err := SignAnalysis(data, b.signingKey)
+		if err != nil {
+			return fmt.Errorf("failed to sign analysis: %w", err)
+		}
+		data.Signature = signature
+		data.Integrity = IntegrityVerified
+	}
 
-	jsonData, err := json.MarshalIndent(data, "", "  ")
+	jsonData, err := b.marshalJSON(data)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(filename, jsonData, 0644)
+	// Drop a copy in the other format left by an earlier store of this ID
+	stale := b.analysisFile(data.ID, !b.compress)
+	if err := os.Remove(stale); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if !b.compress {
+		return fsutil.WriteFile(b.analysisFile(data.ID, false), jsonData, 0644, b.fsync)
+	}
+
+	compressed, err := gzipBytes(jsonData)
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(b.analysisFile(data.ID, true), compressed, 0644, b.fsync)
 }
 
-// loadJSONFile loads the full analysis data from a JSON file
+// loadJSONFile loads the full analysis data from a JSON file, migrated to
+// SchemaVersion and with delta-encoded content reconstructed. Compressed
+// and uncompressed files are both read regardless of the current setting.
 func (b *BM25Backend) loadJSONFile(id string) (*AnalysisData, error) {
-	filename := filepath.Join(b.ragDir, fmt.Sprintf("analysis_%s.json", id))
+	data, err := b.readJSONFile(id)
+	if err != nil {
+		return nil, err
+	}
+	MigrateAnalysis(data)
+	if err := b.resolveDelta(data, 0); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readJSONFile loads an analysis file exactly as it was written, verifying
+// its signature when a signing key is configured
+func (b *BM25Backend) readJSONFile(id string) (*AnalysisData, error) {
+	data, err := b.readRawJSONFile(id)
+	if err != nil || b.signingKey == nil {
+		return data, err
+	}
 
-	data, err := os.ReadFile(filename)
+	data.Integrity = VerifyAnalysis(data, b.signingKey)
+	if data.Integrity == IntegrityTampered {
+		fmt.Fprintf(os.Stderr, "Warning: analysis %s does not match its signature and may have been tampered with\n", id)
+	}
+	return data, nil
+}
+
+// readRawJSONFile reads and decodes an analysis file
+func (b *BM25Backend) readRawJSONFile(id string) (*AnalysisData, error) {
+	data, err := os.ReadFile(b.analysisFile(id, true))
+	if err == nil {
+		data, err = gunzipBytes(data)
+	} else if os.IsNotExist(err) {
+		data, err = os.ReadFile(b.analysisFile(id, false))
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -258,15 +851,39 @@ func (b *BM25Backend) loadJSONFile(id string) (*AnalysisData, error) {
 	return &analysis, nil
 }
 
-// updateIndex adds an entry to the index
+// updateIndex adds an entry to the index, replacing any entry with the
+// same ID
 func (b *BM25Backend) updateIndex(data *AnalysisData) error {
 	index, err := b.loadIndexFile()
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	// Add new entry
-	entry := IndexEntry{
+	kept := index[:0]
+	for _, entry := range index {
+		if entry.ID != data.ID {
+			kept = append(kept, entry)
+		}
+	}
+	kept = append(kept, newIndexEntry(data))
+
+	return b.saveIndexFile(kept)
+}
+
+// docIndex returns the corpus position of a document, or -1. Callers must
+// hold the lock.
+func (b *BM25Backend) docIndex(id string) int {
+	for i, docID := range b.docIDs {
+		if docID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// newIndexEntry builds the index entry for an analysis
+func newIndexEntry(data *AnalysisData) IndexEntry {
+	return IndexEntry{
 		ID:             data.ID,
 		Query:          data.Query,
 		Focus:          data.Focus,
@@ -274,18 +891,67 @@ func (b *BM25Backend) updateIndex(data *AnalysisData) error {
 		Path:           data.Path,
 		HasVectorEmbed: false, // BM25 doesn't use embeddings
 		StorageBackend: "bm25",
+		Namespace:      data.Namespace,
 	}
+}
 
-	index = append(index, entry)
+// searchableContent returns the text indexed for an analysis
+func (b *BM25Backend) searchableContent(data *AnalysisData) string {
+	return extractFields(data, b.indexFields)
+}
 
-	// Save index
+// saveIndexFile writes the index to disk
+func (b *BM25Backend) saveIndexFile(index []IndexEntry) error {
 	indexFile := filepath.Join(b.ragDir, "index.json")
-	indexData, err := json.MarshalIndent(index, "", "  ")
+	indexData, err := b.marshalJSON(index)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(indexFile, indexData, 0644)
+	return fsutil.WriteFile(indexFile, indexData, 0644, b.fsync)
+}
+
+// deleteEntries removes the given IDs from disk, the index file and the
+// in-memory corpus. Callers must hold the write lock.
+func (b *BM25Backend) deleteEntries(index []IndexEntry, ids map[string]bool) error {
+	if err := b.storeDependentsInFull(index, ids); err != nil {
+		return err
+	}
+
+	for id := range ids {
+		for _, compressed := range []bool{false, true} {
+			if err := os.Remove(b.analysisFile(id, compressed)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to delete analysis %s: %w", id, err)
+			}
+		}
+	}
+
+	kept := make([]IndexEntry, 0, len(index))
+	for _, entry := range index {
+		if !ids[entry.ID] {
+			kept = append(kept, entry)
+		}
+	}
+	if err := b.saveIndexFile(kept); err != nil {
+		return err
+	}
+
+	corpus := make([]string, 0, len(b.corpus))
+	docIDs := make([]string, 0, len(b.docIDs))
+	for i, id := range b.docIDs {
+		if !ids[id] {
+			corpus = append(corpus, b.corpus[i])
+			docIDs = append(docIDs, id)
+		} else {
+			delete(b.docs, id)
+		}
+	}
+	b.corpus = corpus
+	b.docIDs = docIDs
+	b.index = nil
+	b.rebuildIndex()
+
+	return nil
 }
 
 // loadIndexFile loads the index from disk
@@ -308,27 +974,6 @@ func (b *BM25Backend) loadIndexFile() ([]IndexEntry, error) {
 	return index, nil
 }
 
-// tokenize splits text into tokens for BM25
-func tokenize(text string) []string {
-	// Convert to lowercase
-	text = strings.ToLower(text)
-
-	// Split on whitespace and punctuation
-	tokens := strings.FieldsFunc(text, func(r rune) bool {
-		return !((r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'))
-	})
-
-	// Filter out very short tokens
-	filtered := make([]string, 0, len(tokens))
-	for _, token := range tokens {
-		if len(token) >= 2 { // Keep tokens with 2+ characters
-			filtered = append(filtered, token)
-		}
-	}
-
-	return filtered
-}
-
 // normalizeScore normalizes BM25 score to 0-100 range
 func normalizeScore(score float64) float64 {
 	// BM25 scores are unbounded, but typically 0-10 for good matches
@@ -339,3 +984,12 @@ func normalizeScore(score float64) float64 {
 	}
 	return normalized
 }
+
+// recencyWeight decays from 1 for a brand-new analysis to 0.5 at one
+// half-life and towards 0 beyond
+func recencyWeight(age, halfLife time.Duration) float64 {
+	if age < 0 {
+		age = 0
+	}
+	return math.Exp2(-float64(age) / float64(halfLife))
+}
@@ -0,0 +1,801 @@
+package storage_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kukks/claude-rlm/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBackend(t *testing.T, config *storage.Config) *storage.BM25Backend {
+	t.Helper()
+	if config == nil {
+		config = storage.DefaultConfig(t.TempDir())
+	}
+	backend, err := storage.NewBM25Backend(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { backend.Close() })
+	return backend
+}
+
+func TestPruneExpired(t *testing.T) {
+	config := storage.DefaultConfig(t.TempDir())
+	config.AnalysisTTL = 24 * time.Hour
+	backend := newTestBackend(t, config)
+	ctx := context.Background()
+
+	old := &storage.AnalysisData{
+		Query:     "old authentication analysis",
+		Timestamp: time.Now().Add(-48 * time.Hour),
+		Result:    map[string]interface{}{"content": "old findings"},
+		Path:      "src",
+	}
+	recent := &storage.AnalysisData{
+		Query:     "recent authentication analysis",
+		Timestamp: time.Now().Add(-1 * time.Hour),
+		Result:    map[string]interface{}{"content": "recent findings"},
+		Path:      "src",
+	}
+	undated := &storage.AnalysisData{
+		Query:  "undated authentication analysis",
+		Result: map[string]interface{}{"content": "undated findings"},
+		Path:   "src",
+	}
+	for _, data := range []*storage.AnalysisData{old, recent, undated} {
+		require.NoError(t, backend.Store(ctx, data))
+	}
+
+	pruned, err := backend.PruneExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+
+	all, err := backend.GetAll(ctx)
+	require.NoError(t, err)
+	ids := make([]string, 0, len(all))
+	for _, data := range all {
+		ids = append(ids, data.ID)
+	}
+	assert.ElementsMatch(t, []string{recent.ID, undated.ID}, ids)
+
+	// Pruned analyses must no longer be searchable
+	results, err := backend.Search(ctx, "authentication", 10)
+	require.NoError(t, err)
+	for _, r := range results {
+		assert.NotEqual(t, old.ID, r.Data.ID)
+	}
+
+	// Reopening must not resurrect the pruned entry
+	reopened := newTestBackend(t, config)
+	all, err = reopened.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
+
+func TestPruneExpiredDisabled(t *testing.T) {
+	backend := newTestBackend(t, nil)
+	ctx := context.Background()
+
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+		Query:     "ancient analysis",
+		Timestamp: time.Now().Add(-24 * 365 * time.Hour),
+	}))
+
+	pruned, err := backend.PruneExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, pruned)
+}
+
+func TestConsolidate(t *testing.T) {
+	backend := newTestBackend(t, nil)
+	ctx := context.Background()
+	now := time.Now()
+
+	stored := []*storage.AnalysisData{
+		{Query: "security review", Focus: "security", Path: "src", Timestamp: now.Add(-3 * time.Hour)},
+		{Query: "security review", Focus: "security", Path: "src", Timestamp: now.Add(-1 * time.Hour)},
+		{Query: "security review", Focus: "security", Path: "src", Timestamp: now.Add(-2 * time.Hour)},
+		{Query: "architecture overview", Focus: "architecture", Path: "src", Timestamp: now.Add(-5 * time.Hour)},
+		{Query: "security review", Focus: "security", Path: "docs", Timestamp: now.Add(-4 * time.Hour)},
+	}
+	for _, data := range stored {
+		require.NoError(t, backend.Store(ctx, data))
+	}
+
+	require.NoError(t, backend.Consolidate(ctx, "src"))
+
+	all, err := backend.GetAll(ctx)
+	require.NoError(t, err)
+	ids := make([]string, 0, len(all))
+	for _, data := range all {
+		ids = append(ids, data.ID)
+	}
+	assert.ElementsMatch(t, []string{stored[1].ID, stored[3].ID, stored[4].ID}, ids)
+}
+
+func TestSearchCollapsesNearDuplicates(t *testing.T) {
+	config := storage.DefaultConfig(t.TempDir())
+	config.DedupScoreGap = 1.0
+	backend := newTestBackend(t, config)
+	ctx := context.Background()
+	now := time.Now()
+
+	// Three re-runs of the same analysis, plus unrelated entries
+	reruns := []*storage.AnalysisData{
+		{Query: "auth review", Focus: "security", Path: "src", Timestamp: now.Add(-3 * time.Hour)},
+		{Query: "auth review", Focus: "security", Path: "src", Timestamp: now.Add(-1 * time.Hour)},
+		{Query: "auth review", Focus: "security", Path: "src", Timestamp: now.Add(-2 * time.Hour)},
+	}
+	for _, data := range reruns {
+		data.Result = map[string]interface{}{"content": "token validation is missing"}
+		require.NoError(t, backend.Store(ctx, data))
+	}
+	other := &storage.AnalysisData{
+		Query: "auth review", Focus: "security", Path: "docs", Timestamp: now.Add(-5 * time.Hour),
+		Result: map[string]interface{}{"content": "docs mention token validation"},
+	}
+	require.NoError(t, backend.Store(ctx, other))
+	for _, q := range []string{"build pipeline", "database schema", "frontend layout", "logging setup", "cli flags", "release process", "error handling", "config loading"} {
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{Query: q, Path: "misc"}))
+	}
+
+	results, err := backend.Search(ctx, "token validation", 10)
+	require.NoError(t, err)
+
+	ids := make([]string, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.Data.ID)
+	}
+	assert.ElementsMatch(t, []string{reruns[1].ID, other.ID}, ids)
+
+	// With collapsing disabled every re-run is returned
+	config.DedupScoreGap = 0
+	plain := newTestBackend(t, config)
+	results, err = plain.Search(ctx, "token validation", 10)
+	require.NoError(t, err)
+	assert.Len(t, results, 4)
+}
+
+func TestMaxPerPathRetention(t *testing.T) {
+	config := storage.DefaultConfig(t.TempDir())
+	config.MaxPerPath = 3
+	backend := newTestBackend(t, config)
+	ctx := context.Background()
+
+	base := time.Now().Add(-time.Hour)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		data := &storage.AnalysisData{
+			Query:     "architecture overview",
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			Result:    map[string]interface{}{"content": "findings"},
+			Path:      "src",
+		}
+		require.NoError(t, backend.Store(ctx, data))
+		ids = append(ids, data.ID)
+	}
+
+	// Other paths are not affected by the limit
+	other := &storage.AnalysisData{
+		Query:     "architecture overview",
+		Timestamp: base,
+		Result:    map[string]interface{}{"content": "findings"},
+		Path:      "docs",
+	}
+	require.NoError(t, backend.Store(ctx, other))
+
+	all, err := backend.GetAll(ctx)
+	require.NoError(t, err)
+	kept := make([]string, 0, len(all))
+	for _, data := range all {
+		kept = append(kept, data.ID)
+	}
+	assert.ElementsMatch(t, append([]string{other.ID}, ids[2:]...), kept)
+
+	// Pruned analyses are gone from search too
+	results, err := backend.Search(ctx, "architecture", 10)
+	require.NoError(t, err)
+	for _, result := range results {
+		assert.NotContains(t, ids[:2], result.Data.ID)
+	}
+}
+
+func TestCompressedStorage(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	large := strings.Repeat("The session cache is invalidated on every login. ", 2000)
+
+	// An uncompressed analysis written before compression was enabled
+	plain := newTestBackend(t, storage.DefaultConfig(dir))
+	legacy := &storage.AnalysisData{Query: "legacy analysis", Result: map[string]interface{}{"content": large}, Path: "src"}
+	require.NoError(t, plain.Store(ctx, legacy))
+
+	config := storage.DefaultConfig(dir)
+	config.Compress = true
+	backend := newTestBackend(t, config)
+	data := &storage.AnalysisData{Query: "session cache analysis", Result: map[string]interface{}{"content": large}, Path: "src"}
+	require.NoError(t, backend.Store(ctx, data))
+
+	compressed, err := os.Stat(filepath.Join(dir, "analysis_"+data.ID+".json.gz"))
+	require.NoError(t, err)
+	uncompressed, err := os.Stat(filepath.Join(dir, "analysis_"+legacy.ID+".json"))
+	require.NoError(t, err)
+	assert.Less(t, compressed.Size()*10, uncompressed.Size())
+
+	// Reopening reads both formats transparently
+	reopened := newTestBackend(t, config)
+	for _, want := range []*storage.AnalysisData{legacy, data} {
+		got, err := reopened.Get(ctx, want.ID)
+		require.NoError(t, err)
+		assert.Equal(t, want.Query, got.Query)
+		assert.Equal(t, large, got.Result["content"])
+	}
+
+	all, err := reopened.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	// The index stays plain JSON
+	index, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	require.NoError(t, err)
+	assert.True(t, json.Valid(index))
+}
+
+func TestCompactJSON(t *testing.T) {
+	ctx := context.Background()
+	newData := func() *storage.AnalysisData {
+		return &storage.AnalysisData{
+			ID:         "fixed-id",
+			Query:      "session cache analysis",
+			Result:     map[string]interface{}{"content": "cache", "metadata": map[string]interface{}{"files": []interface{}{"a.go", "b.go"}}},
+			Path:       "src",
+			FileHashes: map[string]string{"a.go": "1", "b.go": "2"},
+		}
+	}
+
+	prettyDir, compactDir := t.TempDir(), t.TempDir()
+	require.NoError(t, newTestBackend(t, storage.DefaultConfig(prettyDir)).Store(ctx, newData()))
+	config := storage.DefaultConfig(compactDir)
+	config.CompactJSON = true
+	require.NoError(t, newTestBackend(t, config).Store(ctx, newData()))
+
+	for _, name := range []string{"analysis_fixed-id.json", "index.json"} {
+		pretty, err := os.Stat(filepath.Join(prettyDir, name))
+		require.NoError(t, err)
+		compact, err := os.Stat(filepath.Join(compactDir, name))
+		require.NoError(t, err)
+		assert.Less(t, compact.Size(), pretty.Size(), name)
+	}
+
+	// Either layout reads back under either setting
+	for _, dir := range []string{prettyDir, compactDir} {
+		for _, compact := range []bool{false, true} {
+			config := storage.DefaultConfig(dir)
+			config.CompactJSON = compact
+			got, err := newTestBackend(t, config).Get(ctx, "fixed-id")
+			require.NoError(t, err, dir)
+			assert.Equal(t, "session cache analysis", got.Query)
+			assert.Equal(t, map[string]string{"a.go": "1", "b.go": "2"}, got.FileHashes)
+		}
+	}
+}
+
+func TestSearchNamespace(t *testing.T) {
+	ctx := context.Background()
+	backend := newTestBackend(t, nil)
+
+	for _, data := range []*storage.AnalysisData{
+		{Query: "billing retries", Result: map[string]interface{}{"content": "retry policy"}, Path: "svc", Namespace: "shop"},
+		{Query: "crawler retries", Result: map[string]interface{}{"content": "retry policy"}, Path: "svc", Namespace: "crawler"},
+		{Query: "legacy retries", Result: map[string]interface{}{"content": "retry policy"}, Path: "svc"},
+	} {
+		require.NoError(t, backend.Store(ctx, data))
+	}
+
+	// Filler keeps the shared term rare enough to score positively
+	for i := 0; i < 8; i++ {
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+			Query: fmt.Sprintf("unrelated filler %d", i), Result: map[string]interface{}{"content": "x"}, Path: "other",
+		}))
+	}
+
+	search := func(namespace string) []string {
+		var queries []string
+		err := backend.SearchStream(ctx, "retries", storage.SearchOptions{Namespace: namespace}, func(r *storage.SearchResult) error {
+			queries = append(queries, r.Data.Query)
+			return nil
+		})
+		require.NoError(t, err)
+		return queries
+	}
+
+	assert.Equal(t, []string{"billing retries"}, search("shop"))
+	assert.Equal(t, []string{"crawler retries"}, search("crawler"))
+	assert.Equal(t, []string{"legacy retries"}, search(storage.DefaultNamespace))
+	assert.Len(t, search(""), 3)
+
+	// Consolidation keeps one analysis per namespace
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{Query: "retries", Result: map[string]interface{}{"content": "a"}, Path: "svc", Namespace: "shop"}))
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{Query: "retries", Result: map[string]interface{}{"content": "b"}, Path: "svc", Namespace: "crawler"}))
+	require.NoError(t, backend.Consolidate(ctx, "svc"))
+	all, err := backend.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 13)
+}
+
+func TestReindex(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	backend := newTestBackend(t, storage.DefaultConfig(dir))
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 6; i++ {
+		query := fmt.Sprintf("filler topic %d", i)
+		if i < 2 {
+			query = fmt.Sprintf("websocket reconnect handling %d", i)
+		}
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+			Query:     query,
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			Result:    map[string]interface{}{"content": "findings"},
+			Path:      "src",
+		}))
+	}
+
+	// Losing the index leaves the store empty on reopen
+	require.NoError(t, os.Remove(filepath.Join(dir, "index.json")))
+	reopened := newTestBackend(t, storage.DefaultConfig(dir))
+	all, err := reopened.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+
+	require.NoError(t, reopened.Reindex(ctx))
+
+	all, err = reopened.GetAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 6)
+	for i := 1; i < len(all); i++ {
+		assert.False(t, all[i].Timestamp.Before(all[i-1].Timestamp))
+	}
+
+	results, err := reopened.Search(ctx, "websocket", 10)
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	// The rebuilt index survives another reopen
+	again := newTestBackend(t, storage.DefaultConfig(dir))
+	results, err = again.Search(ctx, "websocket", 10)
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+}
+
+func TestSearchSince(t *testing.T) {
+	backend := newTestBackend(t, nil)
+	ctx := context.Background()
+	now := time.Now()
+
+	// Older analyses are the better matches, so filtering after truncation
+	// would return nothing
+	for i := 0; i < 3; i++ {
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+			Query:     "deploy pipeline rollback",
+			Timestamp: now.AddDate(0, 0, -10-i),
+			Result:    map[string]interface{}{"content": "old"},
+			Path:      "ci",
+		}))
+	}
+	recent := &storage.AnalysisData{
+		Query:     "deploy notes with a lot of other words in it",
+		Timestamp: now.AddDate(0, 0, -2),
+		Result:    map[string]interface{}{"content": "new"},
+		Path:      "ci",
+	}
+	require.NoError(t, backend.Store(ctx, recent))
+	for i := 0; i < 8; i++ {
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+			Query:     fmt.Sprintf("unrelated filler %d", i),
+			Timestamp: now,
+			Result:    map[string]interface{}{"content": "x"},
+			Path:      "docs",
+		}))
+	}
+
+	var results []*storage.SearchResult
+	collect := func(r *storage.SearchResult) error {
+		results = append(results, r)
+		return nil
+	}
+
+	require.NoError(t, backend.SearchStream(ctx, "deploy", storage.SearchOptions{Limit: 1}, collect))
+	require.Len(t, results, 1)
+	assert.NotEqual(t, recent.ID, results[0].Data.ID)
+
+	results = nil
+	require.NoError(t, backend.SearchStream(ctx, "deploy", storage.SearchOptions{Limit: 1, Since: now.AddDate(0, 0, -7)}, collect))
+	require.Len(t, results, 1)
+	assert.Equal(t, recent.ID, results[0].Data.ID)
+
+	results = nil
+	require.NoError(t, backend.SearchStream(ctx, "deploy", storage.SearchOptions{Since: now.AddDate(0, 0, -11)}, collect))
+	assert.Len(t, results, 3)
+}
+
+func TestSearchFallsBackWhenIndexBuildFails(t *testing.T) {
+	config := storage.DefaultConfig(t.TempDir())
+	config.Tokenizer = storage.NewTokenizer(2, []string{"null"})
+	backend := newTestBackend(t, config)
+	ctx := context.Background()
+
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+		Query:  "database connection pooling",
+		Result: map[string]interface{}{"content": "pool size"},
+		Path:   "db",
+	}))
+
+	// A document with no indexable tokens makes the BM25 build fail
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{Path: "empty"}))
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+		Query:  "http routing",
+		Result: map[string]interface{}{"content": "router"},
+		Path:   "web",
+	}))
+
+	var results []*storage.SearchResult
+	require.NotPanics(t, func() {
+		var err error
+		results, err = backend.Search(ctx, "pooling", 10)
+		require.NoError(t, err)
+	})
+	require.Len(t, results, 1)
+	assert.Equal(t, "db", results[0].Data.Path)
+	assert.Equal(t, "keyword", results[0].SearchMethod)
+	assert.Greater(t, results[0].Score, 0.0)
+
+	// Every stored analysis is still retrievable
+	all, err := backend.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+}
+
+func TestIndexFieldsExcludeJSONScaffolding(t *testing.T) {
+	storeAll := func(backend *storage.BM25Backend) {
+		ctx := context.Background()
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+			Query: "review",
+			Result: map[string]interface{}{
+				"content":  "goroutine leak in the scheduler",
+				"metadata": map[string]interface{}{"confidence": "high"},
+			},
+			Path: "sched",
+		}))
+		for i := 0; i < 5; i++ {
+			require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+				Query:  fmt.Sprintf("filler %d", i),
+				Result: map[string]interface{}{"content": "unrelated"},
+				Path:   "other",
+			}))
+		}
+	}
+	count := func(backend *storage.BM25Backend, query string) int {
+		results, err := backend.Search(context.Background(), query, 10)
+		require.NoError(t, err)
+		return len(results)
+	}
+
+	// Default fields: JSON keys are not indexed, content is
+	backend := newTestBackend(t, nil)
+	storeAll(backend)
+	assert.Zero(t, count(backend, "metadata"))
+	assert.Zero(t, count(backend, "confidence"))
+	assert.Equal(t, 1, count(backend, "goroutine"))
+
+	// Selecting the metadata key indexes its values, still without keys
+	config := storage.DefaultConfig(t.TempDir())
+	config.IndexFields = []string{"query", "metadata"}
+	backend = newTestBackend(t, config)
+	storeAll(backend)
+	assert.Equal(t, 1, count(backend, "high"))
+	assert.Zero(t, count(backend, "confidence"))
+	assert.Zero(t, count(backend, "goroutine"))
+
+	// "result" restores the raw JSON behaviour
+	config = storage.DefaultConfig(t.TempDir())
+	config.IndexFields = []string{"result"}
+	backend = newTestBackend(t, config)
+	storeAll(backend)
+	assert.Equal(t, 1, count(backend, "metadata"))
+}
+
+func TestDeterministicIDs(t *testing.T) {
+	config := storage.DefaultConfig(t.TempDir())
+	config.DeterministicIDs = true
+	backend := newTestBackend(t, config)
+	ctx := context.Background()
+
+	analysis := func(content string) *storage.AnalysisData {
+		return &storage.AnalysisData{
+			Query:      "how are sessions stored",
+			Focus:      "security",
+			Timestamp:  time.Now(),
+			Result:     map[string]interface{}{"content": content},
+			Path:       "src",
+			FileHashes: map[string]string{"session.go": "aaa", "store.go": "bbb"},
+		}
+	}
+
+	first := analysis("first run")
+	require.NoError(t, backend.Store(ctx, first))
+	second := analysis("second run")
+	require.NoError(t, backend.Store(ctx, second))
+
+	// Identical inputs share an ID and the re-run replaces the first
+	assert.Equal(t, first.ID, second.ID)
+	all, err := backend.GetAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, "second run", all[0].Result["content"])
+
+	// Any input change yields a new ID
+	changed := analysis("second run")
+	changed.FileHashes["store.go"] = "ccc"
+	require.NoError(t, backend.Store(ctx, changed))
+	assert.NotEqual(t, first.ID, changed.ID)
+
+	// Random IDs remain the default
+	defaults := newTestBackend(t, nil)
+	a, b := analysis("same findings"), analysis("same findings")
+	require.NoError(t, defaults.Store(ctx, a))
+	require.NoError(t, defaults.Store(ctx, b))
+	assert.NotEqual(t, a.ID, b.ID)
+}
+
+func TestRatingBoostsSearch(t *testing.T) {
+	dir := t.TempDir()
+	backend := newTestBackend(t, storage.DefaultConfig(dir))
+	ctx := context.Background()
+
+	// Two otherwise-equal analyses, plus filler so the terms score positively
+	for _, id := range []string{"unrated", "rated"} {
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+			ID:     id,
+			Query:  "token refresh",
+			Result: map[string]interface{}{"content": "tokens are refreshed by the session middleware"},
+		}))
+	}
+	for _, topic := range []string{"build pipeline", "database schema", "http routing", "logging setup"} {
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{Query: topic}))
+	}
+
+	results, err := backend.Search(ctx, "token refresh", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, results[0].Score, results[1].Score)
+
+	rated, err := backend.Rate(ctx, "rated", true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rated.Feedback.Rating())
+
+	results, err = backend.Search(ctx, "token refresh", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "rated", results[0].Data.ID)
+	assert.InDelta(t, results[1].Score+storage.RatingBoost, results[0].Score, 1e-9)
+
+	// Ratings persist in the analysis file
+	reopened := newTestBackend(t, storage.DefaultConfig(dir))
+	results, err = reopened.Search(ctx, "token refresh", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "rated", results[0].Data.ID)
+	assert.Equal(t, &storage.Feedback{Helpful: 1}, results[0].Data.Feedback)
+
+	// Net unhelpful ratings demote
+	for i := 0; i < 2; i++ {
+		_, err = reopened.Rate(ctx, "rated", false)
+		require.NoError(t, err)
+	}
+	results, err = reopened.Search(ctx, "token refresh", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "unrated", results[0].Data.ID)
+
+	_, err = reopened.Rate(ctx, "missing", true)
+	assert.Error(t, err)
+}
+
+func TestRecencyBoost(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	store := func(backend storage.Backend) {
+		// The old, shorter analysis matches the query better than the
+		// recent one
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+			ID:        "old",
+			Query:     "cache eviction",
+			Timestamp: now.Add(-90 * 24 * time.Hour),
+			Result:    map[string]interface{}{"content": "uses an LRU list"},
+		}))
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+			ID:        "recent",
+			Query:     "storage review",
+			Timestamp: now.Add(-time.Hour),
+			Result:    map[string]interface{}{"content": "the cache eviction policy was rewritten"},
+		}))
+		for _, topic := range []string{"build pipeline", "database schema", "http routing", "logging setup"} {
+			require.NoError(t, backend.Store(ctx, &storage.AnalysisData{Query: topic, Timestamp: now}))
+		}
+	}
+
+	// By relevance alone the old analysis wins
+	plain := newTestBackend(t, nil)
+	store(plain)
+	results, err := plain.Search(ctx, "cache eviction", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "old", results[0].Data.ID)
+	require.Less(t, results[0].Score, results[1].Score*(1+storage.RecencyBoost), "fixture relevance must be close")
+
+	// A 30 day half-life favors the recent one
+	config := storage.DefaultConfig(t.TempDir())
+	config.RecencyHalfLife = 30 * 24 * time.Hour
+	boosted := newTestBackend(t, config)
+	store(boosted)
+	results, err = boosted.Search(ctx, "cache eviction", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "recent", results[0].Data.ID)
+
+	// With a one-minute half-life the hour-old boost has all but decayed
+	config = storage.DefaultConfig(t.TempDir())
+	config.RecencyHalfLife = time.Minute
+	decayed := newTestBackend(t, config)
+	store(decayed)
+	results, err = decayed.Search(ctx, "cache eviction", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "old", results[0].Data.ID)
+}
+
+func TestFsyncStorage(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	config := storage.DefaultConfig(dir)
+	config.Fsync = true
+	backend := newTestBackend(t, config)
+
+	data := &storage.AnalysisData{Query: "durable audit trail", Result: map[string]interface{}{"content": "stored safely"}, Path: "src"}
+	require.NoError(t, backend.Store(ctx, data))
+
+	// Writes land through a rename, leaving no temporary files behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{"analysis_" + data.ID + ".json", "index.json"}, names)
+
+	reopened := newTestBackend(t, config)
+	got, err := reopened.Get(ctx, data.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "stored safely", got.Result["content"])
+}
+
+func TestSignedAnalyses(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	config := storage.DefaultConfig(dir)
+	config.SigningKey = []byte("secret")
+
+	backend := newTestBackend(t, config)
+	data := &storage.AnalysisData{
+		Query:  "signed authentication analysis",
+		Result: map[string]interface{}{"content": "findings", "metadata": map[string]interface{}{"depth": 2}},
+		Path:   "src",
+	}
+	require.NoError(t, backend.Store(ctx, data))
+	require.NotEmpty(t, data.Signature)
+	require.NoError(t, backend.Close())
+
+	reopened := newTestBackend(t, config)
+	got, err := reopened.Get(ctx, data.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storage.IntegrityVerified, got.Integrity)
+
+	// Rating re-signs the analysis
+	_, err = reopened.Rate(ctx, data.ID, true)
+	require.NoError(t, err)
+	got, err = reopened.Get(ctx, data.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storage.IntegrityVerified, got.Integrity)
+
+	// Edit the stored findings behind rlm's back
+	file := filepath.Join(dir, fmt.Sprintf("analysis_%s.json", data.ID))
+	raw, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, []byte(strings.Replace(string(raw), "findings", "forged", 1)), 0644))
+
+	got, err = reopened.Get(ctx, data.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storage.IntegrityTampered, got.Integrity)
+	assert.Equal(t, "forged", got.Result["content"])
+
+	_, err = reopened.Rate(ctx, data.ID, true)
+	assert.ErrorIs(t, err, storage.ErrTampered)
+
+	// Without a key nothing is verified
+	unkeyed := newTestBackend(t, storage.DefaultConfig(dir))
+	got, err = unkeyed.Get(ctx, data.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got.Integrity)
+}
+
+func TestDeltaEncoding(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	config := storage.DefaultConfig(dir)
+	config.DeltaEncoding = true
+	config.DeltaSnapshotEvery = 3
+	backend := newTestBackend(t, config)
+
+	lines := make([]string, 200)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("Finding %d: the handler at line %d validates its input.", i, i*10)
+	}
+
+	// Each re-run changes a couple of lines
+	var versions []*storage.AnalysisData
+	for v := 0; v < 5; v++ {
+		lines[v*7] = fmt.Sprintf("Finding %d: revised as marker%d.", v*7, v)
+		lines = append(lines, fmt.Sprintf("New finding in run %d about sessions.", v))
+		data := &storage.AnalysisData{
+			Query:     "input validation review",
+			Timestamp: time.Now().Add(time.Duration(v) * time.Minute),
+			Result:    map[string]interface{}{"content": strings.Join(lines, "\n"), "metadata": map[string]interface{}{"run": v}},
+			Path:      "src",
+		}
+		require.NoError(t, backend.Store(ctx, data))
+		versions = append(versions, data)
+	}
+
+	fileSize := func(id string) int64 {
+		info, err := os.Stat(filepath.Join(dir, fmt.Sprintf("analysis_%s.json", id)))
+		require.NoError(t, err)
+		return info.Size()
+	}
+	full := fileSize(versions[0].ID)
+	assert.Less(t, fileSize(versions[1].ID), full/2)
+	assert.Less(t, fileSize(versions[2].ID), full/2)
+	// Every third version is a full snapshot
+	assert.Greater(t, fileSize(versions[3].ID), full/2)
+	assert.Less(t, fileSize(versions[4].ID), full/2)
+
+	reopened := newTestBackend(t, config)
+	for _, want := range versions {
+		got, err := reopened.Get(ctx, want.ID)
+		require.NoError(t, err)
+		assert.Equal(t, want.Result["content"], got.Result["content"])
+		assert.Nil(t, got.Delta)
+	}
+
+	// Reconstructed content is searchable
+	results, err := reopened.Search(ctx, "marker4", 1)
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, versions[4].ID, results[0].Data.ID)
+
+	// Deleting the bases keeps the remaining analysis readable
+	require.NoError(t, reopened.Consolidate(ctx, "src"))
+	latest := versions[len(versions)-1]
+	got, err := reopened.Get(ctx, latest.ID)
+	require.NoError(t, err)
+	assert.Equal(t, latest.Result["content"], got.Result["content"])
+}
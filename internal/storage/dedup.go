@@ -0,0 +1,42 @@
+package storage
+
+import "sort"
+
+// collapseNearDuplicates merges results for the same path and focus whose
+// scores are within gap of each other, keeping the newest analysis of each
+// group. Results must be sorted by score (descending); the output is too.
+func collapseNearDuplicates(results []*SearchResult, gap float64) []*SearchResult {
+	if gap <= 0 || len(results) < 2 {
+		return results
+	}
+
+	collapsed := make([]*SearchResult, 0, len(results))
+	for _, r := range results {
+		merged := false
+		for i, kept := range collapsed {
+			if kept.Data.Path != r.Data.Path || kept.Data.Focus != r.Data.Focus {
+				continue
+			}
+			if kept.Score-r.Score > gap {
+				continue
+			}
+
+			// Near-duplicate: keep whichever analysis is newer
+			if r.Data.Timestamp.After(kept.Data.Timestamp) {
+				collapsed[i] = r
+			}
+			merged = true
+			break
+		}
+
+		if !merged {
+			collapsed = append(collapsed, r)
+		}
+	}
+
+	sort.SliceStable(collapsed, func(i, j int) bool {
+		return collapsed[i].Score > collapsed[j].Score
+	})
+
+	return collapsed
+}
@@ -0,0 +1,339 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+)
+
+// DefaultDeltaSnapshotEvery is how often a full copy of the result content
+// is stored when delta encoding doesn't configure it
+const DefaultDeltaSnapshotEvery = 10
+
+// maxDeltaEdits bounds the changed lines a delta is computed for, keeping
+// the diff's memory small; results changing more are stored in full
+const maxDeltaEdits = 500
+
+// maxDeltaChain bounds how many bases are followed to reconstruct content,
+// so a corrupted chain cannot loop
+const maxDeltaChain = 1000
+
+// ContentDelta stores an analysis' result content as the line edits that
+// turn the content of an earlier analysis, its base, into it
+type ContentDelta struct {
+	Base     string    `json:"base"`     // ID of the analysis the edits apply to
+	Depth    int       `json:"depth"`    // Deltas since the last full snapshot, including this one
+	Ops      []DeltaOp `json:"ops"`      // Edits in order
+	Checksum string    `json:"checksum"` // SHA-256 of the reconstructed content
+}
+
+// DeltaOp is one edit of a ContentDelta; exactly one field is set
+type DeltaOp struct {
+	Keep   int      `json:"keep,omitempty"`   // Copy this many base lines
+	Skip   int      `json:"skip,omitempty"`   // Drop this many base lines
+	Insert []string `json:"insert,omitempty"` // Add these lines
+}
+
+// deltaEncode returns data to be written with its result content as a
+// delta against the newest earlier analysis of the same path, query, focus
+// and namespace, or data itself when it should be stored in full: there is
+// no base, the chain needs a snapshot, or the content changed too much for
+// a delta to be smaller. Callers must hold the write lock.
+func (b *BM25Backend) deltaEncode(data *AnalysisData) *AnalysisData {
+	content, ok := data.Result["content"].(string)
+	if !ok || content == "" {
+		return data
+	}
+
+	base := b.deltaBase(data)
+	if base == nil {
+		return data
+	}
+	depth := 1
+	if base.Delta != nil {
+		depth = base.Delta.Depth + 1
+	}
+	if depth >= b.snapshotEvery {
+		return data
+	}
+	MigrateAnalysis(base)
+	if err := b.resolveDelta(base, 0); err != nil {
+		return data
+	}
+	baseContent, ok := base.Result["content"].(string)
+	if !ok {
+		return data
+	}
+
+	ops, ok := diffLines(strings.Split(baseContent, "\n"), strings.Split(content, "\n"))
+	if !ok {
+		return data
+	}
+	encoded, err := json.Marshal(ops)
+	if err != nil || len(encoded) >= len(content)/2 {
+		return data
+	}
+
+	result := make(map[string]interface{}, len(data.Result))
+	for k, v := range data.Result {
+		if k != "content" {
+			result[k] = v
+		}
+	}
+	stored := *data
+	stored.Result = result
+	stored.Delta = &ContentDelta{
+		Base:     base.ID,
+		Depth:    depth,
+		Ops:      ops,
+		Checksum: contentChecksum(content),
+	}
+	return &stored
+}
+
+// deltaBase returns the newest stored analysis data could be a delta
+// against, as written, or nil
+func (b *BM25Backend) deltaBase(data *AnalysisData) *AnalysisData {
+	index, err := b.loadIndexFile()
+	if err != nil {
+		return nil
+	}
+
+	for i := len(index) - 1; i >= 0; i-- {
+		entry := index[i]
+		if entry.ID == data.ID || !hash.SamePath(entry.Path, data.Path) ||
+			entry.Query != data.Query || entry.Focus != data.Focus ||
+			!SameNamespace(entry.Namespace, data.Namespace) {
+			continue
+		}
+
+		base, err := b.readJSONFile(entry.ID)
+		if err != nil || base.Integrity == IntegrityTampered {
+			return nil
+		}
+		return base
+	}
+	return nil
+}
+
+// resolveDelta reconstructs the result content of a delta-encoded analysis
+// from its base, which may itself be a delta
+func (b *BM25Backend) resolveDelta(data *AnalysisData, hops int) error {
+	if data.Delta == nil {
+		return nil
+	}
+	if hops >= maxDeltaChain {
+		return fmt.Errorf("analysis %s: delta chain too long", data.ID)
+	}
+
+	base, err := b.readJSONFile(data.Delta.Base)
+	if err != nil {
+		return fmt.Errorf("analysis %s: failed to load delta base %s: %w", data.ID, data.Delta.Base, err)
+	}
+	MigrateAnalysis(base)
+	if err := b.resolveDelta(base, hops+1); err != nil {
+		return err
+	}
+
+	baseContent, _ := base.Result["content"].(string)
+	content, err := applyDelta(baseContent, data.Delta.Ops)
+	if err != nil || contentChecksum(content) != data.Delta.Checksum {
+		return fmt.Errorf("analysis %s: delta does not apply to base %s", data.ID, data.Delta.Base)
+	}
+
+	if data.Result == nil {
+		data.Result = make(map[string]interface{})
+	}
+	data.Result["content"] = content
+	data.Delta = nil
+	return nil
+}
+
+// storeDependentsInFull rewrites the analyses stored as deltas against any
+// of ids in full, so that ids can be deleted or replaced. Only analyses of
+// the same paths can depend on them. Callers must hold the write lock.
+func (b *BM25Backend) storeDependentsInFull(index []IndexEntry, ids map[string]bool) error {
+	var paths []string
+	for _, entry := range index {
+		if ids[entry.ID] {
+			paths = append(paths, entry.Path)
+		}
+	}
+
+	for _, entry := range index {
+		if ids[entry.ID] || !samePathAsAny(entry.Path, paths) {
+			continue
+		}
+		data, err := b.readJSONFile(entry.ID)
+		if err != nil || data.Delta == nil || !ids[data.Delta.Base] {
+			continue
+		}
+		// Re-signing would vouch for the tampered content
+		if data.Integrity == IntegrityTampered {
+			continue
+		}
+
+		MigrateAnalysis(data)
+		if err := b.resolveDelta(data, 0); err != nil {
+			return err
+		}
+		if err := b.saveJSONFile(data); err != nil {
+			return fmt.Errorf("failed to store analysis %s in full: %w", data.ID, err)
+		}
+	}
+	return nil
+}
+
+func samePathAsAny(path string, paths []string) bool {
+	for _, p := range paths {
+		if hash.SamePath(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func contentChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// applyDelta applies ops to the lines of base
+func applyDelta(base string, ops []DeltaOp) (string, error) {
+	lines := strings.Split(base, "\n")
+	out := make([]string, 0, len(lines))
+	pos := 0
+	for _, op := range ops {
+		switch {
+		case op.Keep > 0:
+			if pos+op.Keep > len(lines) {
+				return "", fmt.Errorf("delta keeps past the end of its base")
+			}
+			out = append(out, lines[pos:pos+op.Keep]...)
+			pos += op.Keep
+		case op.Skip > 0:
+			if pos+op.Skip > len(lines) {
+				return "", fmt.Errorf("delta skips past the end of its base")
+			}
+			pos += op.Skip
+		default:
+			out = append(out, op.Insert...)
+		}
+	}
+	if pos != len(lines) {
+		return "", fmt.Errorf("delta ends before its base")
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// diffLines returns the shortest edits turning a into b (Myers' algorithm),
+// or false when more than maxDeltaEdits lines would change
+func diffLines(a, b []string) ([]DeltaOp, bool) {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > maxDeltaEdits {
+		max = maxDeltaEdits
+	}
+
+	// v[offset+k] is the furthest x reached on diagonal k; trace holds v
+	// as it was before each step, for backtracking
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(trace, offset, a, b), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrackDiff walks the trace of diffLines back from the end of both
+// inputs and returns the edits in order
+func backtrackDiff(trace [][]int, offset int, a, b []string) []DeltaOp {
+	const (
+		keep = iota
+		skip
+		insert
+	)
+	type edit struct {
+		kind int
+		line string
+	}
+
+	var edits []edit // Reversed
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{kind: keep})
+			x--
+			y--
+		}
+		if x == prevX {
+			edits = append(edits, edit{kind: insert, line: b[prevY]})
+		} else {
+			edits = append(edits, edit{kind: skip})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		edits = append(edits, edit{kind: keep})
+		x--
+		y--
+	}
+
+	ops := make([]DeltaOp, 0)
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		last := len(ops) - 1
+		switch e.kind {
+		case keep:
+			if last >= 0 && ops[last].Keep > 0 {
+				ops[last].Keep++
+			} else {
+				ops = append(ops, DeltaOp{Keep: 1})
+			}
+		case skip:
+			if last >= 0 && ops[last].Skip > 0 {
+				ops[last].Skip++
+			} else {
+				ops = append(ops, DeltaOp{Skip: 1})
+			}
+		default:
+			if last >= 0 && ops[last].Insert != nil {
+				ops[last].Insert = append(ops[last].Insert, e.line)
+			} else {
+				ops = append(ops, DeltaOp{Insert: []string{e.line}})
+			}
+		}
+	}
+	return ops
+}
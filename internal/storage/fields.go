@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultIndexFields indexes the query, focus and result content only
+var DefaultIndexFields = []string{"query", "focus", "content"}
+
+// extractFields returns the searchable text for an analysis. "query",
+// "focus" and "path" select those fields, "result" selects the whole result
+// as raw JSON (the historical behaviour), and any other name selects that key
+// of the result. Non-string result values contribute their string leaves
+// only, so JSON keys and punctuation never reach the index.
+func extractFields(data *AnalysisData, fields []string) string {
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		switch field {
+		case "query":
+			parts = append(parts, data.Query)
+		case "focus":
+			parts = append(parts, data.Focus)
+		case "path":
+			parts = append(parts, data.Path)
+		case "result":
+			resultJSON, _ := json.Marshal(data.Result)
+			parts = append(parts, string(resultJSON))
+		default:
+			if value, ok := data.Result[field]; ok {
+				parts = appendText(parts, value)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// appendText appends the string leaves of a decoded JSON value
+func appendText(parts []string, value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return append(parts, v)
+	case []interface{}:
+		for _, item := range v {
+			parts = appendText(parts, item)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			parts = appendText(parts, v[k])
+		}
+	case nil, bool:
+	default:
+		parts = append(parts, fmt.Sprint(v))
+	}
+	return parts
+}
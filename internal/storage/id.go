@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/kukks/claude-rlm/internal/hash"
+)
+
+// analysisIDNamespace scopes deterministic analysis IDs
+var analysisIDNamespace = uuid.MustParse("edd8db5d-47b8-4d97-a87f-e90aea7dd6a6")
+
+// DeterministicID derives an analysis ID from its inputs: path, query,
+// focus, namespace and file hashes. Identical inputs always produce the
+// same ID, so re-running an analysis replaces the stored one instead of
+// adding another.
+func DeterministicID(data *AnalysisData) string {
+	files := make([]string, 0, len(data.FileHashes))
+	for file := range data.FileHashes {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	var sb strings.Builder
+	for _, field := range []string{hash.CanonicalPath(data.Path), data.Query, data.Focus} {
+		sb.WriteString(field)
+		sb.WriteByte(0)
+	}
+	// Only named namespaces are mixed in, keeping existing IDs stable
+	if normalizeNamespace(data.Namespace) != DefaultNamespace {
+		sb.WriteString(data.Namespace)
+		sb.WriteByte(0)
+	}
+	for _, file := range files {
+		sb.WriteString(file)
+		sb.WriteByte('=')
+		sb.WriteString(data.FileHashes[file])
+		sb.WriteByte('\n')
+	}
+
+	return uuid.NewSHA1(analysisIDNamespace, []byte(sb.String())).String()
+}
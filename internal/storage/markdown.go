@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+)
+
+// markdownEscaper escapes characters with special meaning in inline Markdown
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`,
+	"[", `\[`, "]", `\]`, "<", "&lt;", ">", "&gt;",
+	"#", `\#`, "|", `\|`,
+)
+
+// escapeMarkdown makes text safe to embed inline in Markdown or a table cell
+func escapeMarkdown(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", " ")
+	text = strings.ReplaceAll(text, "\n", " ")
+	return markdownEscaper.Replace(text)
+}
+
+// SnippetLength is the maximum length in runes of a search result snippet
+const SnippetLength = 200
+
+// Snippet returns the start of an analysis' result content on one line,
+// cut at a word boundary when longer than maxRunes
+func Snippet(data *AnalysisData, maxRunes int) string {
+	content, _ := data.Result["content"].(string)
+	runes := []rune(strings.Join(strings.Fields(content), " "))
+	if len(runes) <= maxRunes {
+		return string(runes)
+	}
+
+	cut := maxRunes
+	for i := maxRunes; i > maxRunes/2; i-- {
+		if runes[i] == ' ' {
+			cut = i
+			break
+		}
+	}
+	return string(runes[:cut]) + "..."
+}
+
+// RenderMarkdown renders an analysis as a standalone Markdown document
+func RenderMarkdown(data *AnalysisData) string {
+	var sb strings.Builder
+
+	// Header
+	title := data.Query
+	if title == "" {
+		title = "Analysis " + data.ID
+	}
+	fmt.Fprintf(&sb, "# %s\n\n", escapeMarkdown(title))
+	fmt.Fprintf(&sb, "- **ID:** %s\n", escapeMarkdown(data.ID))
+	if data.Focus != "" {
+		fmt.Fprintf(&sb, "- **Focus:** %s\n", escapeMarkdown(data.Focus))
+	}
+	fmt.Fprintf(&sb, "- **Path:** `%s`\n", strings.ReplaceAll(data.Path, "`", "'"))
+	fmt.Fprintf(&sb, "- **Analyzed:** %s\n", data.Timestamp.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&sb, "- **Cost:** $%.4f\n", data.Stats.TotalCostUSD)
+	if data.ClientName != "" {
+		fmt.Fprintf(&sb, "- **Client:** %s\n", escapeMarkdown(strings.TrimSpace(data.ClientName+" "+data.ClientVersion)))
+	}
+
+	// Result content is already prose (often Markdown), so it's kept verbatim
+	sb.WriteString("\n## Result\n\n")
+	if content, ok := data.Result["content"].(string); ok && content != "" {
+		sb.WriteString(strings.TrimSpace(content))
+		sb.WriteString("\n")
+	} else {
+		sb.WriteString("_No result content._\n")
+	}
+
+	// Files
+	if len(data.FileHashes) > 0 {
+		files := make([]string, 0, len(data.FileHashes))
+		for f := range data.FileHashes {
+			files = append(files, f)
+		}
+		sort.Strings(files)
+
+		fmt.Fprintf(&sb, "\n## Files (%d)\n\n", len(files))
+		sb.WriteString("| File | Hash |\n")
+		sb.WriteString("| --- | --- |\n")
+		for _, f := range files {
+			fmt.Fprintf(&sb, "| %s | `%s` |\n", escapeMarkdown(f), hash.FormatHash(data.FileHashes[f]))
+		}
+	}
+
+	// File types
+	if len(data.FileStats) > 0 {
+		exts := make([]string, 0, len(data.FileStats))
+		for ext := range data.FileStats {
+			exts = append(exts, ext)
+		}
+		// Most files first
+		sort.Slice(exts, func(i, j int) bool {
+			a, b := data.FileStats[exts[i]], data.FileStats[exts[j]]
+			if a.Files != b.Files {
+				return a.Files > b.Files
+			}
+			return exts[i] < exts[j]
+		})
+
+		sb.WriteString("\n## File Types\n\n")
+		sb.WriteString("| Type | Files | Size |\n")
+		sb.WriteString("| --- | --- | --- |\n")
+		for _, ext := range exts {
+			stat := data.FileStats[ext]
+			fmt.Fprintf(&sb, "| %s | %d | %s |\n", escapeMarkdown(ext), stat.Files, hash.FormatBytes(stat.Bytes))
+		}
+	}
+
+	// Stats
+	sb.WriteString("\n## Statistics\n\n")
+	sb.WriteString("| Metric | Value |\n")
+	sb.WriteString("| --- | --- |\n")
+	fmt.Fprintf(&sb, "| Subagent calls | %d |\n", data.Stats.TotalSubagentCalls)
+	fmt.Fprintf(&sb, "| Total tokens | %d |\n", data.Stats.TotalTokens)
+	fmt.Fprintf(&sb, "| Total cost | $%.4f |\n", data.Stats.TotalCostUSD)
+	fmt.Fprintf(&sb, "| Max depth | %d |\n", data.Stats.MaxDepthReached)
+	fmt.Fprintf(&sb, "| Cache hits | %d |\n", data.Stats.CacheHits)
+
+	return sb.String()
+}
@@ -0,0 +1,54 @@
+package storage_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kukks/claude-rlm/internal/hash"
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+	"github.com/kukks/claude-rlm/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	data := &storage.AnalysisData{
+		ID:        "abc-123",
+		Query:     "Why does *auth* fail for [admin] users?",
+		Focus:     "security",
+		Timestamp: time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC),
+		Path:      "src/auth",
+		Result:    map[string]interface{}{"content": "## Findings\n\nTokens are not refreshed."},
+		FileHashes: map[string]string{
+			"login.go":   "0123456789abcdef0123",
+			"a|weird.go": "fedcba9876543210fedc",
+		},
+		FileStats: map[string]hash.FileTypeStat{
+			".go": {Files: 2, Bytes: 2048},
+			".md": {Files: 1, Bytes: 100},
+		},
+		Stats:         orchestrator.Stats{TotalSubagentCalls: 3, TotalTokens: 4200, TotalCostUSD: 0.0126},
+		ClientName:    "claude-code",
+		ClientVersion: "1.0.0",
+	}
+
+	md := storage.RenderMarkdown(data)
+
+	// Header with escaped query
+	assert.True(t, strings.HasPrefix(md, `# Why does \*auth\* fail for \[admin\] users?`))
+	assert.Contains(t, md, "- **Focus:** security")
+	assert.Contains(t, md, "- **Path:** `src/auth`")
+	assert.Contains(t, md, "- **Analyzed:** 2025-03-01 12:30:00")
+	assert.Contains(t, md, "- **Cost:** $0.0126")
+	assert.Contains(t, md, "- **Client:** claude-code 1.0.0")
+
+	// Sections
+	assert.Contains(t, md, "## Result\n\n## Findings\n\nTokens are not refreshed.")
+	assert.Contains(t, md, "## Files (2)")
+	assert.Contains(t, md, "| login.go | `0123456789ab...` |")
+	assert.Contains(t, md, `| a\|weird.go |`)
+	assert.Contains(t, md, "## File Types")
+	assert.Contains(t, md, "| .go | 2 | 2.0 KiB |\n| .md | 1 | 100 B |")
+	assert.Contains(t, md, "## Statistics")
+	assert.Contains(t, md, "| Total tokens | 4200 |")
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+)
+
+// SchemaVersion is the AnalysisData format written by Store. Older files
+// are brought up to it by MigrateAnalysis when read.
+const SchemaVersion = "3.1"
+
+// migration upgrades an analysis from one schema version to the next
+type migration struct {
+	from, to string
+	apply    func(data *AnalysisData)
+}
+
+// migrations run in order; each applies to files at its from version
+var migrations = []migration{
+	{from: "", to: "3.0", apply: migrateUnversioned},
+	{from: "3.0", to: "3.1", apply: migrateResultDetails},
+}
+
+// MigrateAnalysis upgrades data written by an older version to
+// SchemaVersion and reports whether anything changed. Data already at, or
+// newer than, SchemaVersion is left untouched.
+func MigrateAnalysis(data *AnalysisData) bool {
+	migrated := false
+	for _, m := range migrations {
+		if data.Version != m.from {
+			continue
+		}
+		m.apply(data)
+		data.Version = m.to
+		migrated = true
+	}
+	return migrated
+}
+
+// migrateUnversioned fills fields that files from before versioning lack
+func migrateUnversioned(data *AnalysisData) {
+	if data.Backend == "" {
+		data.Backend = "bm25"
+	}
+	if data.Result == nil {
+		data.Result = make(map[string]interface{})
+	}
+	if data.FileHashes == nil {
+		data.FileHashes = make(map[string]string)
+	}
+}
+
+// migrateResultDetails derives the result details 3.1 records from what a
+// 3.0 analysis stored. The agent path was never recorded and stays empty;
+// the files considered are approximated by the hashed files.
+func migrateResultDetails(data *AnalysisData) {
+	if _, ok := data.Result["details"]; ok {
+		return
+	}
+
+	files := make([]string, 0, len(data.FileHashes))
+	for file := range data.FileHashes {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	data.Result["details"] = &orchestrator.ResultMetadata{
+		DocumentPath:    data.Path,
+		Query:           data.Query,
+		FilesConsidered: files,
+		MaxDepthReached: data.Stats.MaxDepthReached,
+		StartedAt:       data.Stats.StartTime,
+		CompletedAt:     data.Timestamp,
+	}
+}
@@ -0,0 +1,81 @@
+package storage_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/orchestrator"
+	"github.com/kukks/claude-rlm/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// legacyAnalysis is an analysis file as version 3.0 wrote it
+const legacyAnalysis = `{
+  "id": "legacy-1",
+  "query": "how is auth wired?",
+  "focus": "security",
+  "timestamp": "2025-03-01T10:00:00Z",
+  "result": {"content": "auth lives in middleware", "metadata": {}},
+  "stats": {"total_subagent_calls": 3, "max_depth_reached": 2, "start_time": "2025-03-01T09:58:00Z"},
+  "path": "/src/app",
+  "file_hashes": {"main.go": "aa", "auth/middleware.go": "bb"},
+  "version": "3.0",
+  "storage_backend": "bm25"
+}`
+
+const legacyIndex = `[{"id": "legacy-1", "query": "how is auth wired?", "focus": "security", "timestamp": "2025-03-01T10:00:00Z", "path": "/src/app", "storage_backend": "bm25"}]`
+
+func writeLegacyStore(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "analysis_legacy-1.json"), []byte(legacyAnalysis), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.json"), []byte(legacyIndex), 0644))
+	return dir
+}
+
+func TestMigrateLegacyAnalysis(t *testing.T) {
+	dir := writeLegacyStore(t)
+	backend := newTestBackend(t, storage.DefaultConfig(dir))
+
+	data, err := backend.Get(context.Background(), "legacy-1")
+	require.NoError(t, err)
+	assert.Equal(t, storage.SchemaVersion, data.Version)
+
+	details, ok := data.Result["details"].(*orchestrator.ResultMetadata)
+	require.True(t, ok, "3.0 analyses gain derived result details")
+	assert.Equal(t, "/src/app", details.DocumentPath)
+	assert.Equal(t, "how is auth wired?", details.Query)
+	assert.Equal(t, []string{"auth/middleware.go", "main.go"}, details.FilesConsidered)
+	assert.Equal(t, 2, details.MaxDepthReached)
+	assert.Equal(t, data.Timestamp, details.CompletedAt)
+
+	// Without rewriting, the file on disk is untouched
+	raw, err := os.ReadFile(filepath.Join(dir, "analysis_legacy-1.json"))
+	require.NoError(t, err)
+	assert.Equal(t, legacyAnalysis, string(raw))
+}
+
+func TestRewriteMigratedAnalysis(t *testing.T) {
+	dir := writeLegacyStore(t)
+	config := storage.DefaultConfig(dir)
+	config.RewriteMigrated = true
+	newTestBackend(t, config)
+
+	raw, err := os.ReadFile(filepath.Join(dir, "analysis_legacy-1.json"))
+	require.NoError(t, err)
+
+	var onDisk map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &onDisk))
+	assert.Equal(t, storage.SchemaVersion, onDisk["version"])
+	assert.Contains(t, onDisk["result"], "details")
+
+	// Current analyses are already at the schema version
+	data := &storage.AnalysisData{Query: "new", Result: map[string]interface{}{"content": "x"}}
+	assert.False(t, storage.MigrateAnalysis(&storage.AnalysisData{Version: storage.SchemaVersion}))
+	require.NoError(t, newTestBackend(t, config).Store(context.Background(), data))
+	assert.Equal(t, storage.SchemaVersion, data.Version)
+}
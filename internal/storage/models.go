@@ -3,23 +3,82 @@ package storage
 import (
 	"time"
 
+	"github.com/kukks/claude-rlm/internal/hash"
 	"github.com/kukks/claude-rlm/internal/orchestrator"
 )
 
 // AnalysisData represents a complete analysis entry
 type AnalysisData struct {
-	ID          string                 `json:"id"`
-	Query       string                 `json:"query"`
-	Focus       string                 `json:"focus"`
-	Timestamp   time.Time              `json:"timestamp"`
-	Result      map[string]interface{} `json:"result"`
-	Stats       orchestrator.Stats     `json:"stats"`
-	Path        string                 `json:"path"`
-	FileHashes  map[string]string      `json:"file_hashes"`
-	Version     string                 `json:"version"`
-	Backend     string                 `json:"storage_backend"`
+	ID         string                       `json:"id"`
+	Query      string                       `json:"query"`
+	Focus      string                       `json:"focus"`
+	Tags       []string                     `json:"tags,omitempty"`
+	Timestamp  time.Time                    `json:"timestamp"`
+	Result     map[string]interface{}       `json:"result"`
+	Stats      orchestrator.Stats           `json:"stats"`
+	Path       string                       `json:"path"`
+	FileHashes map[string]string            `json:"file_hashes"`
+	FileStats  map[string]hash.FileTypeStat `json:"file_stats,omitempty"` // Per-extension composition of the analyzed tree
+	Version    string                       `json:"version"`
+	Backend    string                       `json:"storage_backend"`
+
+	// HashAlgorithm is what FileHashes were computed with; empty for
+	// analyses stored before it was recorded, which used SHA256
+	HashAlgorithm string `json:"hash_algorithm,omitempty"`
+
+	// ClientName and ClientVersion identify the MCP client that requested
+	// the analysis; empty when it never sent initialize
+	ClientName    string `json:"client_name,omitempty"`
+	ClientVersion string `json:"client_version,omitempty"`
+
+	// Namespace isolates analyses of unrelated projects sharing a store;
+	// empty is DefaultNamespace
+	Namespace string `json:"namespace,omitempty"`
+
+	// Feedback holds searchers' ratings of this analysis; nil when unrated
+	Feedback *Feedback `json:"feedback,omitempty"`
+
+	// Trace is the full task tree of the run that produced this analysis;
+	// nil unless the orchestrator's trace_store is enabled
+	Trace *orchestrator.Trace `json:"trace,omitempty"`
+
+	// Delta replaces Result["content"] in an analysis file stored as a
+	// delta against an earlier analysis; it is resolved on load
+	Delta *ContentDelta `json:"delta,omitempty"`
+
+	// Signature is the HMAC of the rest of the analysis under the
+	// configured signing key; empty when stored without one
+	Signature string `json:"signature,omitempty"`
+
+	// Integrity is whether Signature matched when the analysis was loaded
+	// (see VerifyAnalysis); empty when no signing key is configured
+	Integrity string `json:"-"`
+}
+
+// Feedback counts how often an analysis was rated helpful or unhelpful
+type Feedback struct {
+	Helpful   int `json:"helpful"`
+	Unhelpful int `json:"unhelpful"`
 }
 
+// Rating returns the net rating: helpful minus unhelpful votes. Nil
+// feedback rates zero.
+func (f *Feedback) Rating() int {
+	if f == nil {
+		return 0
+	}
+	return f.Helpful - f.Unhelpful
+}
+
+// RatingBoost is the number of score points (on the 0-100 scale) added to a
+// search result per net helpful rating, or removed per net unhelpful one
+const RatingBoost = 5.0
+
+// RecencyBoost is the fraction by which a brand-new analysis' score is
+// raised when Config.RecencyHalfLife is set. The boost halves with every
+// half-life of age, so old analyses compete on relevance alone.
+const RecencyBoost = 0.5
+
 // SearchResult wraps an analysis result with a relevance score
 type SearchResult struct {
 	Data         *AnalysisData `json:"data"`
@@ -29,11 +88,29 @@ type SearchResult struct {
 
 // IndexEntry is a lightweight entry in the index
 type IndexEntry struct {
-	ID               string    `json:"id"`
-	Query            string    `json:"query"`
-	Focus            string    `json:"focus"`
-	Timestamp        time.Time `json:"timestamp"`
-	Path             string    `json:"path"`
-	HasVectorEmbed   bool      `json:"has_vector_embedding"`
-	StorageBackend   string    `json:"storage_backend"`
+	ID             string    `json:"id"`
+	Query          string    `json:"query"`
+	Focus          string    `json:"focus"`
+	Timestamp      time.Time `json:"timestamp"`
+	Path           string    `json:"path"`
+	HasVectorEmbed bool      `json:"has_vector_embedding"`
+	StorageBackend string    `json:"storage_backend"`
+	Namespace      string    `json:"namespace,omitempty"`
+}
+
+// DefaultNamespace holds analyses stored without a namespace
+const DefaultNamespace = "default"
+
+// SameNamespace reports whether two namespaces are the same, treating empty
+// as DefaultNamespace
+func SameNamespace(a, b string) bool {
+	return normalizeNamespace(a) == normalizeNamespace(b)
+}
+
+// normalizeNamespace maps the empty namespace to DefaultNamespace
+func normalizeNamespace(namespace string) string {
+	if namespace == "" {
+		return DefaultNamespace
+	}
+	return namespace
 }
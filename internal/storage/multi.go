@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MultiBackend writes through to several backends and reads from the first
+// (primary) one, falling back to the others in order when it fails. The
+// primary is the source of truth: its write errors are returned, while
+// failures of the other backends are only logged. With ParallelSearch set,
+// searches query every backend at once instead.
+type MultiBackend struct {
+	backends []Backend
+	parallel *ParallelSearch // nil searches backends in order
+}
+
+// NewMultiBackend creates a backend fanning out to primary and others
+func NewMultiBackend(primary Backend, others ...Backend) *MultiBackend {
+	return &MultiBackend{backends: append([]Backend{primary}, others...)}
+}
+
+// Store saves the analysis to every backend
+func (m *MultiBackend) Store(ctx context.Context, data *AnalysisData) error {
+	return m.writeAll("store", func(b Backend) error { return b.Store(ctx, data) })
+}
+
+// Search queries the primary backend, falling back on error, or every
+// backend in parallel when ParallelSearch is set
+func (m *MultiBackend) Search(ctx context.Context, query string, limit int) ([]*SearchResult, error) {
+	if m.parallel != nil {
+		return m.searchParallel(ctx, query, SearchOptions{Limit: limit})
+	}
+
+	var results []*SearchResult
+	err := m.readFirst("search", func(b Backend) error {
+		var err error
+		results, err = b.Search(ctx, query, limit)
+		return err
+	})
+	return results, err
+}
+
+// SearchStream streams from the primary backend, falling back on error as
+// long as no result has been delivered yet. With ParallelSearch set it
+// streams the merged results of a parallel search instead.
+func (m *MultiBackend) SearchStream(ctx context.Context, query string, opts SearchOptions, fn func(*SearchResult) error) error {
+	if m.parallel != nil {
+		results, err := m.searchParallel(ctx, query, opts)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var streamed bool
+	var fnErr error
+	err := m.readFirst("search", func(b Backend) error {
+		err := b.SearchStream(ctx, query, opts, func(r *SearchResult) error {
+			streamed = true
+			if err := fn(r); err != nil {
+				fnErr = err
+				return err
+			}
+			return nil
+		})
+		if err != nil && (streamed || fnErr != nil) {
+			// Falling back would repeat results already seen by fn
+			return &noFallbackError{err}
+		}
+		return err
+	})
+	var nf *noFallbackError
+	if errors.As(err, &nf) {
+		return nf.err
+	}
+	return err
+}
+
+// Get retrieves an analysis from the primary backend, falling back on error
+func (m *MultiBackend) Get(ctx context.Context, id string) (*AnalysisData, error) {
+	var data *AnalysisData
+	err := m.readFirst("get", func(b Backend) error {
+		var err error
+		data, err = b.Get(ctx, id)
+		return err
+	})
+	return data, err
+}
+
+// GetAll retrieves all analyses from the primary backend, falling back on error
+func (m *MultiBackend) GetAll(ctx context.Context) ([]*AnalysisData, error) {
+	var all []*AnalysisData
+	err := m.readFirst("get all", func(b Backend) error {
+		var err error
+		all, err = b.GetAll(ctx)
+		return err
+	})
+	return all, err
+}
+
+// Walk walks the primary backend. It does not fall back, since fn may
+// already have seen part of the primary's analyses.
+func (m *MultiBackend) Walk(ctx context.Context, fn func(*AnalysisData) error) error {
+	return m.backends[0].Walk(ctx, fn)
+}
+
+// PruneExpired prunes every backend, reporting the primary's count
+func (m *MultiBackend) PruneExpired(ctx context.Context) (int, error) {
+	var pruned int
+	err := m.writeAll("prune", func(b Backend) error {
+		n, err := b.PruneExpired(ctx)
+		if b == m.backends[0] {
+			pruned = n
+		}
+		return err
+	})
+	return pruned, err
+}
+
+// Consolidate consolidates path in every backend
+func (m *MultiBackend) Consolidate(ctx context.Context, path string) error {
+	return m.writeAll("consolidate", func(b Backend) error { return b.Consolidate(ctx, path) })
+}
+
+// Rate records the rating in every backend, returning the primary's copy
+func (m *MultiBackend) Rate(ctx context.Context, id string, helpful bool) (*AnalysisData, error) {
+	var rated *AnalysisData
+	err := m.writeAll("rate", func(b Backend) error {
+		data, err := b.Rate(ctx, id, helpful)
+		if b == m.backends[0] {
+			rated = data
+		}
+		return err
+	})
+	return rated, err
+}
+
+// Reindex rebuilds every backend's search structures
+func (m *MultiBackend) Reindex(ctx context.Context) error {
+	return m.writeAll("reindex", func(b Backend) error { return b.Reindex(ctx) })
+}
+
+// Close closes every backend, returning the first error
+func (m *MultiBackend) Close() error {
+	var first error
+	for _, b := range m.backends {
+		if err := b.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Name returns the backend names joined with "+", primary first
+func (m *MultiBackend) Name() string {
+	names := make([]string, len(m.backends))
+	for i, b := range m.backends {
+		names[i] = b.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+// writeAll runs op on every backend. A primary failure is returned; other
+// failures are logged so the primary copy is never lost to them.
+func (m *MultiBackend) writeAll(op string, fn func(Backend) error) error {
+	var primaryErr error
+	for i, b := range m.backends {
+		if err := fn(b); err != nil {
+			if i == 0 {
+				primaryErr = err
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Warning: %s failed on %s backend: %v\n", op, b.Name(), err)
+		}
+	}
+	return primaryErr
+}
+
+// readFirst runs op on each backend in order until one succeeds, returning
+// the primary's error when all fail
+func (m *MultiBackend) readFirst(op string, fn func(Backend) error) error {
+	var primaryErr error
+	for i, b := range m.backends {
+		err := fn(b)
+		if err == nil {
+			return nil
+		}
+		var nf *noFallbackError
+		if errors.As(err, &nf) {
+			return err
+		}
+		if i == 0 {
+			primaryErr = err
+		}
+		if i < len(m.backends)-1 {
+			fmt.Fprintf(os.Stderr, "Warning: %s failed on %s backend, falling back: %v\n", op, b.Name(), err)
+		}
+	}
+	return primaryErr
+}
+
+// noFallbackError stops readFirst from trying the next backend
+type noFallbackError struct {
+	err error
+}
+
+func (e *noFallbackError) Error() string { return e.err.Error() }
@@ -0,0 +1,186 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kukks/claude-rlm/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingBackend wraps a backend, failing searches and optionally stores
+type failingBackend struct {
+	storage.Backend
+	failStore bool
+}
+
+func (f *failingBackend) Store(ctx context.Context, data *storage.AnalysisData) error {
+	if f.failStore {
+		return errors.New("store unavailable")
+	}
+	return f.Backend.Store(ctx, data)
+}
+
+func (f *failingBackend) Search(ctx context.Context, query string, limit int) ([]*storage.SearchResult, error) {
+	return nil, errors.New("search unavailable")
+}
+
+func (f *failingBackend) SearchStream(ctx context.Context, query string, opts storage.SearchOptions, fn func(*storage.SearchResult) error) error {
+	return errors.New("search unavailable")
+}
+
+func newBM25(t *testing.T) storage.Backend {
+	backend, err := storage.NewBM25Backend(storage.DefaultConfig(t.TempDir()))
+	require.NoError(t, err)
+	return backend
+}
+
+func storeFixtures(t *testing.T, backend storage.Backend) {
+	ctx := context.Background()
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+		Path: "/project", Query: "authentication flow",
+		Result: map[string]interface{}{"summary": "Login uses JWT tokens"},
+	}))
+	for i, topic := range []string{"database schema", "logging setup", "build pipeline", "http routing"} {
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+			Path: "/project", Query: topic, Focus: string(rune('a' + i)),
+			Result: map[string]interface{}{"summary": topic},
+		}))
+	}
+}
+
+func TestMultiBackendStoresToAll(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := newBM25(t), newBM25(t)
+	multi := storage.NewMultiBackend(primary, secondary)
+	defer multi.Close()
+
+	storeFixtures(t, multi)
+
+	for _, b := range []storage.Backend{primary, secondary} {
+		all, err := b.GetAll(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 5)
+	}
+	assert.Equal(t, "bm25+bm25", multi.Name())
+}
+
+func TestMultiBackendSearchFallsBack(t *testing.T) {
+	ctx := context.Background()
+	primary := &failingBackend{Backend: newBM25(t)}
+	multi := storage.NewMultiBackend(primary, newBM25(t))
+	defer multi.Close()
+
+	storeFixtures(t, multi)
+
+	results, err := multi.Search(ctx, "authentication", 10)
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "authentication flow", results[0].Data.Query)
+
+	var streamed int
+	require.NoError(t, multi.SearchStream(ctx, "authentication", storage.SearchOptions{}, func(*storage.SearchResult) error {
+		streamed++
+		return nil
+	}))
+	assert.Equal(t, len(results), streamed)
+}
+
+func TestMultiBackendSecondaryStoreFailure(t *testing.T) {
+	ctx := context.Background()
+	primary := newBM25(t)
+	multi := storage.NewMultiBackend(primary, &failingBackend{Backend: newBM25(t), failStore: true})
+	defer multi.Close()
+
+	// A failing secondary doesn't fail the store or lose the primary copy
+	require.NoError(t, multi.Store(ctx, &storage.AnalysisData{Path: "/project", Query: "overview"}))
+	all, err := primary.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+
+	// A failing primary does
+	multi = storage.NewMultiBackend(&failingBackend{Backend: newBM25(t), failStore: true}, newBM25(t))
+	assert.Error(t, multi.Store(ctx, &storage.AnalysisData{Path: "/project", Query: "overview"}))
+}
+
+func TestNewBackendRejectsUnknownBackend(t *testing.T) {
+	config := storage.DefaultConfig(t.TempDir())
+	config.Backends = []string{"bm25", "qdrant"}
+
+	_, err := storage.NewBackend(context.Background(), config)
+	assert.ErrorContains(t, err, `unsupported storage backend "qdrant"`)
+}
+
+// slowBackend wraps a backend whose searches take delay, or until cancelled
+type slowBackend struct {
+	storage.Backend
+	delay     time.Duration
+	cancelled chan struct{}
+}
+
+func (s *slowBackend) SearchStream(ctx context.Context, query string, opts storage.SearchOptions, fn func(*storage.SearchResult) error) error {
+	select {
+	case <-time.After(s.delay):
+		return s.Backend.SearchStream(ctx, query, opts, fn)
+	case <-ctx.Done():
+		close(s.cancelled)
+		return ctx.Err()
+	}
+}
+
+func TestMultiBackendParallelSearch(t *testing.T) {
+	ctx := context.Background()
+	slow := &slowBackend{Backend: newBM25(t), delay: 5 * time.Second, cancelled: make(chan struct{})}
+	multi := storage.NewMultiBackend(slow, newBM25(t))
+	defer multi.Close()
+	storeFixtures(t, multi)
+
+	multi.SetParallelSearch(&storage.ParallelSearch{MinResults: 1})
+
+	// The fast secondary answers without waiting for the slow primary,
+	// which is then cancelled
+	start := time.Now()
+	results, err := multi.Search(ctx, "authentication", 10)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "authentication flow", results[0].Data.Query)
+
+	select {
+	case <-slow.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow backend was not cancelled")
+	}
+}
+
+func TestMultiBackendParallelSearchTimeout(t *testing.T) {
+	ctx := context.Background()
+	slow := &slowBackend{Backend: newBM25(t), delay: 5 * time.Second, cancelled: make(chan struct{})}
+	multi := storage.NewMultiBackend(newBM25(t), slow)
+	defer multi.Close()
+	storeFixtures(t, multi)
+
+	// More results than exist are wanted, so the search runs until the
+	// timeout and returns what the fast backend found
+	multi.SetParallelSearch(&storage.ParallelSearch{Timeout: 50 * time.Millisecond, MinResults: 100})
+
+	start := time.Now()
+	var streamed []*storage.SearchResult
+	require.NoError(t, multi.SearchStream(ctx, "authentication", storage.SearchOptions{}, func(r *storage.SearchResult) error {
+		streamed = append(streamed, r)
+		return nil
+	}))
+	assert.Less(t, time.Since(start), time.Second)
+	require.Len(t, streamed, 1)
+	assert.Equal(t, "authentication flow", streamed[0].Data.Query)
+
+	// Nothing answering in time is an error
+	multi = storage.NewMultiBackend(&slowBackend{Backend: newBM25(t), delay: 5 * time.Second, cancelled: make(chan struct{})})
+	defer multi.Close()
+	multi.SetParallelSearch(&storage.ParallelSearch{Timeout: 20 * time.Millisecond})
+	_, err := multi.Search(ctx, "authentication", 10)
+	assert.ErrorContains(t, err, "timed out")
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// ParallelSearch makes a MultiBackend query all its backends at once and
+// answer from whichever respond first, instead of trying them in order
+type ParallelSearch struct {
+	// Timeout bounds how long a search waits for backends; results that
+	// arrived by then are returned. Zero waits for every backend.
+	Timeout time.Duration
+
+	// MinResults returns as soon as this many distinct results arrived,
+	// cancelling the slower backends. Zero uses the search limit; with no
+	// limit either, every backend is waited for.
+	MinResults int
+}
+
+// SetParallelSearch enables parallel searching; nil restores in-order
+// fallback. Must be called before the backend is shared.
+func (m *MultiBackend) SetParallelSearch(parallel *ParallelSearch) {
+	m.parallel = parallel
+}
+
+// backendResults is one backend's complete answer to a parallel search
+type backendResults struct {
+	index   int
+	results []*SearchResult
+	err     error
+}
+
+// searchParallel runs the search on every backend concurrently and merges
+// the results, best score first, keeping each analysis once. It returns
+// when enough results arrived, every backend answered or the timeout
+// passed, and cancels the backends still running.
+func (m *MultiBackend) searchParallel(ctx context.Context, query string, opts SearchOptions) ([]*SearchResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	answers := make(chan backendResults, len(m.backends))
+	for i, b := range m.backends {
+		go func(i int, b Backend) {
+			var results []*SearchResult
+			err := b.SearchStream(ctx, query, opts, func(r *SearchResult) error {
+				results = append(results, r)
+				return nil
+			})
+			answers <- backendResults{index: i, results: results, err: err}
+		}(i, b)
+	}
+
+	var timeout <-chan time.Time
+	if m.parallel.Timeout > 0 {
+		timer := time.NewTimer(m.parallel.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	target := m.parallel.MinResults
+	if target <= 0 {
+		target = opts.Limit
+	}
+
+	merged := make(map[string]*SearchResult)
+	var primaryErr error
+	answered, succeeded := 0, 0
+wait:
+	for answered < len(m.backends) {
+		select {
+		case answer := <-answers:
+			answered++
+			if answer.err != nil {
+				if answer.index == 0 {
+					primaryErr = answer.err
+				}
+				fmt.Fprintf(os.Stderr, "Warning: search failed on %s backend: %v\n", m.backends[answer.index].Name(), answer.err)
+				continue
+			}
+			succeeded++
+			for _, r := range answer.results {
+				if seen, ok := merged[r.Data.ID]; !ok || r.Score > seen.Score {
+					merged[r.Data.ID] = r
+				}
+			}
+			if target > 0 && len(merged) >= target {
+				break wait
+			}
+		case <-timeout:
+			if succeeded == 0 {
+				return nil, fmt.Errorf("search timed out after %s", m.parallel.Timeout)
+			}
+			break wait
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if succeeded == 0 {
+		return nil, primaryErr
+	}
+
+	results := make([]*SearchResult, 0, len(merged))
+	for _, r := range merged {
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Data.ID < results[j].Data.ID
+	})
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
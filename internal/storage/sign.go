@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+)
+
+// Integrity states of a loaded analysis, set when a signing key is
+// configured
+const (
+	IntegrityVerified = "verified" // The signature matches the content
+	IntegrityUnsigned = "unsigned" // Stored without a signature
+	IntegrityTampered = "tampered" // The content changed after it was signed
+)
+
+// ErrTampered is returned when modifying an analysis whose signature does
+// not match its content, which would otherwise sign the tampered content
+var ErrTampered = errors.New("analysis signature does not match its content")
+
+// SignAnalysis returns the hex HMAC-SHA256 of data under key, computed
+// over its JSON encoding without the signature itself
+func SignAnalysis(data *AnalysisData, key []byte) (string, error) {
+	unsigned := *data
+	unsigned.Signature = ""
+	canonical, err := json.Marshal(&unsigned)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(canonical)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyAnalysis returns the integrity state of data under key: verified,
+// unsigned or tampered
+func VerifyAnalysis(data *AnalysisData, key []byte) string {
+	if data.Signature == "" {
+		return IntegrityUnsigned
+	}
+	expected, err := SignAnalysis(data, key)
+	if err != nil || !hmac.Equal([]byte(expected), []byte(data.Signature)) {
+		return IntegrityTampered
+	}
+	return IntegrityVerified
+}
@@ -0,0 +1,34 @@
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kukks/claude-rlm/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2025-06-01T08:30:00Z", time.Date(2025, 6, 1, 8, 30, 0, 0, time.UTC)},
+		{"2025-06-09", time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)},
+		{"36h", now.Add(-36 * time.Hour)},
+		{"7d", time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := storage.ParseSince(tt.value, now)
+		require.NoError(t, err, tt.value)
+		assert.True(t, tt.want.Equal(got), "%s: got %s", tt.value, got)
+	}
+
+	for _, bad := range []string{"", "last week", "-3d", "2025-13-01"} {
+		_, err := storage.ParseSince(bad, now)
+		assert.Error(t, err, bad)
+	}
+}
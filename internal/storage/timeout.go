@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is returned when a backend operation does not finish within
+// the configured OpTimeout
+var ErrTimeout = errors.New("storage operation timed out")
+
+// TimeoutBackend bounds every operation of the wrapped backend by a
+// timeout as well as the caller's context. An operation that does not
+// return in time is abandoned, so a backend ignoring its context (e.g. a
+// wedged network client) cannot block the caller indefinitely.
+type TimeoutBackend struct {
+	backend Backend
+	timeout time.Duration
+}
+
+// NewTimeoutBackend wraps backend so each operation fails with ErrTimeout
+// after timeout
+func NewTimeoutBackend(backend Backend, timeout time.Duration) *TimeoutBackend {
+	return &TimeoutBackend{backend: backend, timeout: timeout}
+}
+
+// run calls op with a context bounded by the timeout and returns its error,
+// or ErrTimeout / the context's error if op has not returned by then
+func (t *TimeoutBackend) run(ctx context.Context, name string, op func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- op(ctx) }()
+
+	select {
+	case err := <-done:
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == context.DeadlineExceeded {
+			return t.timeoutError(name)
+		}
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return t.timeoutError(name)
+		}
+		return ctx.Err()
+	}
+}
+
+func (t *TimeoutBackend) timeoutError(name string) error {
+	return fmt.Errorf("%s %s: %w after %s", t.backend.Name(), name, ErrTimeout, t.timeout)
+}
+
+// callbackGate keeps an abandoned streaming operation from calling back
+// into the caller once run has returned. The caller closes it before
+// returning.
+type callbackGate struct {
+	mu     sync.Mutex
+	closed bool
+}
+
+// call runs fn unless the gate is closed
+func (g *callbackGate) call(fn func() error) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return ErrTimeout
+	}
+	return fn()
+}
+
+func (g *callbackGate) close() {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+}
+
+// Store saves the analysis within the timeout
+func (t *TimeoutBackend) Store(ctx context.Context, data *AnalysisData) error {
+	return t.run(ctx, "store", func(ctx context.Context) error {
+		return t.backend.Store(ctx, data)
+	})
+}
+
+// Search queries the backend within the timeout
+func (t *TimeoutBackend) Search(ctx context.Context, query string, limit int) ([]*SearchResult, error) {
+	var results []*SearchResult
+	err := t.run(ctx, "search", func(ctx context.Context) error {
+		found, err := t.backend.Search(ctx, query, limit)
+		results = found
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// SearchStream streams results within the timeout
+func (t *TimeoutBackend) SearchStream(ctx context.Context, query string, opts SearchOptions, fn func(*SearchResult) error) error {
+	gate := &callbackGate{}
+	defer gate.close()
+	return t.run(ctx, "search", func(ctx context.Context) error {
+		return t.backend.SearchStream(ctx, query, opts, func(r *SearchResult) error {
+			return gate.call(func() error { return fn(r) })
+		})
+	})
+}
+
+// Get retrieves an analysis within the timeout
+func (t *TimeoutBackend) Get(ctx context.Context, id string) (*AnalysisData, error) {
+	var data *AnalysisData
+	err := t.run(ctx, "get", func(ctx context.Context) error {
+		found, err := t.backend.Get(ctx, id)
+		data = found
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// GetAll retrieves every analysis within the timeout
+func (t *TimeoutBackend) GetAll(ctx context.Context) ([]*AnalysisData, error) {
+	var all []*AnalysisData
+	err := t.run(ctx, "get all", func(ctx context.Context) error {
+		found, err := t.backend.GetAll(ctx)
+		all = found
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// Walk visits every analysis within the timeout
+func (t *TimeoutBackend) Walk(ctx context.Context, fn func(*AnalysisData) error) error {
+	gate := &callbackGate{}
+	defer gate.close()
+	return t.run(ctx, "walk", func(ctx context.Context) error {
+		return t.backend.Walk(ctx, func(data *AnalysisData) error {
+			return gate.call(func() error { return fn(data) })
+		})
+	})
+}
+
+// PruneExpired deletes expired analyses within the timeout
+func (t *TimeoutBackend) PruneExpired(ctx context.Context) (int, error) {
+	var pruned int
+	err := t.run(ctx, "prune", func(ctx context.Context) error {
+		n, err := t.backend.PruneExpired(ctx)
+		pruned = n
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return pruned, nil
+}
+
+// Consolidate deduplicates a path's analyses within the timeout
+func (t *TimeoutBackend) Consolidate(ctx context.Context, path string) error {
+	return t.run(ctx, "consolidate", func(ctx context.Context) error {
+		return t.backend.Consolidate(ctx, path)
+	})
+}
+
+// Rate records a rating within the timeout
+func (t *TimeoutBackend) Rate(ctx context.Context, id string, helpful bool) (*AnalysisData, error) {
+	var data *AnalysisData
+	err := t.run(ctx, "rate", func(ctx context.Context) error {
+		rated, err := t.backend.Rate(ctx, id, helpful)
+		data = rated
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Reindex rebuilds the backend's search structures within the timeout
+func (t *TimeoutBackend) Reindex(ctx context.Context) error {
+	return t.run(ctx, "reindex", func(ctx context.Context) error {
+		return t.backend.Reindex(ctx)
+	})
+}
+
+// Close closes the wrapped backend
+func (t *TimeoutBackend) Close() error {
+	return t.backend.Close()
+}
+
+// Name returns the wrapped backend's name
+func (t *TimeoutBackend) Name() string {
+	return t.backend.Name()
+}
@@ -0,0 +1,56 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kukks/claude-rlm/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingBackend wraps a backend whose stores and searches hang until
+// released, ignoring their context like a wedged network client
+type blockingBackend struct {
+	storage.Backend
+	release chan struct{}
+}
+
+func (b *blockingBackend) Store(ctx context.Context, data *storage.AnalysisData) error {
+	<-b.release
+	return nil
+}
+
+func (b *blockingBackend) Search(ctx context.Context, query string, limit int) ([]*storage.SearchResult, error) {
+	<-b.release
+	return nil, nil
+}
+
+func TestTimeoutBackend(t *testing.T) {
+	ctx := context.Background()
+	blocking := &blockingBackend{Backend: newBM25(t), release: make(chan struct{})}
+	defer close(blocking.release)
+	backend := storage.NewTimeoutBackend(blocking, 50*time.Millisecond)
+
+	start := time.Now()
+	err := backend.Store(ctx, &storage.AnalysisData{Path: "/project", Query: "q"})
+	assert.ErrorIs(t, err, storage.ErrTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+
+	start = time.Now()
+	_, err = backend.Search(ctx, "q", 10)
+	assert.ErrorIs(t, err, storage.ErrTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// A cancelled caller gets its own error rather than a timeout
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = backend.Search(cancelled, "q", 10)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, storage.ErrTimeout)
+
+	// Operations that finish in time pass through
+	require.NoError(t, backend.Reindex(ctx))
+	assert.Equal(t, "bm25", backend.Name())
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Tokenizer splits text into search terms. A backend uses the same tokenizer
+// to index documents and to tokenize queries, so both sides always agree.
+type Tokenizer struct {
+	minLength     int
+	stopwords     map[string]bool
+	caseSensitive bool
+	foldAccents   bool
+	bigrams       bool
+}
+
+// DefaultTokenizer returns the tokenizer used when none is configured
+func DefaultTokenizer() *Tokenizer {
+	return NewTokenizer(2, nil)
+}
+
+// NewTokenizer creates a tokenizer that drops tokens shorter than minLength
+// and any of the given stopwords
+func NewTokenizer(minLength int, stopwords []string) *Tokenizer {
+	t := &Tokenizer{
+		minLength: minLength,
+		stopwords: make(map[string]bool, len(stopwords)),
+	}
+	for _, word := range stopwords {
+		t.stopwords[strings.ToLower(word)] = true
+	}
+	return t
+}
+
+// SetCaseSensitive keeps the case of tokens, so identifiers like NewClient
+// and newclient are distinct terms. Stopwords still match in any case.
+// Must be called before the tokenizer is given to a backend.
+func (t *Tokenizer) SetCaseSensitive(caseSensitive bool) {
+	t.caseSensitive = caseSensitive
+}
+
+// SetFoldAccents strips accents before splitting, so "café" and "cafe"
+// are the same term instead of "café" losing its last letter. Must be
+// called before the tokenizer is given to a backend.
+func (t *Tokenizer) SetFoldAccents(foldAccents bool) {
+	t.foldAccents = foldAccents
+}
+
+// SetBigrams also emits each pair of adjacent tokens as a term joined by
+// "_", so a query phrase like "cache invalidation" ranks documents
+// containing the phrase above ones that merely contain both words. Must
+// be called before the tokenizer is given to a backend.
+func (t *Tokenizer) SetBigrams(bigrams bool) {
+	t.bigrams = bigrams
+}
+
+// Tokenize lowercases text unless case sensitive, splits it on anything
+// that isn't an ASCII letter or digit and filters out short tokens and
+// stopwords. Accents are folded first when enabled, and bigrams of the
+// remaining tokens follow them when enabled.
+func (t *Tokenizer) Tokenize(text string) []string {
+	if t.foldAccents {
+		text = foldAccents(text)
+	}
+
+	// Convert to lowercase unless case is significant
+	if !t.caseSensitive {
+		text = strings.ToLower(text)
+	}
+
+	// Split on whitespace and punctuation
+	tokens := strings.FieldsFunc(text, func(r rune) bool {
+		return !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'))
+	})
+
+	filtered := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if len(token) < t.minLength || t.stopwords[strings.ToLower(token)] {
+			continue
+		}
+		filtered = append(filtered, token)
+	}
+
+	if t.bigrams {
+		n := len(filtered)
+		for i := 1; i < n; i++ {
+			filtered = append(filtered, filtered[i-1]+"_"+filtered[i])
+		}
+	}
+
+	return filtered
+}
+
+// foldAccents decomposes text and drops the combining marks, leaving the
+// base letters
+func foldAccents(text string) string {
+	decomposed := norm.NFD.String(text)
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, decomposed)
+}
@@ -0,0 +1,150 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kukks/claude-rlm/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenize(t *testing.T) {
+	tokenizer := storage.DefaultTokenizer()
+
+	assert.Equal(t,
+		[]string{"newclient", "handles", "oauth2", "tokens", "in", "auth", "go"},
+		tokenizer.Tokenize("NewClient() handles OAuth2 tokens in auth.go!"))
+	assert.Empty(t, tokenizer.Tokenize("a b c -- ()"))
+}
+
+func TestTokenizeStopwords(t *testing.T) {
+	tokenizer := storage.NewTokenizer(3, []string{"The", "and"})
+
+	assert.Equal(t,
+		[]string{"cache", "store", "share", "logic"},
+		tokenizer.Tokenize("The cache and the store share logic in go"))
+}
+
+func TestTokenizeCaseSensitive(t *testing.T) {
+	tokenizer := storage.NewTokenizer(2, []string{"the"})
+	tokenizer.SetCaseSensitive(true)
+
+	assert.Equal(t,
+		[]string{"NewClient", "calls", "newclient"},
+		tokenizer.Tokenize("The NewClient() calls newclient"))
+}
+
+func TestTokenizeFoldAccents(t *testing.T) {
+	tokenizer := storage.DefaultTokenizer()
+	assert.Equal(t, []string{"caf", "na", "ve"}, tokenizer.Tokenize("café naïve"))
+
+	tokenizer.SetFoldAccents(true)
+	assert.Equal(t, []string{"cafe", "naive"}, tokenizer.Tokenize("Café naïve"))
+}
+
+func TestTokenizeMinLength(t *testing.T) {
+	assert.Equal(t, []string{"go", "os", "ci"}, storage.DefaultTokenizer().Tokenize("c go os ci"))
+	assert.Equal(t, []string{"c", "go", "os", "ci"}, storage.NewTokenizer(1, nil).Tokenize("c go os ci"))
+	assert.Equal(t, []string{"written"}, storage.NewTokenizer(3, nil).Tokenize("written in c go"))
+}
+
+func TestTokenizeBigrams(t *testing.T) {
+	tokenizer := storage.NewTokenizer(2, []string{"the"})
+	tokenizer.SetBigrams(true)
+
+	assert.Equal(t,
+		[]string{"cache", "invalidation", "logic", "cache_invalidation", "invalidation_logic"},
+		tokenizer.Tokenize("The cache invalidation logic"))
+	assert.Equal(t, []string{"single"}, tokenizer.Tokenize("single"))
+}
+
+func TestBackendBigramsRankPhrases(t *testing.T) {
+	config := storage.DefaultConfig(t.TempDir())
+	config.Tokenizer = storage.DefaultTokenizer()
+	config.Tokenizer.SetBigrams(true)
+	backend := newTestBackend(t, config)
+	ctx := context.Background()
+
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+		Query:  "scattered",
+		Result: map[string]interface{}{"content": "invalidation of sessions and a cache of templates"},
+	}))
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+		Query:  "phrase",
+		Result: map[string]interface{}{"content": "cache invalidation happens on write"},
+	}))
+	for _, topic := range []string{"build pipeline", "database schema", "http routing"} {
+		require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+			Query:  topic,
+			Result: map[string]interface{}{"content": topic},
+		}))
+	}
+
+	results, err := backend.Search(ctx, "cache invalidation", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "phrase", results[0].Data.Query)
+}
+
+func TestBackendCaseSensitiveSearch(t *testing.T) {
+	config := storage.DefaultConfig(t.TempDir())
+	config.Tokenizer = storage.DefaultTokenizer()
+	config.Tokenizer.SetCaseSensitive(true)
+	backend := newTestBackend(t, config)
+	ctx := context.Background()
+
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+		Query:  "exported constructor",
+		Result: map[string]interface{}{"content": "NewClient builds the client"},
+	}))
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+		Query:  "private helper",
+		Result: map[string]interface{}{"content": "newclient is unexported"},
+	}))
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+		Query:  "database schema",
+		Result: map[string]interface{}{"content": "migrations and indexes"},
+	}))
+
+	// Each spelling finds only the analysis using it
+	results, err := backend.Search(ctx, "NewClient", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "exported constructor", results[0].Data.Query)
+
+	results, err = backend.Search(ctx, "newclient", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "private helper", results[0].Data.Query)
+}
+
+func TestBackendUsesTokenizerForQueries(t *testing.T) {
+	config := storage.DefaultConfig(t.TempDir())
+	config.Tokenizer = storage.NewTokenizer(2, []string{"authentication"})
+	backend := newTestBackend(t, config)
+	ctx := context.Background()
+
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+		Query:  "authentication flow",
+		Result: map[string]interface{}{"content": "session tokens are validated"},
+	}))
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+		Query:  "build pipeline",
+		Result: map[string]interface{}{"content": "release artifacts"},
+	}))
+	require.NoError(t, backend.Store(ctx, &storage.AnalysisData{
+		Query:  "database schema",
+		Result: map[string]interface{}{"content": "migrations and indexes"},
+	}))
+
+	// The stopword is dropped from the query exactly as it was from the index
+	results, err := backend.Search(ctx, "authentication", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	results, err = backend.Search(ctx, "session tokens", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "authentication flow", results[0].Data.Query)
+}
@@ -2,9 +2,12 @@ package updater
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v57/github"
@@ -17,11 +20,37 @@ const (
 	Repo  = "claude-rlm"
 )
 
+// ErrNoAssetForPlatform is matched by a NoAssetForPlatformError when a
+// release has no binary for the running platform
+var ErrNoAssetForPlatform = errors.New("no release asset for this platform")
+
+// NoAssetForPlatformError reports that a release has no rlm_<os>_<arch>
+// binary for the running platform, listing the assets it does have
+type NoAssetForPlatformError struct {
+	OS        string
+	Arch      string
+	Available []string
+}
+
+func (e *NoAssetForPlatformError) Error() string {
+	available := "none"
+	if len(e.Available) > 0 {
+		available = strings.Join(e.Available, ", ")
+	}
+	return fmt.Sprintf("no release asset for platform %s/%s (available: %s); build from source instead", e.OS, e.Arch, available)
+}
+
+// Is matches ErrNoAssetForPlatform
+func (e *NoAssetForPlatformError) Is(target error) bool {
+	return target == ErrNoAssetForPlatform
+}
+
 // Updater handles auto-updates
 type Updater struct {
 	client         *github.Client
 	currentVersion string
 	logger         zerolog.Logger
+	jitter         time.Duration // Upper bound of the random delay added to checks
 }
 
 // New creates a new updater
@@ -33,6 +62,32 @@ func New(currentVersion string, logger zerolog.Logger) *Updater {
 	}
 }
 
+// SetJitter delays the startup check and each periodic check by a random
+// amount up to jitter, so a fleet started together doesn't hit GitHub in
+// lockstep. Zero checks exactly on schedule.
+func (u *Updater) SetJitter(jitter time.Duration) {
+	u.jitter = jitter
+}
+
+// InitialDelay returns how long to wait before the startup check
+func (u *Updater) InitialDelay() time.Duration {
+	return u.randomDelay()
+}
+
+// NextCheck returns when the check following one at from should run:
+// interval later, plus up to the jitter
+func (u *Updater) NextCheck(from time.Time, interval time.Duration) time.Time {
+	return from.Add(interval + u.randomDelay())
+}
+
+// randomDelay returns a uniformly random delay in [0, jitter)
+func (u *Updater) randomDelay() time.Duration {
+	if u.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(u.jitter)))
+}
+
 // CheckForUpdate checks if a newer version is available
 func (u *Updater) CheckForUpdate(ctx context.Context) (*github.RepositoryRelease, bool, error) {
 	release, _, err := u.client.Repositories.GetLatestRelease(ctx, Owner, Repo)
@@ -69,7 +124,13 @@ func (u *Updater) Update(ctx context.Context, release *github.RepositoryRelease)
 	}
 
 	if assetURL == "" {
-		return fmt.Errorf("no asset found for platform %s_%s", runtime.GOOS, runtime.GOARCH)
+		available := make([]string, 0, len(release.Assets))
+		for _, asset := range release.Assets {
+			if asset.Name != nil {
+				available = append(available, *asset.Name)
+			}
+		}
+		return &NoAssetForPlatformError{OS: runtime.GOOS, Arch: runtime.GOARCH, Available: available}
 	}
 
 	u.logger.Info().Str("url", assetURL).Msg("Downloading update")
@@ -94,32 +155,39 @@ func (u *Updater) Update(ctx context.Context, release *github.RepositoryRelease)
 	return nil
 }
 
-// AutoUpdate runs periodic update checks in the background
+// AutoUpdate runs periodic update checks in the background, spread out by
+// the configured jitter
 func (u *Updater) AutoUpdate(ctx context.Context, interval time.Duration, autoApply bool) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+	timer := time.NewTimer(time.Until(u.NextCheck(time.Now(), interval)))
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			release, hasUpdate, err := u.CheckForUpdate(ctx)
-			if err != nil {
-				u.logger.Warn().Err(err).Msg("Update check failed")
-				continue
-			}
-
-			if hasUpdate {
-				u.logger.Info().Str("version", *release.TagName).Msg("Update available")
-
-				if autoApply {
-					if err := u.Update(ctx, release); err != nil {
-						u.logger.Error().Err(err).Msg("Auto-update failed")
-					}
-				}
-			}
+		case <-timer.C:
+			u.checkAndApply(ctx, autoApply)
+			timer.Reset(time.Until(u.NextCheck(time.Now(), interval)))
 
 		case <-ctx.Done():
 			return
 		}
 	}
 }
+
+// checkAndApply runs one background update check
+func (u *Updater) checkAndApply(ctx context.Context, autoApply bool) {
+	release, hasUpdate, err := u.CheckForUpdate(ctx)
+	if err != nil {
+		u.logger.Warn().Err(err).Msg("Update check failed")
+		return
+	}
+
+	if hasUpdate {
+		u.logger.Info().Str("version", *release.TagName).Msg("Update available")
+
+		if autoApply {
+			if err := u.Update(ctx, release); err != nil {
+				u.logger.Error().Err(err).Msg("Auto-update failed")
+			}
+		}
+	}
+}
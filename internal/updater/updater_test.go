@@ -0,0 +1,66 @@
+package updater_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/kukks/claude-rlm/internal/updater"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextCheckJitter(t *testing.T) {
+	upd := updater.New("v1.0.0", zerolog.Nop())
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	interval := 24 * time.Hour
+
+	// Without jitter checks run exactly on schedule
+	assert.Equal(t, from.Add(interval), upd.NextCheck(from, interval))
+	assert.Zero(t, upd.InitialDelay())
+
+	jitter := 30 * time.Minute
+	upd.SetJitter(jitter)
+
+	spread := false
+	for i := 0; i < 100; i++ {
+		next := upd.NextCheck(from, interval)
+		assert.False(t, next.Before(from.Add(interval)), "next check %s is early", next)
+		assert.True(t, next.Before(from.Add(interval+jitter)), "next check %s is past the jitter window", next)
+		if !next.Equal(from.Add(interval)) {
+			spread = true
+		}
+
+		delay := upd.InitialDelay()
+		assert.GreaterOrEqual(t, delay, time.Duration(0))
+		assert.Less(t, delay, jitter)
+	}
+	assert.True(t, spread, "jitter should move checks off the exact interval")
+}
+
+func TestUpdateNoAssetForPlatform(t *testing.T) {
+	upd := updater.New("v1.0.0", zerolog.Nop())
+	release := &github.RepositoryRelease{
+		TagName: github.String("v1.1.0"),
+		Assets: []*github.ReleaseAsset{
+			{Name: github.String("rlm_plan9_386")},
+			{Name: github.String("rlm_aix_ppc64")},
+			{Name: github.String("checksums.txt")},
+		},
+	}
+
+	err := upd.Update(context.Background(), release)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, updater.ErrNoAssetForPlatform))
+
+	var noAsset *updater.NoAssetForPlatformError
+	require.True(t, errors.As(err, &noAsset))
+	assert.Equal(t, runtime.GOOS, noAsset.OS)
+	assert.Equal(t, runtime.GOARCH, noAsset.Arch)
+	assert.Equal(t, []string{"rlm_plan9_386", "rlm_aix_ppc64", "checksums.txt"}, noAsset.Available)
+	assert.Contains(t, err.Error(), "rlm_plan9_386")
+}